@@ -5,6 +5,7 @@ package bundle
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -165,15 +166,36 @@ func (o *Bundle) NoteCopy(processedImages *imageset.ProcessedImages, reg ImagesM
 	return NewLocations(ui).Save(reg, destinationRef, locationsCfg, util.NewNoopLevelLogger())
 }
 
+// PullOpts options used when pulling a Bundle
+type PullOpts struct {
+	// PullNestedBundles when true every nested bundle is pulled as well
+	PullNestedBundles bool
+	// ExtractOpts options used when extracting each bundle to disk
+	ExtractOpts ctlimg.ExtractOpts
+	// ImageMap when provided the ImagesLock files are always rewritten with the locations produced by the map
+	// instead of checking if the images are present in the bundle repository
+	ImageMap *lockconfig.ImageMap
+}
+
 // Pull Downloads bundle image to disk and checks if it can update the ImagesLock file
 func (o *Bundle) Pull(outputPath string, logger Logger, pullNestedBundles bool) (bool, error) {
-	isRootBundleRelocated, err := o.pull(outputPath, logger, pullNestedBundles, "", map[string]bool{}, 0)
+	return o.PullWithOpts(outputPath, logger, PullOpts{PullNestedBundles: pullNestedBundles})
+}
+
+// PullWithOpts Downloads bundle image to disk using the provided options and checks if it can update the ImagesLock file
+func (o *Bundle) PullWithOpts(outputPath string, logger Logger, opts PullOpts) (bool, error) {
+	// The ImagesLock file is always needed to pull a bundle, so the .imgpkg folder is never filtered out
+	opts.ExtractOpts.PathFilter = opts.ExtractOpts.PathFilter.WithAlwaysIncluded(ImgpkgDir)
+
+	isRootBundleRelocated, err := o.pull(outputPath, logger, opts, "", map[string]bool{}, 0)
 	if err != nil {
 		return false, err
 	}
 
 	logger.Logf("\nLocating image lock file images...\n")
-	if isRootBundleRelocated {
+	if opts.ImageMap != nil {
+		logger.Logf("The bundle's Images Lock file (.imgpkg/images.yml) was updated using the provided image map\n")
+	} else if isRootBundleRelocated {
 		logger.Logf("The bundle repo (%s) is hosting every image specified in the bundle's Images Lock file (.imgpkg/images.yml)\n", o.Repo())
 	} else {
 		logger.Logf("One or more images not found in bundle repo; skipping lock file update\n")
@@ -181,7 +203,7 @@ func (o *Bundle) Pull(outputPath string, logger Logger, pullNestedBundles bool)
 	return isRootBundleRelocated, nil
 }
 
-func (o *Bundle) pull(baseOutputPath string, logger Logger, pullNestedBundles bool, bundlePath string, imagesProcessed map[string]bool, numSubBundles int) (bool, error) {
+func (o *Bundle) pull(baseOutputPath string, logger Logger, opts PullOpts, bundlePath string, imagesProcessed map[string]bool, numSubBundles int) (bool, error) {
 	img, err := o.checkedImage()
 	if err != nil {
 		return false, err
@@ -198,7 +220,18 @@ func (o *Bundle) pull(baseOutputPath string, logger Logger, pullNestedBundles bo
 		return false, err
 	}
 
-	err = ctlimg.NewDirImage(filepath.Join(baseOutputPath, bundlePath), img, util.NewIndentedLevelLogger(logger)).AsDirectory()
+	extractOpts := opts.ExtractOpts
+	extractOpts.Source = o.Repo()
+
+	if o.rootBundle(bundlePath) && extractOpts.Clean {
+		// nested bundles folder is only populated by pull, so it is always fully recreated
+		err = os.RemoveAll(filepath.Join(baseOutputPath, ImgpkgDir, BundlesDir))
+		if err != nil {
+			return false, fmt.Errorf("Removing nested bundles from previous pull: %s", err)
+		}
+	}
+
+	err = ctlimg.NewDirImageWithOpts(filepath.Join(baseOutputPath, bundlePath), img, util.NewIndentedLevelLogger(logger), extractOpts).AsDirectory()
 	if err != nil {
 		return false, fmt.Errorf("Extracting bundle into directory: %s", err)
 	}
@@ -217,12 +250,26 @@ func (o *Bundle) pull(baseOutputPath string, logger Logger, pullNestedBundles bo
 		return false, err
 	}
 
-	isRelocatedToBundle, err := bundleImageRefs.UpdateRelativeToRepo(o.imgRetriever, o.Repo())
-	if err != nil {
-		return false, err
+	// images mapped by the image map are not relocated to the bundle repository, the ImagesLock is rewritten
+	// but the bundle is not reported as relocated
+	var isRelocatedToBundle, isMapped bool
+	if opts.ImageMap != nil {
+		unmappedImages, err := bundleImageRefs.UpdateWithImageMap(o.imgRetriever, *opts.ImageMap)
+		if err != nil {
+			return false, err
+		}
+		for _, image := range unmappedImages {
+			logger.Warnf("No mapping found for image '%s', keeping its original location\n", image)
+		}
+		isMapped = true
+	} else {
+		isRelocatedToBundle, err = bundleImageRefs.UpdateRelativeToRepo(o.imgRetriever, o.Repo())
+		if err != nil {
+			return false, err
+		}
 	}
 
-	if pullNestedBundles {
+	if opts.PullNestedBundles {
 		for _, bundleImgRef := range bundleImageRefs.ImageRefs() {
 			if isBundle, alreadyProcessedImage := imagesProcessed[bundleImgRef.Image]; alreadyProcessedImage {
 				if isBundle {
@@ -259,7 +306,7 @@ func (o *Bundle) pull(baseOutputPath string, logger Logger, pullNestedBundles bo
 			if err != nil {
 				return false, err
 			}
-			_, err = subBundle.pull(baseOutputPath, util.NewIndentedLevelLogger(logger), pullNestedBundles, o.subBundlePath(bundleDigest), imagesProcessed, numSubBundles)
+			_, err = subBundle.pull(baseOutputPath, util.NewIndentedLevelLogger(logger), opts, o.subBundlePath(bundleDigest), imagesProcessed, numSubBundles)
 			if err != nil {
 				return false, err
 			}
@@ -272,7 +319,7 @@ func (o *Bundle) pull(baseOutputPath string, logger Logger, pullNestedBundles bo
 		}
 	}
 
-	if isRelocatedToBundle {
+	if isRelocatedToBundle || isMapped {
 		err := bundleImageRefs.ImagesLock().WriteToPath(filepath.Join(baseOutputPath, bundlePath, ImgpkgDir, ImagesLockFile))
 		if err != nil {
 			return false, fmt.Errorf("Rewriting image lock file: %s", err)
@@ -0,0 +1,116 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"fmt"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/versions"
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+const (
+	// BundleDepsFile file, inside the ImgpkgDir, that declares the nested bundles by version constraint
+	BundleDepsFile = "bundle-deps.yml"
+	// BundleDepConstraintAnnotation annotation added to the ImagesLock entries resolved from the BundleDepsFile with the constraint used
+	BundleDepConstraintAnnotation = "imgpkg.carvel.dev/bundle-constraint"
+	// BundleDepVersionAnnotation annotation added to the ImagesLock entries resolved from the BundleDepsFile with the tag selected
+	BundleDepVersionAnnotation = "imgpkg.carvel.dev/bundle-version"
+)
+
+// TagsLister lists the tags present in a repository
+type TagsLister interface {
+	ListTags(repo regname.Repository) ([]string, error)
+}
+
+// BundleDepsResolver resolves the nested bundles declared in a BundleDeps to digest references
+type BundleDepsResolver struct {
+	imgRetriever ImagesMetadata
+	tagsLister   TagsLister
+	logger       Logger
+}
+
+// NewBundleDepsResolver creates a BundleDepsResolver
+func NewBundleDepsResolver(imgRetriever ImagesMetadata, tagsLister TagsLister, logger Logger) *BundleDepsResolver {
+	return &BundleDepsResolver{imgRetriever: imgRetriever, tagsLister: tagsLister, logger: logger}
+}
+
+// Resolve selects, for each dependency, the highest tag that satisfies the constraint and returns
+// the ImageRefs pinned to the digest of that tag
+func (r *BundleDepsResolver) Resolve(deps lockconfig.BundleDeps) ([]lockconfig.ImageRef, error) {
+	var resolved []lockconfig.ImageRef
+
+	for _, dep := range deps.Bundles {
+		repo, err := regname.NewRepository(dep.Repository)
+		if err != nil {
+			return nil, fmt.Errorf("Parsing repository '%s': %s", dep.Repository, err)
+		}
+
+		constraint, err := versions.NewConstraint(dep.Constraint)
+		if err != nil {
+			return nil, err
+		}
+
+		tags, err := r.tagsLister.ListTags(repo)
+		if err != nil {
+			return nil, fmt.Errorf("Listing tags of '%s': %s", dep.Repository, err)
+		}
+
+		tag, found := constraint.HighestMatching(tags)
+		if !found {
+			return nil, fmt.Errorf("Expected a tag of '%s' to match constraint '%s', but none did (found %d tags)", dep.Repository, dep.Constraint, len(tags))
+		}
+
+		digest, err := r.imgRetriever.Digest(repo.Tag(tag))
+		if err != nil {
+			return nil, fmt.Errorf("Resolving '%s:%s': %s", dep.Repository, tag, err)
+		}
+		digestRef := repo.Digest(digest.String()).Name()
+
+		isBundle, err := NewBundleFromRef(digestRef, r.imgRetriever, nil, nil).IsBundle()
+		if err != nil {
+			return nil, fmt.Errorf("Checking if '%s:%s' is a bundle: %s", dep.Repository, tag, err)
+		}
+		if !isBundle {
+			return nil, fmt.Errorf("Expected '%s:%s' to be a bundle", dep.Repository, tag)
+		}
+
+		r.logger.Logf("Resolved '%s' (%s) to '%s' (%s)\n", dep.Repository, dep.Constraint, tag, digestRef)
+
+		resolved = append(resolved, lockconfig.ImageRef{
+			Image: digestRef,
+			Annotations: map[string]string{
+				BundleDepConstraintAnnotation: dep.Constraint,
+				BundleDepVersionAnnotation:    tag,
+			},
+		})
+	}
+
+	return resolved, nil
+}
+
+// LockBundleDeps replaces in imagesLock the entries previously resolved from a BundleDeps with resolvedRefs,
+// every other image is kept
+func LockBundleDeps(imagesLock lockconfig.ImagesLock, resolvedRefs []lockconfig.ImageRef) lockconfig.ImagesLock {
+	resolved := map[string]struct{}{}
+	for _, ref := range resolvedRefs {
+		resolved[ref.Image] = struct{}{}
+	}
+
+	result := lockconfig.NewEmptyImagesLock()
+	for _, img := range imagesLock.Images {
+		if _, found := img.Annotations[BundleDepConstraintAnnotation]; found {
+			continue
+		}
+		if _, found := resolved[img.Image]; found {
+			continue
+		}
+		result.AddImageRef(img)
+	}
+	for _, ref := range resolvedRefs {
+		result.AddImageRef(ref)
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle_test
+
+import (
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
+	"carvel.dev/imgpkg/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleDepsResolver(t *testing.T) {
+	fakeRegistry := helpers.NewFakeRegistry(t, &helpers.Logger{LogLevel: helpers.LogDebug})
+	defer fakeRegistry.CleanUp()
+
+	fakeRegistry.WithRandomBundleAndImages("org/database:1.2.0", nil)
+	database := fakeRegistry.WithRandomBundleAndImages("org/database:1.4.1", nil)
+	fakeRegistry.WithRandomBundleAndImages("org/database:2.0.0", nil)
+	fakeRegistry.WithRandomTaggedImage("org/plain:1.0.0", "1.0.0")
+	reg := fakeRegistry.Build()
+
+	newDeps := func(repo, constraint string) lockconfig.BundleDeps {
+		return lockconfig.BundleDeps{
+			LockVersion: lockconfig.LockVersion{APIVersion: lockconfig.BundleDepsAPIVersion, Kind: lockconfig.BundleDepsKind},
+			Bundles:     []lockconfig.BundleDep{{Repository: fakeRegistry.ReferenceOnTestServer(repo), Constraint: constraint}},
+		}
+	}
+
+	t.Run("resolves the highest tag that matches the constraint", func(t *testing.T) {
+		subject := bundle.NewBundleDepsResolver(reg, reg, util.NewNoopLevelLogger())
+
+		refs, err := subject.Resolve(newDeps("org/database", "^1.2.0"))
+		require.NoError(t, err)
+
+		require.Len(t, refs, 1)
+		assert.Equal(t, database.RefDigest, refs[0].Image)
+		assert.Equal(t, map[string]string{
+			bundle.BundleDepConstraintAnnotation: "^1.2.0",
+			bundle.BundleDepVersionAnnotation:    "1.4.1",
+		}, refs[0].Annotations)
+	})
+
+	t.Run("fails when no tag matches the constraint", func(t *testing.T) {
+		subject := bundle.NewBundleDepsResolver(reg, reg, util.NewNoopLevelLogger())
+
+		_, err := subject.Resolve(newDeps("org/database", "^3.0.0"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "to match constraint '^3.0.0', but none did")
+	})
+
+	t.Run("fails when the matching tag is not a bundle", func(t *testing.T) {
+		subject := bundle.NewBundleDepsResolver(reg, reg, util.NewNoopLevelLogger())
+
+		_, err := subject.Resolve(newDeps("org/plain", "1.x"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "to be a bundle")
+	})
+}
+
+func TestLockBundleDeps(t *testing.T) {
+	digest1 := "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	digest2 := "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	digest3 := "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+
+	imagesLock := lockconfig.NewEmptyImagesLock()
+	imagesLock.AddImageRef(lockconfig.ImageRef{Image: "registry.io/app@" + digest1})
+	imagesLock.AddImageRef(lockconfig.ImageRef{
+		Image:       "registry.io/database@" + digest2,
+		Annotations: map[string]string{bundle.BundleDepConstraintAnnotation: "^1.0.0", bundle.BundleDepVersionAnnotation: "1.0.0"},
+	})
+
+	resolvedRef := lockconfig.ImageRef{
+		Image:       "registry.io/database@" + digest3,
+		Annotations: map[string]string{bundle.BundleDepConstraintAnnotation: "^1.0.0", bundle.BundleDepVersionAnnotation: "1.1.0"},
+	}
+
+	result := bundle.LockBundleDeps(imagesLock, []lockconfig.ImageRef{resolvedRef})
+	assert.Equal(t, []lockconfig.ImageRef{{Image: "registry.io/app@" + digest1}, resolvedRef}, result.Images)
+}
@@ -0,0 +1,29 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	ctlimg "carvel.dev/imgpkg/pkg/imgpkg/image"
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+)
+
+// FileDigests returns the sha256 of every file in the bundle, keyed by the path of the file
+func (o *Bundle) FileDigests() (map[string]string, error) {
+	img, err := o.checkedImage()
+	if err != nil {
+		return nil, err
+	}
+
+	return ctlimg.NewDirImage("", img, util.NewNoopLogger()).FileDigests()
+}
+
+// ReadFile returns the contents of a file in the bundle, false is returned when the bundle does not contain the file
+func (o *Bundle) ReadFile(filePath string) ([]byte, bool, error) {
+	img, err := o.checkedImage()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ctlimg.NewDirImage("", img, util.NewNoopLogger()).ReadFile(filePath)
+}
@@ -5,9 +5,11 @@ package bundle
 
 import (
 	"archive/tar"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
@@ -27,11 +29,22 @@ func (o *Bundle) ImagesRefsWithErrors() []ImageRef {
 func (o *Bundle) AllImagesLockRefs(concurrency int, logger util.LoggerWithLevels) ([]*Bundle, ImageRefs, error) {
 	throttleReq := util.NewThrottle(concurrency)
 
-	return o.buildAllImagesLock(&throttleReq, logger)
+	return o.buildAllImagesLock(&throttleReq, logger, nil)
 }
 
-// buildAllImagesLock recursive function that will iterate over the Bundle graph and collect all the bundles and images
-func (o *Bundle) buildAllImagesLock(throttleReq *util.Throttle, logger util.LoggerWithLevels) ([]*Bundle, ImageRefs, error) {
+// NestedBundleCycleError returned when a bundle references, directly or through its nested bundles, itself
+type NestedBundleCycleError struct {
+	// Path bundles from the first occurrence of the bundle until it is referenced again
+	Path []string
+}
+
+func (e NestedBundleCycleError) Error() string {
+	return fmt.Sprintf("Detected a cycle in nested bundles: %s", strings.Join(e.Path, " -> "))
+}
+
+// buildAllImagesLock recursive function that will iterate over the Bundle graph and collect all the bundles and images.
+// ancestors contains the digest references of the bundles that lead to this bundle, and is used to detect cycles
+func (o *Bundle) buildAllImagesLock(throttleReq *util.Throttle, logger util.LoggerWithLevels, ancestors []string) ([]*Bundle, ImageRefs, error) {
 	img, err := o.checkedImage()
 	if err != nil {
 		return nil, ImageRefs{}, err
@@ -42,6 +55,14 @@ func (o *Bundle) buildAllImagesLock(throttleReq *util.Throttle, logger util.Logg
 		panic(fmt.Sprintf("Internal inconsistency: The Bundle Reference '%s' does not have a digest", o.DigestRef()))
 	}
 
+	for i, ancestor := range ancestors {
+		ancestorDigestRef, err := regname.NewDigest(ancestor)
+		if err == nil && ancestorDigestRef.DigestStr() == bundleDigestRef.DigestStr() {
+			return nil, ImageRefs{}, NestedBundleCycleError{Path: append(append([]string{}, ancestors[i:]...), o.DigestRef())}
+		}
+	}
+	ancestors = append(append([]string{}, ancestors...), o.DigestRef())
+
 	locationsConfig := LocationsConfig{
 		logger:          logger,
 		imgRetriever:    o.imgRetriever,
@@ -72,7 +93,7 @@ func (o *Bundle) buildAllImagesLock(throttleReq *util.Throttle, logger util.Logg
 
 		image := image.DeepCopy()
 		go func() {
-			nestedBundles, nestedBundlesProcessedImageRefs, imgRef, err := o.imagesLockIfIsBundle(throttleReq, image, logger)
+			nestedBundles, nestedBundlesProcessedImageRefs, imgRef, err := o.imagesLockIfIsBundle(throttleReq, image, logger, ancestors)
 			if err != nil {
 				errChan <- err
 				return
@@ -128,7 +149,7 @@ func (o *Bundle) fetchImagesRef(img regv1.Image, locationsConfig ImageRefLocatio
 }
 
 // imagesLockIfIsBundle retrieve all the images associated with Bundle imgRef. if it is not a bundle will return no new images
-func (o *Bundle) imagesLockIfIsBundle(throttleReq *util.Throttle, imgRef ImageRef, logger util.LoggerWithLevels) ([]*Bundle, ImageRefs, lockconfig.ImageRef, error) {
+func (o *Bundle) imagesLockIfIsBundle(throttleReq *util.Throttle, imgRef ImageRef, logger util.LoggerWithLevels, ancestors []string) ([]*Bundle, ImageRefs, lockconfig.ImageRef, error) {
 	newImgRef, bundle, err := o.bundleFetcher.Bundle(throttleReq, imgRef)
 	if err != nil {
 		return nil, ImageRefs{}, lockconfig.ImageRef{}, err
@@ -137,8 +158,11 @@ func (o *Bundle) imagesLockIfIsBundle(throttleReq *util.Throttle, imgRef ImageRe
 	var processedImageRefs ImageRefs
 	var nestedBundles []*Bundle
 	if bundle != nil {
-		nestedBundles, processedImageRefs, err = bundle.buildAllImagesLock(throttleReq, logger)
+		nestedBundles, processedImageRefs, err = bundle.buildAllImagesLock(throttleReq, logger, ancestors)
 		if err != nil {
+			if errors.As(err, &NestedBundleCycleError{}) {
+				return nil, ImageRefs{}, lockconfig.ImageRef{}, err
+			}
 			return nil, ImageRefs{}, lockconfig.ImageRef{}, fmt.Errorf("Retrieving images for bundle '%s': %s", imgRef.Image, err)
 		}
 	}
@@ -9,11 +9,14 @@ import (
 	"testing"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle/bundlefakes"
 	"carvel.dev/imgpkg/pkg/imgpkg/imageset"
 	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
 	"carvel.dev/imgpkg/pkg/imgpkg/plainimage"
 	"carvel.dev/imgpkg/test/helpers"
 	regname "github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/stretchr/testify/require"
 )
 
@@ -1293,3 +1296,36 @@ func handleSetup(t *testing.T, setup imageOrBundleDef, logger *helpers.Logger, t
 	fmt.Printf("top bundle digest: %s\n", tree.TopRef()[0])
 	return imagesLockReader, registryBuilder, tree.TopRef()[0], tree
 }
+
+func TestBundle_AllImagesLock_NestedBundlesCycle(t *testing.T) {
+	logger := &helpers.Logger{LogLevel: helpers.LogDebug}
+	registryBuilder := helpers.NewFakeRegistry(t, logger)
+	defer registryBuilder.CleanUp()
+
+	bundleA := registryBuilder.WithRandomBundle("repo/bundle-a")
+	bundleB := registryBuilder.WithRandomBundle("repo/bundle-b")
+	reg := registryBuilder.Build()
+
+	imagesLocks := map[string]lockconfig.ImagesLock{
+		bundleA.Digest: {Images: []lockconfig.ImageRef{{Image: bundleB.RefDigest}}},
+		bundleB.Digest: {Images: []lockconfig.ImageRef{{Image: bundleA.RefDigest}}},
+	}
+	imagesLockReader := &bundlefakes.FakeImagesLockReader{}
+	imagesLockReader.ReadStub = func(img regv1.Image) (lockconfig.ImagesLock, error) {
+		digest, err := img.Digest()
+		require.NoError(t, err)
+		return imagesLocks[digest.String()], nil
+	}
+
+	subject := bundle.NewBundleFromRef(bundleA.RefDigest, reg, imagesLockReader, bundle.NewRegistryFetcher(reg, imagesLockReader))
+	_, _, err := subject.AllImagesLockRefs(1, util.NewNoopLevelLogger())
+	require.Error(t, err)
+
+	cycleErr := bundle.NestedBundleCycleError{}
+	require.ErrorAs(t, err, &cycleErr)
+	require.Len(t, cycleErr.Path, 3)
+	require.True(t, isSameImage(t, bundleA.RefDigest, cycleErr.Path[0]))
+	require.True(t, isSameImage(t, bundleB.RefDigest, cycleErr.Path[1]))
+	require.True(t, isSameImage(t, bundleA.RefDigest, cycleErr.Path[2]))
+	require.Contains(t, err.Error(), "Detected a cycle in nested bundles: ")
+}
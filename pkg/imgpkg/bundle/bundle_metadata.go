@@ -0,0 +1,30 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"path/filepath"
+)
+
+const (
+	// BundleMetadataFile file, inside the ImgpkgDir, that contains the metadata of the bundle
+	BundleMetadataFile       = "bundle.yml"
+	BundleMetadataKind       = "Bundle"
+	BundleMetadataAPIVersion = "imgpkg.carvel.dev/v1alpha1"
+)
+
+// MetadataPath returns the location of the BundleMetadataFile of the bundle, the file might not exist
+func (b Contents) MetadataPath() (string, error) {
+	imgpkgDirs, err := b.findImgpkgDirs()
+	if err != nil {
+		return "", err
+	}
+
+	err = b.validateImgpkgDirs(imgpkgDirs)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(imgpkgDirs[0], BundleMetadataFile), nil
+}
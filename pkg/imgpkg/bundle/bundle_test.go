@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"carvel.dev/imgpkg/pkg/imgpkg/image"
 	"carvel.dev/imgpkg/pkg/imgpkg/imageset"
 	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
 	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
@@ -84,6 +85,129 @@ func TestPullBundleWritingContentsToDisk(t *testing.T) {
 	})
 }
 
+func TestPullBundleWithImagesAsLayout(t *testing.T) {
+	logger := util.NewNoopLevelLogger()
+
+	fakeRegistry := helpers.NewFakeRegistry(t, &helpers.Logger{LogLevel: helpers.LogDebug})
+	defer fakeRegistry.CleanUp()
+
+	fakeRegistry.WithBundleFromPath("repo/some-bundle-name", "test_assets/bundle").WithEveryImageFromPath("test_assets/image_with_config", map[string]string{})
+
+	reg := fakeRegistry.Build()
+	imagesLockReader := bundle.NewImagesLockReader()
+	subject := bundle.NewBundleFromRef(fakeRegistry.ReferenceOnTestServer("repo/some-bundle-name"), reg, imagesLockReader, bundle.NewRegistryFetcher(reg, imagesLockReader))
+	outputPath := t.TempDir()
+
+	_, err := subject.Pull(outputPath, logger, false)
+	require.NoError(t, err)
+
+	require.NoError(t, subject.WriteImagesAsLayout(outputPath, 1, logger))
+	// running it twice replaces the previous layout
+	require.NoError(t, subject.WriteImagesAsLayout(outputPath, 1, logger))
+
+	imagesLock, err := lockconfig.NewImagesLockFromPath(filepath.Join(outputPath, ".imgpkg", "images.yml"))
+	require.NoError(t, err)
+
+	layoutPath := filepath.Join(outputPath, bundle.ImagesLayoutDir)
+	require.FileExists(t, filepath.Join(layoutPath, "oci-layout"))
+	index, err := os.ReadFile(filepath.Join(layoutPath, "index.json"))
+	require.NoError(t, err)
+	assert.Equal(t, len(imagesLock.Images), strings.Count(string(index), bundle.ImageRefNameAnnotation))
+	for _, img := range imagesLock.Images {
+		assert.Contains(t, string(index), img.Image)
+	}
+
+	t.Run("fails when the bundle contains an images folder", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(layoutPath))
+		require.NoError(t, os.MkdirAll(layoutPath, 0700))
+
+		err := subject.WriteImagesAsLayout(outputPath, 1, logger)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is not an OCI Image Layout")
+	})
+}
+
+func TestPullBundleWithImageMap(t *testing.T) {
+	logger := util.NewNoopLevelLogger()
+
+	fakeRegistry := helpers.NewFakeRegistry(t, &helpers.Logger{LogLevel: helpers.LogDebug})
+	defer fakeRegistry.CleanUp()
+
+	appImg := fakeRegistry.WithRandomImage("library/app")
+	otherImg := fakeRegistry.WithRandomImage("other/app")
+	mirroredImg := fakeRegistry.CopyImage(*appImg, "mirror/library/app")
+	fakeRegistry.WithRandomBundle("repo/bundle").WithImageRefs([]lockconfig.ImageRef{
+		{Image: appImg.RefDigest},
+		{Image: otherImg.RefDigest},
+	})
+
+	reg := fakeRegistry.Build()
+	imagesLockReader := bundle.NewImagesLockReader()
+	newSubject := func() *bundle.Bundle {
+		return bundle.NewBundleFromRef(fakeRegistry.ReferenceOnTestServer("repo/bundle"), reg, imagesLockReader, bundle.NewRegistryFetcher(reg, imagesLockReader))
+	}
+
+	newImageMap := func(to string) *lockconfig.ImageMap {
+		return &lockconfig.ImageMap{
+			LockVersion: lockconfig.LockVersion{APIVersion: lockconfig.ImageMapAPIVersion, Kind: lockconfig.ImageMapKind},
+			Mappings:    []lockconfig.ImageMapping{{From: fakeRegistry.Host() + "/library", To: to}},
+		}
+	}
+
+	t.Run("rewrites the mapped images, keeps the others and does not report the bundle as relocated", func(t *testing.T) {
+		outputPath := t.TempDir()
+
+		relocated, err := newSubject().PullWithOpts(outputPath, logger, bundle.PullOpts{ImageMap: newImageMap(fakeRegistry.Host() + "/mirror/library")})
+		require.NoError(t, err)
+		assert.False(t, relocated)
+
+		imagesLock, err := lockconfig.NewImagesLockFromPath(filepath.Join(outputPath, ".imgpkg", "images.yml"))
+		require.NoError(t, err)
+		require.Len(t, imagesLock.Images, 2)
+		assert.Equal(t, mirroredImg.RefDigest, imagesLock.Images[0].Image)
+		assert.Equal(t, otherImg.RefDigest, imagesLock.Images[1].Image)
+	})
+
+	t.Run("fails when a mapped image does not exist", func(t *testing.T) {
+		_, err := newSubject().PullWithOpts(t.TempDir(), logger, bundle.PullOpts{ImageMap: newImageMap(fakeRegistry.Host() + "/missing")})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), fmt.Sprintf("Image '%s' mapped to '%s/missing/app@%s' could not be found", appImg.RefDigest, fakeRegistry.Host(), appImg.Digest))
+	})
+}
+
+func TestBundleVerifyDirectory(t *testing.T) {
+	logger := util.NewNoopLevelLogger()
+
+	fakeRegistry := helpers.NewFakeRegistry(t, &helpers.Logger{LogLevel: helpers.LogDebug})
+	defer fakeRegistry.CleanUp()
+
+	appImg := fakeRegistry.WithRandomImage("library/app")
+	// image is also present in the bundle repository, so that pull rewrites the ImagesLock.
+	// It is tagged so that it does not compete with the bundle for the latest tag
+	fakeRegistry.CopyImage(*appImg, "repo/bundle:app")
+	fakeRegistry.WithRandomBundle("repo/bundle").WithImageRefs([]lockconfig.ImageRef{{Image: appImg.RefDigest}})
+
+	reg := fakeRegistry.Build()
+	imagesLockReader := bundle.NewImagesLockReader()
+	subject := bundle.NewBundleFromRef(fakeRegistry.ReferenceOnTestServer("repo/bundle"), reg, imagesLockReader, bundle.NewRegistryFetcher(reg, imagesLockReader))
+	outputPath := t.TempDir()
+
+	updated, err := subject.Pull(outputPath, logger, false)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	diff, err := subject.VerifyDirectory(outputPath, logger, image.ExtractOpts{})
+	require.NoError(t, err)
+	assert.False(t, diff.HasChanges(), "unexpected changes: %#v", diff)
+
+	require.NoError(t, os.WriteFile(filepath.Join(outputPath, "hand-edit.yml"), []byte("changed"), 0600))
+	require.NoError(t, lockconfig.NewEmptyImagesLock().WriteToPath(filepath.Join(outputPath, ".imgpkg", "images.yml")))
+	diff, err = subject.VerifyDirectory(outputPath, logger, image.ExtractOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hand-edit.yml"}, diff.Added)
+	assert.Equal(t, []string{".imgpkg/images.yml"}, diff.Modified)
+}
+
 func TestPullNestedBundlesWritingContentsToDisk(t *testing.T) {
 	logger := util.NewNoopLevelLogger()
 	pullNestedBundles := true
@@ -4,12 +4,18 @@
 package bundle
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
 	"carvel.dev/imgpkg/pkg/imgpkg/plainimage"
 	"carvel.dev/imgpkg/pkg/imgpkg/registry"
 	regname "github.com/google/go-containerregistry/pkg/name"
@@ -75,6 +81,202 @@ func (b Contents) PresentsAsBundle() (bool, error) {
 	return true, nil
 }
 
+// StageWithImagesLock copies the contents of the bundle into a temporary folder and writes imagesLock
+// to .imgpkg/images.yml in the copy, so that the provided paths are never modified.
+// Returns the Contents that point to the copy and the temporary folder that needs to be removed by the caller
+// with RemoveStagingDir
+func (b Contents) StageWithImagesLock(imagesLock lockconfig.ImagesLock, logger Logger) (Contents, string, error) {
+	imgpkgDirs, err := b.findImgpkgDirs()
+	if err != nil {
+		return Contents{}, "", err
+	}
+
+	// By default the .imgpkg folder is created in the root of the first path
+	imgpkgDirPathIdx := 0
+	if len(imgpkgDirs) > 0 {
+		imgpkgDirPathIdx = -1
+		for idx, flagPath := range b.paths {
+			absFlagPath, err := filepath.Abs(flagPath)
+			if err != nil {
+				return Contents{}, "", err
+			}
+			if len(imgpkgDirs) == 1 && filepath.Dir(imgpkgDirs[0]) == absFlagPath {
+				imgpkgDirPathIdx = idx
+				break
+			}
+		}
+
+		if imgpkgDirPathIdx == -1 {
+			err = b.validateImgpkgDirs(imgpkgDirs)
+			if err == nil {
+				panic("Internal inconsistency: expected .imgpkg folder to be invalid")
+			}
+			return Contents{}, "", err
+		}
+	}
+
+	stagingDir, err := os.MkdirTemp("", "imgpkg-bundle-staging")
+	if err != nil {
+		return Contents{}, "", err
+	}
+
+	var stagedPaths []string
+	dirModes := map[string]os.FileMode{}
+	for idx, flagPath := range b.paths {
+		stagedPath := filepath.Join(stagingDir, strconv.Itoa(idx))
+		err = b.copyPath(flagPath, stagedPath, dirModes)
+		if err != nil {
+			_ = os.RemoveAll(stagingDir)
+			return Contents{}, "", fmt.Errorf("Staging '%s': %s", flagPath, err)
+		}
+		stagedPaths = append(stagedPaths, stagedPath)
+	}
+
+	stagedImgpkgDir := filepath.Join(stagedPaths[imgpkgDirPathIdx], ImgpkgDir)
+	err = os.MkdirAll(stagedImgpkgDir, 0700)
+	if err != nil {
+		_ = os.RemoveAll(stagingDir)
+		return Contents{}, "", err
+	}
+
+	stagedImagesLockPath := filepath.Join(stagedImgpkgDir, ImagesLockFile)
+	if _, err := os.Stat(stagedImagesLockPath); err == nil {
+		logger.Warnf("Replacing existing %s with the generated one\n", filepath.Join(ImgpkgDir, ImagesLockFile))
+		// the copy keeps the permissions of the original file, that might be read-only
+		err = os.Remove(stagedImagesLockPath)
+		if err != nil {
+			_ = os.RemoveAll(stagingDir)
+			return Contents{}, "", err
+		}
+	}
+
+	err = imagesLock.WriteToPath(stagedImagesLockPath)
+	if err != nil {
+		_ = os.RemoveAll(stagingDir)
+		return Contents{}, "", err
+	}
+
+	err = applyDirModes(dirModes)
+	if err != nil {
+		_ = RemoveStagingDir(stagingDir)
+		return Contents{}, "", err
+	}
+
+	return NewContents(stagedPaths, b.excludedPaths, b.preservePermissions), stagingDir, nil
+}
+
+// RemoveStagingDir removes the folder created by StageWithImagesLock, including the copied folders that are read-only
+func RemoveStagingDir(stagingDir string) error {
+	err := filepath.WalkDir(stagingDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.Chmod(path, 0700)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.RemoveAll(stagingDir)
+}
+
+// copyPath copies the file or folder in srcPath into the folder dstPath.
+// When srcPath is a file it is copied into dstPath keeping the same name, to mirror how paths are added to the bundle.
+// The folders are created writable and their permissions are recorded in dirModes, to be applied once everything is copied
+func (b Contents) copyPath(srcPath, dstPath string, dirModes map[string]os.FileMode) error {
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		err = os.MkdirAll(dstPath, 0700)
+		if err != nil {
+			return err
+		}
+		return copyFile(srcPath, filepath.Join(dstPath, filepath.Base(srcPath)), info.Mode())
+	}
+
+	return filepath.Walk(srcPath, func(walkedPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcPath, walkedPath)
+		if err != nil {
+			return err
+		}
+
+		for _, excludedPath := range b.excludedPaths {
+			if excludedPath == relPath {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		target := filepath.Join(dstPath, relPath)
+		if info.IsDir() {
+			dirModes[target] = info.Mode().Perm()
+			return os.MkdirAll(target, 0700)
+		}
+
+		if (info.Mode() & os.ModeType) != 0 {
+			return fmt.Errorf("Expected file '%s' to be a regular file", walkedPath)
+		}
+
+		return copyFile(walkedPath, target, info.Mode())
+	})
+}
+
+// applyDirModes sets the permissions of the folders, starting with the deepest ones so that a
+// parent folder without write or search permissions does not prevent changing its children
+func applyDirModes(dirModes map[string]os.FileMode) error {
+	var dirs []string
+	for dir := range dirModes {
+		dirs = append(dirs, dir)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+
+	for _, dir := range dirs {
+		err := os.Chmod(dir, dirModes[dir])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(srcPath, dstPath string, mode os.FileMode) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dstPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		_ = dst.Close()
+		return err
+	}
+
+	err = dst.Close()
+	if err != nil {
+		return err
+	}
+
+	// Ensure the permissions are not affected by the umask
+	return os.Chmod(dstPath, mode.Perm())
+}
+
 func (b Contents) validate() error {
 	imgpkgDirs, err := b.findImgpkgDirs()
 	if err != nil {
@@ -4,6 +4,7 @@
 package bundle_test
 
 import (
+	"fmt"
 	"testing"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
@@ -13,6 +14,8 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewContentsBundleWithBundles(t *testing.T) {
@@ -84,3 +87,59 @@ images:
 		}
 	})
 }
+
+func TestContentsVerifyImages(t *testing.T) {
+	fakeRegistry := helpers.NewFakeRegistry(t, &helpers.Logger{LogLevel: helpers.LogDebug})
+	defer fakeRegistry.CleanUp()
+
+	img := fakeRegistry.WithRandomImage("library/image")
+	imgDigest, err := img.Image.Digest()
+	require.NoError(t, err)
+	imgRef := fakeRegistry.ReferenceOnTestServer("library/image@" + imgDigest.String())
+	nestedBundle := fakeRegistry.WithBundleFromPath("library/bundle", "test_assets/bundle").
+		WithEveryImageFromPath("test_assets/image_with_config", map[string]string{})
+	reg := fakeRegistry.Build()
+
+	assets := &helpers.Assets{T: t}
+	defer assets.CleanCreatedFolders()
+
+	t.Run("returns every image and if it is a bundle when all images exist", func(t *testing.T) {
+		imagesLockYAML := fmt.Sprintf(`---
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: ImagesLock
+images:
+- image: %s
+- image: %s
+`, imgRef, nestedBundle.RefDigest)
+		bundleBuilder := helpers.NewBundleDir(t, assets)
+		bundleDir := bundleBuilder.CreateBundleDir(helpers.BundleYAML, imagesLockYAML)
+
+		subject := bundle.NewContents([]string{bundleDir}, nil, false)
+		imgRefs, err := subject.VerifyImages(reg, util.NewNoopLevelLogger())
+		require.NoError(t, err)
+		require.Len(t, imgRefs, 2)
+		assert.Equal(t, imgRef, imgRefs[0].Image)
+		assert.Equal(t, bundle.ContentImage, imgRefs[0].ImageType)
+		assert.Equal(t, nestedBundle.RefDigest, imgRefs[1].Image)
+		assert.Equal(t, bundle.BundleImage, imgRefs[1].ImageType)
+	})
+
+	t.Run("fails listing every image that cannot be found", func(t *testing.T) {
+		missingImgRef := fakeRegistry.ReferenceOnTestServer("library/missing@sha256:703218c0465075f4425e58fac086e09e1de5c340b12976ab9eb8ad26615c3715")
+		imagesLockYAML := fmt.Sprintf(`---
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: ImagesLock
+images:
+- image: %s
+- image: %s
+`, imgRef, missingImgRef)
+		bundleBuilder := helpers.NewBundleDir(t, assets)
+		bundleDir := bundleBuilder.CreateBundleDir(helpers.BundleYAML, imagesLockYAML)
+
+		subject := bundle.NewContents([]string{bundleDir}, nil, false)
+		_, err := subject.VerifyImages(reg, util.NewNoopLevelLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), fmt.Sprintf("Image '%s' could not be found", missingImgRef))
+		assert.NotContains(t, err.Error(), fmt.Sprintf("Image '%s'", imgRef))
+	})
+}
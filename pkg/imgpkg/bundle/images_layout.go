@@ -0,0 +1,107 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+)
+
+const (
+	// ImagesLayoutDir folder, inside the pulled bundle, where the images are written as an OCI Image Layout
+	ImagesLayoutDir = "images"
+	// ImageRefNameAnnotation OCI annotation used to store the original location of the image in the layout index
+	ImageRefNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+// WriteImagesAsLayout writes every image referenced by the bundle, including nested bundles and their images,
+// into an OCI Image Layout in the images folder of outputPath
+func (o *Bundle) WriteImagesAsLayout(outputPath string, concurrency int, logger util.LoggerWithLevels) error {
+	layoutPath := filepath.Join(outputPath, ImagesLayoutDir)
+
+	err := o.prepareImagesLayoutDir(layoutPath)
+	if err != nil {
+		return err
+	}
+
+	_, imageRefs, err := o.AllImagesLockRefs(concurrency, logger)
+	if err != nil {
+		return fmt.Errorf("Reading Images from Bundle: %s", err)
+	}
+
+	imagesLayout, err := layout.Write(layoutPath, empty.Index)
+	if err != nil {
+		return fmt.Errorf("Creating OCI Image Layout: %s", err)
+	}
+
+	logger.Logf("\nWriting images to OCI Image Layout '%s'\n", layoutPath)
+
+	for _, imgRef := range imageRefs.ImageRefs() {
+		ref, err := regname.NewDigest(imgRef.PrimaryLocation())
+		if err != nil {
+			return err
+		}
+
+		util.NewIndentedLevelLogger(logger).Logf("Writing image '%s'\n", imgRef.Image)
+
+		desc, err := o.imgRetriever.Get(ref)
+		if err != nil {
+			return fmt.Errorf("Fetching image '%s': %s", imgRef.PrimaryLocation(), err)
+		}
+
+		annotations := layout.WithAnnotations(map[string]string{ImageRefNameAnnotation: imgRef.Image})
+		if desc.MediaType.IsIndex() {
+			idx, err := registry.DescriptorIndex(o.imgRetriever, desc)
+			if err != nil {
+				return err
+			}
+			err = imagesLayout.AppendIndex(idx, annotations)
+			if err != nil {
+				return fmt.Errorf("Writing image '%s' to OCI Image Layout: %s", imgRef.Image, err)
+			}
+			continue
+		}
+
+		img, err := registry.DescriptorImage(o.imgRetriever, desc)
+		if err != nil {
+			return err
+		}
+		err = imagesLayout.AppendImage(img, annotations)
+		if err != nil {
+			return fmt.Errorf("Writing image '%s' to OCI Image Layout: %s", imgRef.Image, err)
+		}
+	}
+
+	return nil
+}
+
+// prepareImagesLayoutDir removes the OCI Image Layout written by a previous pull and ensures
+// the bundle does not contain a folder with the same name
+func (o *Bundle) prepareImagesLayoutDir(layoutPath string) error {
+	_, err := os.Stat(layoutPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	_, err = os.Stat(filepath.Join(layoutPath, "oci-layout"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("Unable to write images to '%s': folder already exists and is not an OCI Image Layout (hint: bundle might contain an '%s' folder)", layoutPath, ImagesLayoutDir)
+		}
+		return err
+	}
+
+	return os.RemoveAll(layoutPath)
+}
@@ -0,0 +1,189 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/jsonpath"
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultImageJSONPath JSONPath used to find image references in YAML files when none is provided
+	DefaultImageJSONPath = "$..image"
+	// ImageOriginalRefAnnotation annotation added to the generated ImagesLock entries with the reference found in the files.
+	// The same key is used by kbld, which allows the generated ImagesLock to be used with kbld
+	ImageOriginalRefAnnotation = "kbld.carvel.dev/id"
+)
+
+var yamlDocSeparator = regexp.MustCompile(`(?m)^---[ \t]*(#.*)?$`)
+
+// ImagesLockGenerator finds image references in YAML files and creates an ImagesLock with them resolved to digests
+type ImagesLockGenerator struct {
+	paths          []string
+	excludedPaths  []string
+	imageJSONPaths []jsonpath.Path
+	imgRetriever   ImagesMetadata
+	logger         Logger
+}
+
+// NewImagesLockGenerator creates an ImagesLockGenerator that will look for image references in the YAML files
+// present in paths using the provided JSONPath expressions
+func NewImagesLockGenerator(paths []string, excludedPaths []string, imageJSONPaths []string, imgRetriever ImagesMetadata, logger Logger) (*ImagesLockGenerator, error) {
+	if len(imageJSONPaths) == 0 {
+		imageJSONPaths = []string{DefaultImageJSONPath}
+	}
+
+	var parsedPaths []jsonpath.Path
+	for _, expr := range imageJSONPaths {
+		parsedPath, err := jsonpath.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		parsedPaths = append(parsedPaths, parsedPath)
+	}
+
+	return &ImagesLockGenerator{
+		paths:          paths,
+		excludedPaths:  excludedPaths,
+		imageJSONPaths: parsedPaths,
+		imgRetriever:   imgRetriever,
+		logger:         logger,
+	}, nil
+}
+
+// Generate the ImagesLock with every image reference found in the YAML files
+func (g *ImagesLockGenerator) Generate() (lockconfig.ImagesLock, error) {
+	imageRefs, err := g.findImageRefs()
+	if err != nil {
+		return lockconfig.ImagesLock{}, err
+	}
+
+	imagesLock := lockconfig.NewEmptyImagesLock()
+	for _, imageRef := range imageRefs {
+		ref, err := regname.ParseReference(imageRef, regname.WeakValidation)
+		if err != nil {
+			return lockconfig.ImagesLock{}, fmt.Errorf("Parsing image reference '%s': %s", imageRef, err)
+		}
+
+		digest, err := g.imgRetriever.Digest(ref)
+		if err != nil {
+			return lockconfig.ImagesLock{}, fmt.Errorf("Resolving image '%s': %s", imageRef, err)
+		}
+
+		digestRef := ref.Context().Name() + "@" + digest.String()
+		g.logger.Debugf("Resolved image '%s' to '%s'\n", imageRef, digestRef)
+
+		imagesLock.AddImageRef(lockconfig.ImageRef{
+			Image:       digestRef,
+			Annotations: map[string]string{ImageOriginalRefAnnotation: imageRef},
+		})
+	}
+
+	g.logger.Logf("Found %d image(s) in the provided files\n", len(imagesLock.Images))
+
+	return imagesLock, nil
+}
+
+// findImageRefs retrieves all the unique image references present in the YAML files in the order they were found
+func (g *ImagesLockGenerator) findImageRefs() ([]string, error) {
+	var imageRefs []string
+	seenImageRefs := map[string]bool{}
+
+	for _, path := range g.paths {
+		err := filepath.Walk(path, func(walkedPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			relPath, err := filepath.Rel(path, walkedPath)
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				if g.isExcluded(relPath) || info.Name() == ImgpkgDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if g.isExcluded(relPath) || !isYAMLFile(walkedPath) {
+				return nil
+			}
+
+			foundImageRefs, err := g.imageRefsInFile(walkedPath)
+			if err != nil {
+				return err
+			}
+
+			for _, imageRef := range foundImageRefs {
+				if !seenImageRefs[imageRef] {
+					seenImageRefs[imageRef] = true
+					imageRefs = append(imageRefs, imageRef)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Looking for images in '%s': %s", path, err)
+		}
+	}
+
+	return imageRefs, nil
+}
+
+func (g *ImagesLockGenerator) imageRefsInFile(path string) ([]string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var imageRefs []string
+	for _, doc := range yamlDocSeparator.Split(string(contents), -1) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+
+		var parsedDoc interface{}
+		err := yaml.Unmarshal([]byte(doc), &parsedDoc)
+		if err != nil {
+			return nil, fmt.Errorf("Parsing YAML file '%s': %s", path, err)
+		}
+
+		for _, imageJSONPath := range g.imageJSONPaths {
+			for _, imageRef := range imageJSONPath.FindStrings(parsedDoc) {
+				imageRef = strings.TrimSpace(imageRef)
+				if imageRef == "" {
+					continue
+				}
+				g.logger.Tracef("Found image '%s' in '%s' using '%s'\n", imageRef, path, imageJSONPath)
+				imageRefs = append(imageRefs, imageRef)
+			}
+		}
+	}
+
+	return imageRefs, nil
+}
+
+func (g *ImagesLockGenerator) isExcluded(relPath string) bool {
+	for _, path := range g.excludedPaths {
+		if path == relPath {
+			return true
+		}
+	}
+	return false
+}
+
+func isYAMLFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yml" || ext == ".yaml"
+}
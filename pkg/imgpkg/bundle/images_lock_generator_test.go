@@ -0,0 +1,159 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
+	"carvel.dev/imgpkg/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImagesLockGenerator(t *testing.T) {
+	fakeRegistry := helpers.NewFakeRegistry(t, &helpers.Logger{LogLevel: helpers.LogDebug})
+	defer fakeRegistry.CleanUp()
+
+	appImg := fakeRegistry.WithRandomTaggedImage("library/app:v1", "v1")
+	sidecarImg := fakeRegistry.WithRandomImage("library/sidecar")
+	reg := fakeRegistry.Build()
+
+	appTagRef := fakeRegistry.ReferenceOnTestServer("library/app:v1")
+
+	configDir, err := os.MkdirTemp("", "imgpkg-images-lock-generator")
+	require.NoError(t, err)
+	defer os.RemoveAll(configDir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "deployment.yml"), []byte(fmt.Sprintf(`---
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - image: %s
+      - image: %s
+---
+kind: Pod
+spec:
+  containers:
+  - image: %s
+`, appTagRef, sidecarImg.RefDigest, appTagRef)), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "custom.yaml"), []byte(fmt.Sprintf(`
+kind: Custom
+spec:
+  workload:
+    ref: %s
+`, sidecarImg.RefDigest)), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "README.md"), []byte("image: not/yaml:file"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(configDir, ".imgpkg"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, ".imgpkg", "images.yml"), []byte(emptyImagesLockYAML), 0600))
+
+	t.Run("resolves every image found in the YAML files to a digest", func(t *testing.T) {
+		subject, err := bundle.NewImagesLockGenerator([]string{configDir}, nil, nil, reg, util.NewNoopLevelLogger())
+		require.NoError(t, err)
+
+		imagesLock, err := subject.Generate()
+		require.NoError(t, err)
+
+		require.Len(t, imagesLock.Images, 2)
+		assert.Equal(t, appImg.RefDigest, imagesLock.Images[0].Image)
+		assert.Equal(t, map[string]string{bundle.ImageOriginalRefAnnotation: appTagRef}, imagesLock.Images[0].Annotations)
+		assert.Equal(t, sidecarImg.RefDigest, imagesLock.Images[1].Image)
+	})
+
+	t.Run("uses the provided JSONPaths to find images", func(t *testing.T) {
+		subject, err := bundle.NewImagesLockGenerator([]string{configDir}, []string{"deployment.yml"}, []string{"$.spec.workload.ref"}, reg, util.NewNoopLevelLogger())
+		require.NoError(t, err)
+
+		imagesLock, err := subject.Generate()
+		require.NoError(t, err)
+
+		require.Len(t, imagesLock.Images, 1)
+		assert.Equal(t, sidecarImg.RefDigest, imagesLock.Images[0].Image)
+	})
+
+	t.Run("fails when an image cannot be resolved", func(t *testing.T) {
+		missingDir, err := os.MkdirTemp("", "imgpkg-images-lock-generator-missing")
+		require.NoError(t, err)
+		defer os.RemoveAll(missingDir)
+
+		missingRef := fakeRegistry.ReferenceOnTestServer("library/missing:v1")
+		require.NoError(t, os.WriteFile(filepath.Join(missingDir, "pod.yml"), []byte("image: "+missingRef), 0600))
+
+		subject, err := bundle.NewImagesLockGenerator([]string{missingDir}, nil, nil, reg, util.NewNoopLevelLogger())
+		require.NoError(t, err)
+
+		_, err = subject.Generate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), fmt.Sprintf("Resolving image '%s'", missingRef))
+	})
+
+	t.Run("staging the bundle writes the images lock without changing the original files", func(t *testing.T) {
+		imagesLock := lockconfig.NewEmptyImagesLock()
+		imagesLock.AddImageRef(lockconfig.ImageRef{Image: appImg.RefDigest})
+
+		contents := bundle.NewContents([]string{configDir}, nil, false)
+		stagedContents, stagingDir, err := contents.StageWithImagesLock(imagesLock, util.NewNoopLevelLogger())
+		require.NoError(t, err)
+		defer bundle.RemoveStagingDir(stagingDir)
+
+		originalImagesLock, err := os.ReadFile(filepath.Join(configDir, ".imgpkg", "images.yml"))
+		require.NoError(t, err)
+		assert.Equal(t, emptyImagesLockYAML, string(originalImagesLock))
+
+		stagedImagesLock, err := lockconfig.NewImagesLockFromPath(filepath.Join(stagingDir, "0", ".imgpkg", "images.yml"))
+		require.NoError(t, err)
+		assert.Equal(t, appImg.RefDigest, stagedImagesLock.Images[0].Image)
+		assert.FileExists(t, filepath.Join(stagingDir, "0", "deployment.yml"))
+
+		isBundle, err := stagedContents.PresentsAsBundle()
+		require.NoError(t, err)
+		assert.True(t, isBundle)
+	})
+	t.Run("staging read-only folders keeps their permissions and they can be removed afterwards", func(t *testing.T) {
+		readOnlyDir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(readOnlyDir, ".imgpkg"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(readOnlyDir, ".imgpkg", "images.yml"), []byte(emptyImagesLockYAML), 0400))
+		require.NoError(t, os.MkdirAll(filepath.Join(readOnlyDir, "config"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(readOnlyDir, "config", "pod.yml"), []byte("image: "+appImg.RefDigest), 0400))
+		for _, dir := range []string{"config", ".imgpkg", "."} {
+			require.NoError(t, os.Chmod(filepath.Join(readOnlyDir, dir), 0555))
+		}
+		t.Cleanup(func() {
+			for _, dir := range []string{".", "config", ".imgpkg"} {
+				os.Chmod(filepath.Join(readOnlyDir, dir), 0700)
+			}
+		})
+
+		imagesLock := lockconfig.NewEmptyImagesLock()
+		imagesLock.AddImageRef(lockconfig.ImageRef{Image: appImg.RefDigest})
+
+		contents := bundle.NewContents([]string{readOnlyDir}, nil, true)
+		_, stagingDir, err := contents.StageWithImagesLock(imagesLock, util.NewNoopLevelLogger())
+		require.NoError(t, err)
+
+		for _, dir := range []string{"config", ".imgpkg", "."} {
+			info, err := os.Stat(filepath.Join(stagingDir, "0", dir))
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0555), info.Mode().Perm(), dir)
+		}
+		stagedImagesLock, err := lockconfig.NewImagesLockFromPath(filepath.Join(stagingDir, "0", ".imgpkg", "images.yml"))
+		require.NoError(t, err)
+		assert.Equal(t, appImg.RefDigest, stagedImagesLock.Images[0].Image)
+
+		require.NoError(t, bundle.RemoveStagingDir(stagingDir))
+		assert.NoDirExists(t, stagingDir)
+	})
+}
+
+const emptyImagesLockYAML = `---
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: ImagesLock
+`
@@ -159,6 +159,44 @@ func (i *ImageRefs) UpdateRelativeToRepo(imgRetriever ImagesMetadata, relativeTo
 	return true, nil
 }
 
+// UpdateWithImageMap adds the location produced by the image map to each image, failing when any mapped
+// location does not exist. Returns the images that did not match any mapping
+func (i *ImageRefs) UpdateWithImageMap(imgRetriever ImagesMetadata, imageMap lockconfig.ImageMap) ([]string, error) {
+	i.refsLock.Lock()
+	defer i.refsLock.Unlock()
+
+	var unmappedImages []string
+	var errs []string
+	for j, ref := range i.refs {
+		mappedImage, found, err := imageMap.Map(ref.Image)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			unmappedImages = append(unmappedImages, ref.Image)
+			continue
+		}
+
+		mappedRef, err := name.NewDigest(mappedImage)
+		if err != nil {
+			return nil, fmt.Errorf("Mapping image '%s': %s", ref.Image, err)
+		}
+		_, err = imgRetriever.Digest(mappedRef)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("- Image '%s' mapped to '%s' could not be found: %s", ref.Image, mappedImage, err))
+			continue
+		}
+
+		i.refs[j].AddLocation(mappedImage)
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("Mapping images using the image map:\n%s", strings.Join(errs, "\n"))
+	}
+
+	return unmappedImages, nil
+}
+
 func (i *ImageRefs) AddImagesRef(refs ...ImageRef) {
 	i.refsLock.Lock()
 	defer i.refsLock.Unlock()
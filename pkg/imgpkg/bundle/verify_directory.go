@@ -0,0 +1,78 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	ctlimg "carvel.dev/imgpkg/pkg/imgpkg/image"
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+// VerifyDirectory compares a directory where the bundle was previously pulled against the contents of the bundle,
+// without extracting it. Nested bundles and the images OCI Image Layout written by pull are not compared
+func (o *Bundle) VerifyDirectory(outputPath string, logger Logger, extractOpts ctlimg.ExtractOpts) (ctlimg.DirDiff, error) {
+	img, err := o.checkedImage()
+	if err != nil {
+		return ctlimg.DirDiff{}, err
+	}
+
+	logger.Logf("Verifying bundle '%s'\n", o.DigestRef())
+
+	extractOpts.PathFilter = extractOpts.PathFilter.WithAlwaysIncluded(ImgpkgDir)
+	ignoredPaths := []string{path.Join(ImgpkgDir, BundlesDir)}
+	if _, err := os.Stat(filepath.Join(outputPath, ImagesLayoutDir, "oci-layout")); err == nil {
+		ignoredPaths = append(ignoredPaths, ImagesLayoutDir)
+	}
+
+	diff, err := ctlimg.NewDirImageWithOpts(outputPath, img, util.NewIndentedLevelLogger(logger), extractOpts).Compare(ignoredPaths...)
+	if err != nil {
+		return ctlimg.DirDiff{}, err
+	}
+
+	// pull rewrites the ImagesLock when the images were relocated,
+	// so it is only considered modified when the images digests changed
+	imagesLockPath := path.Join(ImgpkgDir, ImagesLockFile)
+	for idx, modifiedFile := range diff.Modified {
+		if modifiedFile != imagesLockPath {
+			continue
+		}
+
+		bundleImagesLock, err := o.imagesLockReader.Read(img)
+		if err != nil {
+			return ctlimg.DirDiff{}, err
+		}
+		localImagesLock, err := lockconfig.NewImagesLockFromPath(filepath.Join(outputPath, ImgpkgDir, ImagesLockFile))
+		if err == nil && sameImageDigests(bundleImagesLock, localImagesLock) {
+			diff.Modified = append(diff.Modified[:idx], diff.Modified[idx+1:]...)
+		}
+		break
+	}
+
+	return diff, nil
+}
+
+func sameImageDigests(a, b lockconfig.ImagesLock) bool {
+	if len(a.Images) != len(b.Images) {
+		return false
+	}
+	for idx := range a.Images {
+		aDigest, err := regname.NewDigest(a.Images[idx].Image)
+		if err != nil {
+			return false
+		}
+		bDigest, err := regname.NewDigest(b.Images[idx].Image)
+		if err != nil {
+			return false
+		}
+		if aDigest.DigestStr() != bDigest.DigestStr() {
+			return false
+		}
+	}
+	return true
+}
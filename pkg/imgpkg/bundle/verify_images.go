@@ -0,0 +1,90 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+// VerifyImages checks that every image present in the ImagesLock of the bundle exists in the registry
+// and that the digest it resolves to matches the one recorded in the ImagesLock.
+// Returns all the images with the information about them being a bundle or not
+func (b Contents) VerifyImages(imgRetriever ImagesMetadata, logger Logger) ([]ImageRef, error) {
+	imagesLockPath, err := b.imagesLockPath()
+	if err != nil {
+		return nil, err
+	}
+
+	imagesLock, err := lockconfig.NewImagesLockFromPath(imagesLockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Logf("Verifying %d image(s) referenced in %s\n", len(imagesLock.Images), filepath.Join(ImgpkgDir, ImagesLockFile))
+
+	imagesLockReader := NewImagesLockReader()
+	bundleFetcher := NewRegistryFetcher(imgRetriever, imagesLockReader)
+
+	var verifiedImages []ImageRef
+	var verificationErrs []string
+	for _, imgRef := range imagesLock.Images {
+		digestRef, err := regname.NewDigest(imgRef.Image)
+		if err != nil {
+			panic(fmt.Sprintf("Internal inconsistency: Image '%s' is not a valid Digest Reference", imgRef.Image))
+		}
+
+		digest, err := imgRetriever.Digest(digestRef)
+		if err != nil {
+			verificationErrs = append(verificationErrs, fmt.Sprintf("Image '%s' could not be found: %s", imgRef.Image, err))
+			continue
+		}
+
+		if digest.String() != digestRef.DigestStr() {
+			verificationErrs = append(verificationErrs, fmt.Sprintf("Image '%s' resolved to unexpected digest '%s'", imgRef.Image, digest))
+			continue
+		}
+
+		isBundle, err := NewBundleFromRef(imgRef.Image, imgRetriever, imagesLockReader, bundleFetcher).IsBundle()
+		if err != nil {
+			verificationErrs = append(verificationErrs, fmt.Sprintf("Checking if image '%s' is a bundle: %s", imgRef.Image, err))
+			continue
+		}
+
+		var verifiedImage ImageRef
+		if isBundle {
+			verifiedImage = NewBundleImageRef(imgRef)
+			logger.Debugf("Image '%s' is a bundle\n", imgRef.Image)
+		} else {
+			verifiedImage = NewContentImageRef(imgRef)
+			logger.Debugf("Image '%s' is an image\n", imgRef.Image)
+		}
+		verifiedImages = append(verifiedImages, verifiedImage)
+	}
+
+	if len(verificationErrs) > 0 {
+		return nil, fmt.Errorf("Verifying images in %s:\n- %s", filepath.Join(ImgpkgDir, ImagesLockFile), strings.Join(verificationErrs, "\n- "))
+	}
+
+	return verifiedImages, nil
+}
+
+// imagesLockPath retrieves the path to the ImagesLock file of the bundle
+func (b Contents) imagesLockPath() (string, error) {
+	imgpkgDirs, err := b.findImgpkgDirs()
+	if err != nil {
+		return "", err
+	}
+
+	err = b.validateImgpkgDirs(imgpkgDirs)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(imgpkgDirs[0], ImagesLockFile), nil
+}
@@ -0,0 +1,339 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cache provides a content addressable on-disk cache of image blobs shared between imgpkg invocations
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/imageutils/verify"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Cache stores blobs in dir indexed by their digest. Every blob is verified against its digest when read,
+// entries that fail the verification are removed
+type Cache struct {
+	dir     string
+	maxSize int64
+	now     func() time.Time
+
+	lock sync.Mutex
+	// lru blobs in the cache, most recently used first, loaded from dir the first time a blob is added
+	// so that the cache is listed once and not every time a blob is added
+	lru      *list.List
+	lruElems map[regv1.Hash]*list.Element
+	size     int64
+}
+
+// Entry blob present in the cache
+type Entry struct {
+	Digest   regv1.Hash
+	Size     int64
+	LastUsed time.Time
+}
+
+// errNotFound returned by open when the blob is not present in the cache
+var errNotFound = errors.New("blob not found in cache")
+
+// NewCache creates a cache that stores blobs in dir. When maxSize is greater than 0, the least recently used blobs
+// are removed when a blob is added to keep the cache under maxSize bytes
+func NewCache(dir string, maxSize int64) *Cache {
+	return &Cache{dir: dir, maxSize: maxSize, now: time.Now}
+}
+
+// Dir returns the directory where the blobs are stored
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Entries returns all the blobs present in the cache, most recently used first
+func (c *Cache) Entries() ([]Entry, error) {
+	var result []Entry
+
+	blobsDir := filepath.Join(c.dir, "blobs")
+	err := filepath.WalkDir(blobsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == blobsDir {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(blobsDir, path)
+		if err != nil {
+			return err
+		}
+		digest, err := regv1.NewHash(strings.Replace(filepath.ToSlash(rel), "/", ":", 1))
+		if err != nil {
+			// Not a blob, ignore it
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		result = append(result, Entry{Digest: digest, Size: info.Size(), LastUsed: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Listing cache directory '%s': %s", c.dir, err)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastUsed.After(result[j].LastUsed)
+	})
+	return result, nil
+}
+
+// Prune removes the least recently used blobs until the cache is at most maxSize bytes,
+// as well as partial downloads left behind by interrupted invocations. Returns the removed blobs
+func (c *Cache) Prune(maxSize int64) ([]Entry, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Blobs removed by Prune are not tracked, the cache is listed again when the next blob is added
+	c.lru = nil
+
+	entries, err := c.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	var removed []Entry
+	full := false
+	for _, entry := range entries {
+		if !full && total+entry.Size <= maxSize {
+			total += entry.Size
+			continue
+		}
+		full = true
+		if err := os.Remove(c.blobPath(entry.Digest)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, fmt.Errorf("Removing blob '%s' from cache: %s", entry.Digest, err)
+		}
+		removed = append(removed, entry)
+	}
+
+	c.removePartialDownloads()
+	return removed, nil
+}
+
+// removePartialDownloads removes the partial downloads that are not being written by a running invocation
+func (c *Cache) removePartialDownloads() {
+	tmpFiles, _ := os.ReadDir(filepath.Join(c.dir, "tmp"))
+	for _, tmpFile := range tmpFiles {
+		if info, err := tmpFile.Info(); err == nil && c.now().Sub(info.ModTime()) > time.Hour {
+			os.Remove(filepath.Join(c.dir, "tmp", tmpFile.Name()))
+		}
+	}
+}
+
+// added records that entry was added to the cache and removes the least recently used blobs
+// until the cache is at most maxSize bytes
+func (c *Cache) added(entry Entry) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.lru == nil {
+		entries, err := c.Entries()
+		if err != nil {
+			return err
+		}
+		c.lru, c.lruElems, c.size = list.New(), map[regv1.Hash]*list.Element{}, 0
+		for _, existing := range entries {
+			c.lruElems[existing.Digest] = c.lru.PushBack(existing)
+			c.size += existing.Size
+		}
+		c.removePartialDownloads()
+	}
+
+	if elem, found := c.lruElems[entry.Digest]; found {
+		c.size -= elem.Value.(Entry).Size
+		c.lru.Remove(elem)
+	}
+	c.lruElems[entry.Digest] = c.lru.PushFront(entry)
+	c.size += entry.Size
+
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		oldest := c.lru.Remove(c.lru.Back()).(Entry)
+		delete(c.lruElems, oldest.Digest)
+		c.size -= oldest.Size
+		if err := os.Remove(c.blobPath(oldest.Digest)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("Removing blob '%s' from cache: %s", oldest.Digest, err)
+		}
+	}
+	return nil
+}
+
+// used records that the blob was read so that it is the last one to be removed
+func (c *Cache) used(digest regv1.Hash, lastUsed time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, found := c.lruElems[digest]; found {
+		entry := elem.Value.(Entry)
+		entry.LastUsed = lastUsed
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+	}
+}
+
+// open returns the verified content of the blob, errNotFound when the blob is not in the cache
+func (c *Cache) open(digest regv1.Hash, size int64) (io.ReadCloser, error) {
+	path := c.blobPath(digest)
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errNotFound
+		}
+		return nil, err
+	}
+
+	// Keep track of the last time the blob was used so that prune removes the least recently used blobs
+	now := c.now()
+	os.Chtimes(path, now, now)
+	c.used(digest, now)
+
+	verified, err := verify.ReadCloser(file, size, digest)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &removeOnErrorReader{ReadCloser: verified, path: path}, nil
+}
+
+// store returns a reader of content that adds it to the cache once it is fully read and matches digest
+func (c *Cache) store(digest regv1.Hash, size int64, content io.ReadCloser) (io.ReadCloser, error) {
+	verified, err := verify.ReadCloser(content, size, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir := filepath.Join(c.dir, "tmp")
+	if err := os.MkdirAll(tmpDir, 0700); err != nil {
+		// Failing to cache the blob should not fail the read
+		return verified, nil
+	}
+	tmpFile, err := os.CreateTemp(tmpDir, digest.Hex+"-*")
+	if err != nil {
+		return verified, nil
+	}
+
+	return &storeReader{
+		ReadCloser: verified,
+		tmpFile:    tmpFile,
+		commit: func() error {
+			path := c.blobPath(digest)
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return err
+			}
+			if err := os.Rename(tmpFile.Name(), path); err != nil {
+				return err
+			}
+			if c.maxSize > 0 {
+				info, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				return c.added(Entry{Digest: digest, Size: info.Size(), LastUsed: info.ModTime()})
+			}
+			return nil
+		},
+	}, nil
+}
+
+func (c *Cache) blobPath(digest regv1.Hash) string {
+	return filepath.Join(c.dir, "blobs", digest.Algorithm, digest.Hex)
+}
+
+// removeOnErrorReader removes the cached blob when it fails the verification
+type removeOnErrorReader struct {
+	io.ReadCloser
+	path string
+}
+
+func (r *removeOnErrorReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		os.Remove(r.path)
+	}
+	return n, err
+}
+
+// storeReader copies everything that is read to tmpFile and commits it to the cache once
+// the content is fully read and verified. Content that is not fully read is discarded
+type storeReader struct {
+	io.ReadCloser
+	tmpFile *os.File
+	commit  func() error
+	failed  bool
+	done    bool
+}
+
+func (r *storeReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if !r.failed && n > 0 {
+		if _, writeErr := r.tmpFile.Write(p[:n]); writeErr != nil {
+			r.failed = true
+		}
+	}
+	if err == io.EOF {
+		r.done = true
+	} else if err != nil {
+		r.failed = true
+	}
+	return n, err
+}
+
+func (r *storeReader) Close() error {
+	err := r.ReadCloser.Close()
+
+	closeErr := r.tmpFile.Close()
+	if r.done && !r.failed && closeErr == nil {
+		// Failing to cache the blob should not fail the read
+		_ = r.commit()
+	}
+	os.Remove(r.tmpFile.Name())
+	return err
+}
+
+// ParseSize parses sizes in bytes with an optional unit, i.e. 1024, 500MB, 10GiB
+func ParseSize(value string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000}, {"TB", 1000 * 1000 * 1000 * 1000},
+		{"B", 1},
+	}
+
+	number, multiplier := strings.TrimSpace(value), int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.multiplier
+			break
+		}
+	}
+
+	size, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("Expected size to be a positive number optionally followed by a unit (B, KB, MB, GB, TB, KiB, MiB, GiB, TiB), got '%s'", value)
+	}
+	return size * multiplier, nil
+}
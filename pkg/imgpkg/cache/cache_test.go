@@ -0,0 +1,249 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cache_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/cache"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_Image(t *testing.T) {
+	t.Run("when a layer is read, it is cached and read from the cache afterwards", func(t *testing.T) {
+		subject := cache.NewCache(t.TempDir(), 0)
+		img, counter := newCountingImage(t)
+
+		layers, err := subject.Image(img).Layers()
+		require.NoError(t, err)
+		expected := readAll(t, layers[0])
+
+		layers, err = subject.Image(img).Layers()
+		require.NoError(t, err)
+		assert.Equal(t, expected, readAll(t, layers[0]))
+		assert.Equal(t, 1, *counter)
+
+		entries, err := subject.Entries()
+		require.NoError(t, err)
+		digest, err := layers[0].Digest()
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, digest, entries[0].Digest)
+	})
+
+	t.Run("when a layer is not fully read, it is not cached", func(t *testing.T) {
+		subject := cache.NewCache(t.TempDir(), 0)
+		img, _ := newCountingImage(t)
+
+		layers, err := subject.Image(img).Layers()
+		require.NoError(t, err)
+		content, err := layers[0].Compressed()
+		require.NoError(t, err)
+		_, err = content.Read(make([]byte, 10))
+		require.NoError(t, err)
+		require.NoError(t, content.Close())
+
+		entries, err := subject.Entries()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("when a cached layer is corrupted, it fails the verification and is removed from the cache", func(t *testing.T) {
+		dir := t.TempDir()
+		subject := cache.NewCache(dir, 0)
+		img, _ := newCountingImage(t)
+
+		layers, err := subject.Image(img).Layers()
+		require.NoError(t, err)
+		expected := readAll(t, layers[0])
+
+		digest, err := layers[0].Digest()
+		require.NoError(t, err)
+		corrupted := append([]byte{}, expected...)
+		corrupted[0]++
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "blobs", digest.Algorithm, digest.Hex), corrupted, 0600))
+
+		content, err := layers[0].Compressed()
+		require.NoError(t, err)
+		_, err = io.ReadAll(content)
+		require.ErrorContains(t, err, "error verifying sha256 checksum")
+
+		entries, err := subject.Entries()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("when the image configuration is read, it is cached", func(t *testing.T) {
+		subject := cache.NewCache(t.TempDir(), 0)
+		img, _ := newCountingImage(t)
+
+		expected, err := img.RawConfigFile()
+		require.NoError(t, err)
+		config, err := subject.Image(img).RawConfigFile()
+		require.NoError(t, err)
+		assert.Equal(t, expected, config)
+
+		configName, err := img.ConfigName()
+		require.NoError(t, err)
+		entries, err := subject.Entries()
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, configName, entries[0].Digest)
+	})
+}
+
+func TestCache_Prune(t *testing.T) {
+	t.Run("removes the least recently used blobs until the cache fits in the max size", func(t *testing.T) {
+		dir := t.TempDir()
+		subject := cache.NewCache(dir, 0)
+
+		var digests []regv1.Hash
+		var sizes []int64
+		for i := 0; i < 3; i++ {
+			img, _ := newCountingImage(t)
+			layers, err := subject.Image(img).Layers()
+			require.NoError(t, err)
+			readAll(t, layers[0])
+
+			digest, err := layers[0].Digest()
+			require.NoError(t, err)
+			digests = append(digests, digest)
+			size, err := layers[0].Size()
+			require.NoError(t, err)
+			sizes = append(sizes, size)
+
+			lastUsed := time.Now().Add(time.Duration(i-3) * time.Hour)
+			require.NoError(t, os.Chtimes(filepath.Join(dir, "blobs", digest.Algorithm, digest.Hex), lastUsed, lastUsed))
+		}
+
+		removed, err := subject.Prune(sizes[1] + sizes[2])
+		require.NoError(t, err)
+		require.Len(t, removed, 1)
+		assert.Equal(t, digests[0], removed[0].Digest)
+
+		entries, err := subject.Entries()
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+
+	t.Run("when the cache has a max size, it prunes the cache when adding blobs", func(t *testing.T) {
+		subject := cache.NewCache(t.TempDir(), 1)
+		img, _ := newCountingImage(t)
+
+		layers, err := subject.Image(img).Layers()
+		require.NoError(t, err)
+		readAll(t, layers[0])
+
+		entries, err := subject.Entries()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("when adding blobs exceeds the max size, it removes the least recently used blobs", func(t *testing.T) {
+		var imgs []regv1.Image
+		var digests []regv1.Hash
+		var sizes []int64
+		for i := 0; i < 3; i++ {
+			img, _ := newCountingImage(t)
+			layers, err := img.Layers()
+			require.NoError(t, err)
+			digest, err := layers[0].Digest()
+			require.NoError(t, err)
+			size, err := layers[0].Size()
+			require.NoError(t, err)
+			imgs = append(imgs, img)
+			digests = append(digests, digest)
+			sizes = append(sizes, size)
+		}
+
+		subject := cache.NewCache(t.TempDir(), sizes[0]+max(sizes[1], sizes[2]))
+		read := func(img regv1.Image) {
+			layers, err := subject.Image(img).Layers()
+			require.NoError(t, err)
+			readAll(t, layers[0])
+		}
+		read(imgs[0])
+		read(imgs[1])
+		// reading the first blob from the cache makes the second one the least recently used
+		read(imgs[0])
+		read(imgs[2])
+
+		entries, err := subject.Entries()
+		require.NoError(t, err)
+		var cached []regv1.Hash
+		for _, entry := range entries {
+			cached = append(cached, entry.Digest)
+		}
+		assert.ElementsMatch(t, []regv1.Hash{digests[0], digests[2]}, cached)
+	})
+
+	t.Run("when the cache directory does not exist, it does nothing", func(t *testing.T) {
+		removed, err := cache.NewCache(filepath.Join(t.TempDir(), "does-not-exist"), 0).Prune(0)
+		require.NoError(t, err)
+		assert.Empty(t, removed)
+	})
+}
+
+func TestParseSize(t *testing.T) {
+	for value, expected := range map[string]int64{"1024": 1024, "10B": 10, "500MB": 500 * 1000 * 1000, "10GiB": 10 << 30, "2 KiB": 2048} {
+		size, err := cache.ParseSize(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, size, value)
+	}
+
+	_, err := cache.ParseSize("10XB")
+	require.ErrorContains(t, err, "Expected size to be a positive number optionally followed by a unit")
+}
+
+// countingImage counts the number of times the content of its layers is read
+type countingImage struct {
+	regv1.Image
+	counter *int
+}
+
+func (i countingImage) Layers() ([]regv1.Layer, error) {
+	layers, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	var result []regv1.Layer
+	for _, layer := range layers {
+		result = append(result, countingLayer{Layer: layer, counter: i.counter})
+	}
+	return result, nil
+}
+
+type countingLayer struct {
+	regv1.Layer
+	counter *int
+}
+
+func (l countingLayer) Compressed() (io.ReadCloser, error) {
+	*l.counter++
+	return l.Layer.Compressed()
+}
+
+func newCountingImage(t *testing.T) (regv1.Image, *int) {
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	counter := 0
+	return countingImage{Image: img, counter: &counter}, &counter
+}
+
+func readAll(t *testing.T, layer regv1.Layer) []byte {
+	content, err := layer.Compressed()
+	require.NoError(t, err)
+	defer content.Close()
+
+	bs, err := io.ReadAll(content)
+	require.NoError(t, err)
+	return bs
+}
@@ -0,0 +1,189 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	regremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Image wraps img so that its layers and configuration are read from the cache when present,
+// and added to the cache when read from img
+func (c *Cache) Image(img regv1.Image) regv1.Image {
+	return &cachedImage{Image: img, cache: c}
+}
+
+// Index wraps idx so that the images it contains read their layers and configuration from the cache
+func (c *Cache) Index(idx regv1.ImageIndex) regv1.ImageIndex {
+	return &cachedIndex{inner: idx, cache: c}
+}
+
+type cachedImage struct {
+	regv1.Image
+	cache *Cache
+}
+
+// Layers returns the layers of the image backed by the cache
+func (i *cachedImage) Layers() ([]regv1.Layer, error) {
+	layers, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []regv1.Layer
+	for _, layer := range layers {
+		result = append(result, i.cache.layer(layer))
+	}
+	return result, nil
+}
+
+// LayerByDigest returns the layer backed by the cache
+func (i *cachedImage) LayerByDigest(digest regv1.Hash) (regv1.Layer, error) {
+	layer, err := i.Image.LayerByDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	return i.cache.layer(layer), nil
+}
+
+// LayerByDiffID returns the layer backed by the cache
+func (i *cachedImage) LayerByDiffID(diffID regv1.Hash) (regv1.Layer, error) {
+	layer, err := i.Image.LayerByDiffID(diffID)
+	if err != nil {
+		return nil, err
+	}
+	return i.cache.layer(layer), nil
+}
+
+// RawConfigFile returns the configuration of the image from the cache when present
+func (i *cachedImage) RawConfigFile() ([]byte, error) {
+	manifest, err := i.Image.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := i.cache.open(manifest.Config.Digest, manifest.Config.Size)
+	if errors.Is(err, errNotFound) {
+		config, err := i.Image.RawConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		content, err = i.cache.store(manifest.Config.Digest, manifest.Config.Size, io.NopCloser(bytes.NewReader(config)))
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	return io.ReadAll(content)
+}
+
+// ConfigFile parses the configuration returned by RawConfigFile
+func (i *cachedImage) ConfigFile() (*regv1.ConfigFile, error) {
+	return partial.ConfigFile(i)
+}
+
+type cachedIndex struct {
+	inner regv1.ImageIndex
+	cache *Cache
+}
+
+func (i *cachedIndex) MediaType() (types.MediaType, error)          { return i.inner.MediaType() }
+func (i *cachedIndex) Digest() (regv1.Hash, error)                  { return i.inner.Digest() }
+func (i *cachedIndex) Size() (int64, error)                         { return i.inner.Size() }
+func (i *cachedIndex) IndexManifest() (*regv1.IndexManifest, error) { return i.inner.IndexManifest() }
+func (i *cachedIndex) RawManifest() ([]byte, error)                 { return i.inner.RawManifest() }
+
+// Image returns the child image backed by the cache
+func (i *cachedIndex) Image(digest regv1.Hash) (regv1.Image, error) {
+	img, err := i.inner.Image(digest)
+	if err != nil {
+		return nil, err
+	}
+	return i.cache.Image(img), nil
+}
+
+// ImageIndex returns the child index backed by the cache
+func (i *cachedIndex) ImageIndex(digest regv1.Hash) (regv1.ImageIndex, error) {
+	idx, err := i.inner.ImageIndex(digest)
+	if err != nil {
+		return nil, err
+	}
+	return i.cache.Index(idx), nil
+}
+
+// layer wraps layer, layers that can be mounted from another repository continue to be mountable
+func (c *Cache) layer(layer regv1.Layer) regv1.Layer {
+	if mountable, ok := layer.(*regremote.MountableLayer); ok {
+		return &regremote.MountableLayer{Layer: &cachedLayer{Layer: mountable.Layer, cache: c}, Reference: mountable.Reference}
+	}
+	return &cachedLayer{Layer: layer, cache: c}
+}
+
+type cachedLayer struct {
+	regv1.Layer
+	cache *Cache
+}
+
+// Compressed returns the content of the layer from the cache when present,
+// otherwise reads it from the layer and adds it to the cache
+func (l *cachedLayer) Compressed() (io.ReadCloser, error) {
+	digest, err := l.Layer.Digest()
+	if err != nil {
+		return nil, err
+	}
+	size, err := l.Layer.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := l.cache.open(digest, size)
+	if !errors.Is(err, errNotFound) {
+		return content, err
+	}
+
+	content, err = l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	return l.cache.store(digest, size, content)
+}
+
+// Uncompressed decompresses the content returned by Compressed
+func (l *cachedLayer) Uncompressed() (io.ReadCloser, error) {
+	layer, err := partial.CompressedToLayer(compressedLayer{l})
+	if err != nil {
+		return nil, err
+	}
+	return layer.Uncompressed()
+}
+
+// Descriptor keeps the original descriptor, i.e. the URLs of non-distributable layers
+func (l *cachedLayer) Descriptor() (*regv1.Descriptor, error) {
+	return partial.Descriptor(l.Layer)
+}
+
+// Exists keeps the ability of the layer to report whether it exists in the registry
+func (l *cachedLayer) Exists() (bool, error) {
+	return partial.Exists(l.Layer)
+}
+
+// compressedLayer exposes only the methods of cachedLayer needed to decompress it
+type compressedLayer struct {
+	layer *cachedLayer
+}
+
+func (c compressedLayer) Digest() (regv1.Hash, error)         { return c.layer.Digest() }
+func (c compressedLayer) Compressed() (io.ReadCloser, error)  { return c.layer.Compressed() }
+func (c compressedLayer) Size() (int64, error)                { return c.layer.Size() }
+func (c compressedLayer) MediaType() (types.MediaType, error) { return c.layer.MediaType() }
+func (c compressedLayer) DiffID() (regv1.Hash, error)         { return c.layer.DiffID() }
@@ -0,0 +1,89 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/spf13/cobra"
+)
+
+// BundleLockOptions Command Line options that can be provided to the bundle lock command
+type BundleLockOptions struct {
+	ui ui.UI
+
+	BundleDir     string
+	RegistryFlags RegistryFlags
+}
+
+// NewBundleLockOptions constructor for building a BundleLockOptions, holding values derived via flags
+func NewBundleLockOptions(ui ui.UI) *BundleLockOptions {
+	return &BundleLockOptions{ui: ui}
+}
+
+// NewBundleLockCmd constructor for the bundle lock command
+func NewBundleLockCmd(o *BundleLockOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Resolve the nested bundles declared in .imgpkg/bundle-deps.yml and pin them in .imgpkg/images.yml",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+		Example: `
+  # Resolve the nested bundles of the bundle in the my-bundle directory
+  imgpkg bundle lock -f my-bundle`,
+	}
+	cmd.Flags().StringVarP(&o.BundleDir, "file", "f", ".", "Bundle directory, containing the .imgpkg directory")
+	o.RegistryFlags.Set(cmd)
+	return cmd
+}
+
+// Run functions called when the bundle lock command is provided in the command line
+func (o *BundleLockOptions) Run() error {
+	imgpkgDir, err := bundleImgpkgDir(o.BundleDir)
+	if err != nil {
+		return err
+	}
+
+	deps, err := lockconfig.NewBundleDepsFromPath(filepath.Join(imgpkgDir, bundle.BundleDepsFile))
+	if err != nil {
+		return err
+	}
+
+	imagesLockPath := filepath.Join(imgpkgDir, bundle.ImagesLockFile)
+	imagesLock := lockconfig.NewEmptyImagesLock()
+	if _, err := os.Stat(imagesLockPath); err == nil {
+		imagesLock, err = lockconfig.NewImagesLockFromPath(imagesLockPath)
+		if err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	reg, err := registry.NewSimpleRegistry(o.RegistryFlags.AsRegistryOpts(o.ui))
+	if err != nil {
+		return err
+	}
+
+	logger := util.NewUILevelLogger(util.LogWarn, util.NewLogger(o.ui))
+	resolvedRefs, err := bundle.NewBundleDepsResolver(reg, reg, logger).Resolve(deps)
+	if err != nil {
+		return err
+	}
+
+	err = bundle.LockBundleDeps(imagesLock, resolvedRefs).WriteToPath(imagesLockPath)
+	if err != nil {
+		return err
+	}
+
+	o.ui.BeginLinef("Updated '%s'\n", imagesLockPath)
+
+	return nil
+}
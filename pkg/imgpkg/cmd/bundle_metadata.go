@@ -0,0 +1,231 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	v1 "carvel.dev/imgpkg/pkg/imgpkg/v1"
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/spf13/cobra"
+)
+
+func NewBundleMetadataCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "metadata",
+		Short: "Edit the metadata (.imgpkg/bundle.yml) of a bundle directory",
+	}
+	return cmd
+}
+
+// BundleMetadataSetOptions Command Line options that can be provided to the bundle metadata set command
+type BundleMetadataSetOptions struct {
+	ui ui.UI
+
+	BundleDir      string
+	Metadata       []string
+	RemoveMetadata []string
+	Authors        []string
+	Websites       []string
+	Force          bool
+}
+
+// NewBundleMetadataSetOptions constructor for building a BundleMetadataSetOptions, holding values derived via flags
+func NewBundleMetadataSetOptions(ui ui.UI) *BundleMetadataSetOptions {
+	return &BundleMetadataSetOptions{ui: ui}
+}
+
+// NewBundleMetadataSetCmd constructor for the bundle metadata set command
+func NewBundleMetadataSetCmd(o *BundleMetadataSetOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "Set values in the metadata of a bundle directory",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+		Example: `
+  # Set the version of the bundle in the my-bundle directory
+  imgpkg bundle metadata set -f my-bundle --metadata version=1.2.0
+
+  # Replace the authors and websites of the bundle
+  imgpkg bundle metadata set -f my-bundle --author "Carvel Team <carvel@vmware.com>" --website carvel.dev/imgpkg`,
+	}
+	cmd.Flags().StringVarP(&o.BundleDir, "file", "f", ".", "Bundle directory, containing the .imgpkg directory")
+	cmd.Flags().StringSliceVar(&o.Metadata, "metadata", nil, "Set metadata value (format: key=value) (can be specified multiple times)")
+	cmd.Flags().StringSliceVar(&o.RemoveMetadata, "remove-metadata", nil, "Remove metadata key (can be specified multiple times)")
+	cmd.Flags().StringSliceVar(&o.Authors, "author", nil, "Replace the authors (format: 'Name <email>') (can be specified multiple times)")
+	cmd.Flags().StringSliceVar(&o.Websites, "website", nil, "Replace the websites (format: carvel.dev/imgpkg) (can be specified multiple times)")
+	cmd.Flags().BoolVar(&o.Force, "force", false, "Rewrite the metadata even when it contains unknown fields, removing them")
+	return cmd
+}
+
+// Run functions called when the bundle metadata set command is provided in the command line
+func (o *BundleMetadataSetOptions) Run() error {
+	logger := util.NewUILevelLogger(util.LogWarn, util.NewLogger(o.ui))
+	metadataPath, err := bundleMetadataPath(o.BundleDir)
+	if err != nil {
+		return err
+	}
+
+	metadata := v1.Metadata{}
+	if _, err := os.Stat(metadataPath); err == nil {
+		var warnings []string
+		metadata, warnings, err = v1.ReadMetadataFile(metadataPath, false)
+		if err != nil {
+			return err
+		}
+		if len(warnings) > 0 && !o.Force {
+			return fmt.Errorf("Expected '%s' to only contain known fields, rewriting it would remove the others:\n- %s\n(hint: use --force to rewrite it anyway)",
+				metadataPath, strings.Join(warnings, "\n- "))
+		}
+		for _, warning := range warnings {
+			logger.Warnf("%s\n", warning)
+		}
+	}
+
+	for _, keyValue := range o.Metadata {
+		pieces := strings.SplitN(keyValue, "=", 2)
+		if len(pieces) != 2 || pieces[0] == "" {
+			return fmt.Errorf("Expected metadata '%s' to be in format key=value", keyValue)
+		}
+		metadata.Set(pieces[0], pieces[1])
+	}
+
+	for _, key := range o.RemoveMetadata {
+		metadata.Remove(key)
+	}
+
+	if len(o.Authors) > 0 {
+		metadata.Authors = nil
+		for _, author := range o.Authors {
+			metadata.Authors = append(metadata.Authors, parseBundleAuthor(author))
+		}
+	}
+
+	if len(o.Websites) > 0 {
+		metadata.Websites = nil
+		for _, website := range o.Websites {
+			metadata.Websites = append(metadata.Websites, v1.Website{URL: website})
+		}
+	}
+
+	err = metadata.Validate(nil)
+	if err != nil {
+		return err
+	}
+
+	err = metadata.WriteToPath(metadataPath)
+	if err != nil {
+		return err
+	}
+
+	o.ui.BeginLinef("Updated '%s'\n", metadataPath)
+
+	return nil
+}
+
+// parseBundleAuthor converts 'Name <email>' into an author, when not in that format the value is used as the name
+func parseBundleAuthor(author string) v1.Author {
+	addr, err := mail.ParseAddress(author)
+	if err != nil {
+		return v1.Author{Name: author}
+	}
+	return v1.Author{Name: addr.Name, Email: addr.Address}
+}
+
+// BundleMetadataGetOptions Command Line options that can be provided to the bundle metadata get command
+type BundleMetadataGetOptions struct {
+	ui ui.UI
+
+	BundleDir string
+	Key       string
+}
+
+// NewBundleMetadataGetOptions constructor for building a BundleMetadataGetOptions, holding values derived via flags
+func NewBundleMetadataGetOptions(ui ui.UI) *BundleMetadataGetOptions {
+	return &BundleMetadataGetOptions{ui: ui}
+}
+
+// NewBundleMetadataGetCmd constructor for the bundle metadata get command
+func NewBundleMetadataGetCmd(o *BundleMetadataGetOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Get the metadata of a bundle directory",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+		Example: `
+  # Print the metadata of the bundle in the my-bundle directory
+  imgpkg bundle metadata get -f my-bundle
+
+  # Print the version of the bundle
+  imgpkg bundle metadata get -f my-bundle --key version`,
+	}
+	cmd.Flags().StringVarP(&o.BundleDir, "file", "f", ".", "Bundle directory, containing the .imgpkg directory")
+	cmd.Flags().StringVar(&o.Key, "key", "", "Only print the value of this metadata key")
+	return cmd
+}
+
+// Run functions called when the bundle metadata get command is provided in the command line
+func (o *BundleMetadataGetOptions) Run() error {
+	logger := util.NewUILevelLogger(util.LogWarn, util.NewLogger(o.ui))
+	metadataPath, err := bundleMetadataPath(o.BundleDir)
+	if err != nil {
+		return err
+	}
+
+	metadata, warnings, err := v1.ReadMetadataFile(metadataPath, false)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		logger.Warnf("%s\n", warning)
+	}
+
+	if o.Key != "" {
+		value, found := metadata.Get(o.Key)
+		if !found {
+			return fmt.Errorf("Metadata key '%s' not found in '%s'", o.Key, metadataPath)
+		}
+		o.ui.PrintBlock([]byte(value + "\n"))
+		return nil
+	}
+
+	bs, err := metadata.AsBytes()
+	if err != nil {
+		return err
+	}
+	o.ui.PrintBlock(bs)
+
+	return nil
+}
+
+func bundleMetadataPath(bundleDir string) (string, error) {
+	imgpkgDir, err := bundleImgpkgDir(bundleDir)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(imgpkgDir, bundle.BundleMetadataFile), nil
+}
+
+// bundleImgpkgDir returns the .imgpkg directory of bundleDir, failing when it does not exist
+func bundleImgpkgDir(bundleDir string) (string, error) {
+	imgpkgDir := filepath.Join(bundleDir, bundle.ImgpkgDir)
+	info, err := os.Stat(imgpkgDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("Expected '%s' to be a bundle directory, but '%s' was not found", bundleDir, imgpkgDir)
+		}
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("Expected '%s' to be a directory", imgpkgDir)
+	}
+
+	return imgpkgDir, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cppforlife/go-cli-ui/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleMetadataSet(t *testing.T) {
+	metadataWithUnknownFields := `apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: Bundle
+metadata:
+  version: 1.0.0
+extra: value
+`
+
+	setup := func(t *testing.T) (string, string) {
+		bundleDir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(bundleDir, ".imgpkg"), 0700))
+		metadataPath := filepath.Join(bundleDir, ".imgpkg", "bundle.yml")
+		require.NoError(t, os.WriteFile(metadataPath, []byte(metadataWithUnknownFields), 0600))
+		return bundleDir, metadataPath
+	}
+
+	t.Run("when the metadata has unknown fields, it fails without changing the file", func(t *testing.T) {
+		bundleDir, metadataPath := setup(t)
+		confUI := ui.NewConfUI(ui.NewNoopLogger())
+		defer confUI.Flush()
+
+		set := NewBundleMetadataSetOptions(confUI)
+		set.BundleDir = bundleDir
+		set.Metadata = []string{"version=2.0.0"}
+		err := set.Run()
+		require.ErrorContains(t, err, "Validating extra: Unknown field")
+		require.ErrorContains(t, err, "--force")
+
+		contents, err := os.ReadFile(metadataPath)
+		require.NoError(t, err)
+		assert.Equal(t, metadataWithUnknownFields, string(contents))
+	})
+
+	t.Run("when forced, it rewrites the metadata removing the unknown fields", func(t *testing.T) {
+		bundleDir, metadataPath := setup(t)
+		confUI := ui.NewConfUI(ui.NewNoopLogger())
+		defer confUI.Flush()
+
+		set := NewBundleMetadataSetOptions(confUI)
+		set.BundleDir = bundleDir
+		set.Metadata = []string{"version=2.0.0"}
+		set.Force = true
+		require.NoError(t, set.Run())
+
+		contents, err := os.ReadFile(metadataPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(contents), "version: 2.0.0")
+		assert.NotContains(t, string(contents), "extra")
+	})
+}
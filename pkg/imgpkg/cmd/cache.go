@@ -0,0 +1,177 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/cache"
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	v1 "carvel.dev/imgpkg/pkg/imgpkg/v1"
+	"github.com/cppforlife/go-cli-ui/ui"
+	uitable "github.com/cppforlife/go-cli-ui/ui/table"
+	"github.com/spf13/cobra"
+)
+
+// NewCacheCmd creates the parent command of the blob cache commands
+func NewCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Cache",
+	}
+	return cmd
+}
+
+// CacheFlags command line flags to locate the blob cache
+type CacheFlags struct {
+	Dir string
+}
+
+// Set Registers the flags available to the provided command
+func (c *CacheFlags) Set(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&c.Dir, "cache-dir", "", "Directory of the blob cache ($IMGPKG_CACHE_DIR)")
+}
+
+// CacheDir returns the cache directory from the flag or the environment
+func (c CacheFlags) CacheDir() (string, error) {
+	if dir := v1.OptsFromEnv(registry.Opts{CacheDir: c.Dir}, os.LookupEnv).CacheDir; dir != "" {
+		return dir, nil
+	}
+	return "", fmt.Errorf("Expected --cache-dir or $IMGPKG_CACHE_DIR to be provided")
+}
+
+// sizeValue flag value that accepts sizes with units, i.e. 500MB or 10GiB
+type sizeValue int64
+
+func (s *sizeValue) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *sizeValue) Set(val string) error {
+	size, err := cache.ParseSize(val)
+	if err != nil {
+		return err
+	}
+	*s = sizeValue(size)
+	return nil
+}
+
+func (s *sizeValue) Type() string {
+	return "size"
+}
+
+// CacheListOptions options of the cache list command
+type CacheListOptions struct {
+	ui ui.UI
+
+	CacheFlags CacheFlags
+}
+
+// NewCacheListOptions constructor for building a CacheListOptions, holding values derived via flags
+func NewCacheListOptions(ui ui.UI) *CacheListOptions {
+	return &CacheListOptions{ui: ui}
+}
+
+// NewCacheListCmd creates the command that lists the blobs in the cache
+func NewCacheListCmd(o *CacheListOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List blobs present in the cache",
+		RunE:    func(_ *cobra.Command, _ []string) error { return o.Run() },
+		Example: `
+  # List the blobs in the cache
+  imgpkg cache ls --cache-dir ~/.cache/imgpkg`,
+	}
+	o.CacheFlags.Set(cmd)
+	return cmd
+}
+
+// Run lists the blobs in the cache and their total size
+func (c *CacheListOptions) Run() error {
+	dir, err := c.CacheFlags.CacheDir()
+	if err != nil {
+		return err
+	}
+
+	entries, err := cache.NewCache(dir, 0).Entries()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	table := uitable.Table{
+		Title:   "Blobs",
+		Content: "blobs",
+
+		Header: []uitable.Header{
+			uitable.NewHeader("Digest"),
+			uitable.NewHeader("Size"),
+			uitable.NewHeader("Last used"),
+		},
+	}
+	for _, entry := range entries {
+		total += entry.Size
+		table.Rows = append(table.Rows, []uitable.Value{
+			uitable.NewValueString(entry.Digest.String()),
+			uitable.NewValueString(formatBytes(entry.Size)),
+			uitable.NewValueTime(entry.LastUsed),
+		})
+	}
+	table.Notes = []string{fmt.Sprintf("Total size: %s", formatBytes(total))}
+
+	c.ui.PrintTable(table)
+	return nil
+}
+
+// CachePruneOptions options of the cache prune command
+type CachePruneOptions struct {
+	ui ui.UI
+
+	CacheFlags CacheFlags
+	MaxSize    sizeValue
+}
+
+// NewCachePruneOptions constructor for building a CachePruneOptions, holding values derived via flags
+func NewCachePruneOptions(ui ui.UI) *CachePruneOptions {
+	return &CachePruneOptions{ui: ui}
+}
+
+// NewCachePruneCmd creates the command that removes blobs from the cache
+func NewCachePruneCmd(o *CachePruneOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove the least recently used blobs from the cache",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+		Example: `
+  # Remove all the blobs from the cache
+  imgpkg cache prune --cache-dir ~/.cache/imgpkg
+
+  # Remove the least recently used blobs until the cache is at most 10GiB
+  imgpkg cache prune --cache-dir ~/.cache/imgpkg --max-size 10GiB`,
+	}
+	o.CacheFlags.Set(cmd)
+	cmd.Flags().Var(&o.MaxSize, "max-size", "Maximum size of the cache after pruning (format: 500MB, 10GiB)")
+	return cmd
+}
+
+// Run removes the least recently used blobs until the cache is at most MaxSize bytes
+func (c *CachePruneOptions) Run() error {
+	dir, err := c.CacheFlags.CacheDir()
+	if err != nil {
+		return err
+	}
+
+	removed, err := cache.NewCache(dir, 0).Prune(int64(c.MaxSize))
+
+	var total int64
+	for _, entry := range removed {
+		total += entry.Size
+	}
+	c.ui.PrintLinef("Removed %d blobs (%s)", len(removed), formatBytes(total))
+
+	return err
+}
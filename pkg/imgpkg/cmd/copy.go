@@ -77,6 +77,7 @@ func NewCopyCmd(o *CopyOptions) *cobra.Command {
 	o.LockOutputFlags.SetOnCopy(cmd)
 	o.TarFlags.Set(cmd)
 	o.RegistryFlags.Set(cmd)
+	o.RegistryFlags.SetReport(cmd)
 	o.SignatureFlags.Set(cmd)
 	cmd.Flags().StringVar(&o.RepoDst, "to-repo", "", "Location to upload assets")
 	cmd.Flags().IntVar(&o.Concurrency, "concurrency", 5, "Concurrency")
@@ -88,17 +89,21 @@ func NewCopyCmd(o *CopyOptions) *cobra.Command {
 }
 
 func (c *CopyOptions) Run() error {
+	err := c.run()
+	if reportErr := c.RegistryFlags.WriteReport(); err == nil {
+		err = reportErr
+	}
+	return err
+}
+
+func (c *CopyOptions) run() error {
 	if !c.hasOneSrc() {
 		return fmt.Errorf("Expected either --lock, --bundle (-b), --image (-i), or --tar as a source")
 	}
 	if !c.hasOneDst() {
 		return fmt.Errorf("Expected either --to-tar or --to-repo")
 	}
-
-	registryOpts := c.RegistryFlags.AsRegistryOpts()
-	registryOpts.IncludeNonDistributableLayers = c.IncludeNonDistributable
-
-	reg, err := registry.NewSimpleRegistry(registryOpts)
+	err := c.RegistryFlags.ResolveLayoutReferences(&c.BundleFlags.Bundle, &c.ImageFlags.Image, &c.RepoDst)
 	if err != nil {
 		return err
 	}
@@ -107,6 +112,15 @@ func (c *CopyOptions) Run() error {
 	levelLogger := util.NewUILevelLogger(util.LogWarn, prefixedLogger)
 	imagesUploaderLogger := util.NewProgressBar(levelLogger, "done uploading images", "Error uploading images")
 
+	registryOpts := c.RegistryFlags.AsRegistryOpts(c.ui)
+	registryOpts.IncludeNonDistributableLayers = c.IncludeNonDistributable
+	registryOpts.ThrottleLogger = levelLogger
+
+	reg, err := registry.NewSimpleRegistry(registryOpts)
+	if err != nil {
+		return err
+	}
+
 	var tagGen ctlimgset.TagGenerator
 	tagGen = image.DefaultTagGenerator{}
 	if c.UseRepoBasedTags {
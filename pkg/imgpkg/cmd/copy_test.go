@@ -4,6 +4,7 @@
 package cmd
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -62,3 +63,22 @@ func TestTarSrcWithTarDst(t *testing.T) {
 		t.Fatalf("Expected error message related to destinations, got: %s", err)
 	}
 }
+
+func TestLayoutSrcWithRegistryDst(t *testing.T) {
+	flags := RegistryFlags{}
+	digest := "sha256:" + strings.Repeat("a", 64)
+	bundle, otherBundle, repoDst := "layout://dir/bundle:v1", "layout://other-dir/bundle@"+digest, "registry.io/bundle"
+	err := flags.ResolveLayoutReferences(&bundle, &otherBundle, &repoDst)
+	if err != nil {
+		t.Fatalf("Expected ResolveLayoutReferences() to succeed, got: %s", err)
+	}
+
+	if bundle != "oci-layout.local/bundle:v1" || otherBundle != "oci-layout.local/bundle-2@"+digest || repoDst != "registry.io/bundle" {
+		t.Fatalf("Expected only the layout references to be replaced, got: %s, %s, %s", bundle, otherBundle, repoDst)
+	}
+
+	expectedLayouts := map[string]string{"oci-layout.local/bundle": "dir/bundle", "oci-layout.local/bundle-2": "other-dir/bundle"}
+	if !reflect.DeepEqual(flags.layouts, expectedLayouts) {
+		t.Fatalf("Expected layouts %v, got: %v", expectedLayouts, flags.layouts)
+	}
+}
@@ -4,10 +4,13 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/jsonpath"
 	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
 	v1 "carvel.dev/imgpkg/pkg/imgpkg/v1"
 	goui "github.com/cppforlife/go-cli-ui/ui"
@@ -18,20 +21,28 @@ import (
 
 var (
 	// DescribeOutputType Possible output options
-	DescribeOutputType = []string{"text", "yaml"}
+	DescribeOutputType = []string{"text", "yaml", "json", "jsonpath=<expression>", "dot", "mermaid", "json-graph"}
 )
 
+const describeJSONPathOutputPrefix = "jsonpath="
+
 // DescribeOptions Command Line options that can be provided to the describe command
 type DescribeOptions struct {
 	ui goui.UI
 
 	BundleFlags   BundleFlags
+	ImageFlags    ImageFlags
 	RegistryFlags RegistryFlags
 
 	Concurrency            int
 	OutputType             string
 	Layers                 bool
 	IncludeCosignArtifacts bool
+	ShowDuplicates         bool
+	Sizes                  bool
+
+	// uiJSON set when the --json UI flag is provided
+	uiJSON bool
 }
 
 // NewDescribeOptions constructor for building a DescribeOptions, holding values derived via flags
@@ -43,19 +54,37 @@ func NewDescribeOptions(ui *goui.ConfUI) *DescribeOptions {
 func NewDescribeCmd(o *DescribeOptions) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "describe",
-		Short: "Describe the images and bundles associated with a give bundle",
-		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+		Short: "Describe the images and bundles associated with a give bundle, or the manifest of an image",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			o.uiJSON = cmd.Flags().Changed("json")
+			return o.Run()
+		},
 		Example: `
     # Describe a bundle
-    imgpkg describe -b carvel.dev/app1-bundle`,
+    imgpkg describe -b carvel.dev/app1-bundle
+
+    # Print the digests of the images of a bundle
+    imgpkg describe -b carvel.dev/app1-bundle -o 'jsonpath={.content.images.*.image}'
+
+    # Estimate how much data will be transferred when copying a bundle
+    imgpkg describe -b carvel.dev/app1-bundle --sizes
+
+    # Render the graph of bundles and images with Graphviz
+    imgpkg describe -b carvel.dev/app1-bundle -o dot | dot -Tsvg > bundle.svg
+
+    # Describe an image or image index
+    imgpkg describe -i carvel.dev/app1-image`,
 	}
 
 	o.BundleFlags.SetCopy(cmd)
+	cmd.Flags().StringVarP(&o.ImageFlags.Image, "image", "i", "", "Image reference to describe, can be an image index (example: docker.io/dkalinin/test-content)")
 	o.RegistryFlags.Set(cmd)
 	cmd.Flags().IntVar(&o.Concurrency, "concurrency", 5, "Concurrency")
-	cmd.Flags().StringVarP(&o.OutputType, "output-type", "o", "text", "Type of output possible values: [text, yaml]")
+	cmd.Flags().StringVarP(&o.OutputType, "output-type", "o", "text", "Type of output possible values: [text, yaml, json, jsonpath=<expression>, dot, mermaid, json-graph]")
 	cmd.Flags().BoolVarP(&o.Layers, "layers", "", true, "Retrieve image layers info (Default: false)")
 	cmd.Flags().BoolVar(&o.IncludeCosignArtifacts, "cosign-artifacts", true, "Retrieve cosign artifact information (Default: true)")
+	cmd.Flags().BoolVar(&o.Sizes, "sizes", false, "Report the compressed size of each image, the total size and the size after deduplicating shared layers")
+	cmd.Flags().BoolVar(&o.ShowDuplicates, "show-duplicates", false, "Report nested bundles that are referenced by more than one bundle, with the paths that reach them")
 	return cmd
 }
 
@@ -65,8 +94,16 @@ func (d *DescribeOptions) Run() error {
 	if err != nil {
 		return err
 	}
+	err = d.RegistryFlags.ResolveLayoutReferences(&d.BundleFlags.Bundle, &d.ImageFlags.Image)
+	if err != nil {
+		return err
+	}
 	logLevel := util.LogWarn
 
+	if d.ImageFlags.Image != "" {
+		return d.describeImage(logLevel)
+	}
+
 	levelLogger := util.NewUILevelLogger(logLevel, util.NewLogger(d.ui))
 	description, err := v1.Describe(
 		d.BundleFlags.Bundle,
@@ -75,8 +112,10 @@ func (d *DescribeOptions) Run() error {
 			Concurrency:            d.Concurrency,
 			IncludeCosignArtifacts: d.IncludeCosignArtifacts,
 			Layers:                 d.Layers,
+			IndexLayers:            d.Sizes,
+			LayerSizes:             d.Sizes || d.graphOutput(),
 		},
-		d.RegistryFlags.AsRegistryOpts())
+		d.RegistryFlags.AsRegistryOpts(d.ui))
 	if err != nil {
 		return err
 	}
@@ -85,9 +124,34 @@ func (d *DescribeOptions) Run() error {
 	if d.OutputType == "text" {
 		p := bundleTextPrinter{logger: ttyEnabledLogger}
 		p.Print(description)
+		if d.ShowDuplicates {
+			p.PrintDuplicatedBundles(v1.FindDuplicatedBundles(description))
+		}
+		if d.Sizes {
+			sizes, err := v1.CalculateSizes(description)
+			if err != nil {
+				return err
+			}
+			p.PrintSizes(sizes)
+		}
 	} else if d.OutputType == "yaml" {
 		p := bundleYAMLPrinter{logger: ttyEnabledLogger}
 		return p.Print(description)
+	} else if d.OutputType == "json" {
+		p := describeJSONPrinter{logger: ttyEnabledLogger}
+		return p.Print(v1.NewDescriptionDocument(description))
+	} else if strings.HasPrefix(d.OutputType, describeJSONPathOutputPrefix) {
+		p := describeJSONPathPrinter{logger: ttyEnabledLogger, path: jsonpath.MustParse(strings.TrimPrefix(d.OutputType, describeJSONPathOutputPrefix))}
+		return p.Print(v1.NewDescriptionDocument(description))
+	} else if d.OutputType == "dot" {
+		p := bundleDOTPrinter{logger: ttyEnabledLogger}
+		p.Print(v1.NewDescriptionGraph(description))
+	} else if d.OutputType == "mermaid" {
+		p := bundleMermaidPrinter{logger: ttyEnabledLogger}
+		p.Print(v1.NewDescriptionGraph(description))
+	} else if d.OutputType == "json-graph" {
+		p := bundleJSONGraphPrinter{logger: ttyEnabledLogger}
+		return p.Print(v1.NewDescriptionGraph(description))
 	}
 	return nil
 }
@@ -100,12 +164,52 @@ func (d *DescribeOptions) validateFlags() error {
 			break
 		}
 	}
+	if strings.HasPrefix(d.OutputType, describeJSONPathOutputPrefix) {
+		_, err := jsonpath.Parse(strings.TrimPrefix(d.OutputType, describeJSONPathOutputPrefix))
+		if err != nil {
+			return fmt.Errorf("Parsing --output-type: %s", err)
+		}
+		outputType = d.OutputType
+	}
 	if outputType == "" {
-		return fmt.Errorf("--output-type can only have the following values [text, yaml]")
+		return fmt.Errorf("--output-type can only have the following values [%s]", strings.Join(DescribeOutputType, ", "))
+	}
+	if d.uiJSON && d.OutputType != "text" {
+		return fmt.Errorf("--json wraps the output of --output-type %s in the UI response, use only --output-type to get the document", d.OutputType)
+	}
+	if d.BundleFlags.Bundle != "" && d.ImageFlags.Image != "" {
+		return fmt.Errorf("Expected only one of --bundle (-b) or --image (-i) to be provided")
+	}
+	if d.BundleFlags.Bundle == "" && d.ImageFlags.Image == "" {
+		return fmt.Errorf("Expected either --bundle (-b) or --image (-i) to be provided")
+	}
+	if d.ImageFlags.Image != "" {
+		switch {
+		case d.ShowDuplicates:
+			return fmt.Errorf("--show-duplicates can only be used when describing a bundle")
+		case d.Sizes:
+			return fmt.Errorf("--sizes can only be used when describing a bundle")
+		case d.graphOutput():
+			return fmt.Errorf("--output-type %s can only be used when describing a bundle", d.OutputType)
+		}
+	}
+	if d.ShowDuplicates && d.OutputType != "text" {
+		return fmt.Errorf("--show-duplicates can only be used with --output-type text")
+	}
+	if d.Sizes && d.OutputType != "text" {
+		return fmt.Errorf("--sizes can only be used with --output-type text")
+	}
+	if d.Sizes && !d.Layers {
+		return fmt.Errorf("--sizes requires layers information, which is disabled by --layers=false")
 	}
 	return nil
 }
 
+// graphOutput returns true when the output type prints the bundle as a graph
+func (d *DescribeOptions) graphOutput() bool {
+	return d.OutputType == "dot" || d.OutputType == "mermaid" || d.OutputType == "json-graph"
+}
+
 type bundleTextPrinter struct {
 	logger Logger
 }
@@ -196,6 +300,36 @@ func (p bundleTextPrinter) printAnnotations(annotations map[string]string, inden
 	}
 }
 
+func (p bundleTextPrinter) PrintDuplicatedBundles(duplicated []v1.DuplicatedBundle) {
+	p.logger.Logf("\n")
+	if len(duplicated) == 0 {
+		p.logger.Logf("No nested bundles are referenced by more than one bundle\n")
+		return
+	}
+
+	p.logger.Logf("Nested bundles referenced by more than one bundle:\n")
+	for _, duplicatedBundle := range duplicated {
+		p.logger.Logf("- Image: %s\n", duplicatedBundle.Image)
+		p.logger.Logf("  Paths:\n")
+		for _, path := range duplicatedBundle.Paths {
+			p.logger.Logf("  - %s\n", strings.Join(path, " -> "))
+		}
+	}
+}
+
+func (p bundleTextPrinter) PrintSizes(sizes v1.Sizes) {
+	p.logger.Logf("\n")
+	p.logger.Logf("Sizes:\n")
+	for _, image := range sizes.Images {
+		p.logger.Logf("- Image: %s\n", image.Image)
+		p.logger.Logf("  Type: %s\n", image.ImageType)
+		p.logger.Logf("  Size: %s\n", formatBytes(image.Size))
+	}
+	p.logger.Logf("\n")
+	p.logger.Logf("Total size: %s\n", formatBytes(sizes.TotalSize))
+	p.logger.Logf("Unique size (shared layers counted once): %s\n", formatBytes(sizes.UniqueSize))
+}
+
 type bundleYAMLPrinter struct {
 	logger Logger
 }
@@ -206,13 +340,60 @@ func (p bundleYAMLPrinter) Print(description v1.Description) error {
 		panic(fmt.Sprintf("Internal consistency: expected %s to be a digest reference", description.Image))
 	}
 
-	yamlDesc, err := yaml.Marshal(description)
+	yamlDesc, err := yaml.Marshal(v1.NewDescriptionDocument(description))
 	if err != nil {
 		return err
 	}
 
 	p.logger.Logf("sha: %s\n", bundleRef.Identifier())
-	p.logger.Logf(string(yamlDesc))
+	p.logger.Logf("%s", yamlDesc)
+
+	return nil
+}
 
+type describeJSONPrinter struct {
+	logger Logger
+}
+
+func (p describeJSONPrinter) Print(document interface{}) error {
+	bs, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	p.logger.Logf("%s\n", bs)
+	return nil
+}
+
+// describeJSONPathPrinter prints the fields of the document selected by path, one per line.
+// Strings are printed as is and any other value as JSON
+type describeJSONPathPrinter struct {
+	logger Logger
+	path   jsonpath.Path
+}
+
+func (p describeJSONPathPrinter) Print(document interface{}) error {
+	bs, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	var doc interface{}
+	err = json.Unmarshal(bs, &doc)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range p.path.Find(doc) {
+		if str, ok := value.(string); ok {
+			p.logger.Logf("%s\n", str)
+			continue
+		}
+		valueBs, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		p.logger.Logf("%s\n", valueBs)
+	}
 	return nil
 }
@@ -0,0 +1,138 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	v1 "carvel.dev/imgpkg/pkg/imgpkg/v1"
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+type bundleDOTPrinter struct {
+	logger Logger
+}
+
+func (p bundleDOTPrinter) Print(graph v1.DescriptionGraph) {
+	var sb strings.Builder
+	sb.WriteString("digraph bundle {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [fontname=\"monospace\"];\n")
+	for _, node := range graph.Nodes {
+		attrs := []string{
+			fmt.Sprintf("label=%s", dotQuote(graphNodeLabel(node, "\n"))),
+			fmt.Sprintf("shape=%s", graphNodeShape(node)),
+			fmt.Sprintf("type=%s", dotQuote(string(node.ImageType))),
+		}
+		if node.Size > 0 {
+			attrs = append(attrs, fmt.Sprintf("size_bytes=%d", node.Size))
+		}
+		if node.Error != "" {
+			attrs = append(attrs, "color=red")
+		}
+		sb.WriteString(fmt.Sprintf("  %s [%s];\n", dotQuote(node.ID), strings.Join(attrs, ", ")))
+	}
+	for _, edge := range graph.Edges {
+		sb.WriteString(fmt.Sprintf("  %s -> %s;\n", dotQuote(edge.From), dotQuote(edge.To)))
+	}
+	sb.WriteString("}\n")
+
+	p.logger.Logf("%s", sb.String())
+}
+
+func graphNodeShape(node v1.DescriptionGraphNode) string {
+	if node.ImageType == bundle.BundleImage {
+		return "box3d"
+	}
+	return "box"
+}
+
+func dotQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+type bundleMermaidPrinter struct {
+	logger Logger
+}
+
+func (p bundleMermaidPrinter) Print(graph v1.DescriptionGraph) {
+	// mermaid node IDs cannot contain ':' or '@' so nodes are numbered
+	ids := map[string]string{}
+	for i, node := range graph.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+	for _, node := range graph.Nodes {
+		label := `"` + strings.ReplaceAll(graphNodeLabel(node, "<br/>"), `"`, "#quot;") + `"`
+		if node.ImageType == bundle.BundleImage {
+			sb.WriteString(fmt.Sprintf("  %s[[%s]]\n", ids[node.ID], label))
+		} else {
+			sb.WriteString(fmt.Sprintf("  %s[%s]\n", ids[node.ID], label))
+		}
+	}
+	for _, edge := range graph.Edges {
+		sb.WriteString(fmt.Sprintf("  %s --> %s\n", ids[edge.From], ids[edge.To]))
+	}
+
+	p.logger.Logf("%s", sb.String())
+}
+
+type bundleJSONGraphPrinter struct {
+	logger Logger
+}
+
+func (p bundleJSONGraphPrinter) Print(graph v1.DescriptionGraph) error {
+	bs, err := json.MarshalIndent(graph, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	p.logger.Logf("%s\n", bs)
+	return nil
+}
+
+// graphNodeLabel describes the node with its repository, short digest, type and size
+func graphNodeLabel(node v1.DescriptionGraphNode, separator string) string {
+	var lines []string
+	if node.Error != "" {
+		lines = append(lines, node.ID, "Error: "+node.Error)
+	} else {
+		repo := node.Image
+		digest := node.ID
+		if digestRef, err := regname.NewDigest(node.Image); err == nil {
+			repo = digestRef.Context().RepositoryStr()
+		}
+		if len(digest) > len("sha256:")+12 {
+			digest = digest[:len("sha256:")+12]
+		}
+		lines = append(lines, repo, digest)
+	}
+
+	info := string(node.ImageType)
+	if node.Size > 0 {
+		info += ", " + formatBytes(node.Size)
+	}
+	lines = append(lines, info)
+
+	return strings.Join(lines, separator)
+}
+
+// formatBytes converts size into a human readable format using binary units, i.e. 1.5 MiB
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
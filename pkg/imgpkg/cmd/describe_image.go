@@ -0,0 +1,131 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/jsonpath"
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	v1 "carvel.dev/imgpkg/pkg/imgpkg/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func (d *DescribeOptions) describeImage(logLevel util.LogLevel) error {
+	levelLogger := util.NewUILevelLogger(logLevel, util.NewLogger(d.ui))
+	info, err := v1.DescribeImage(
+		d.ImageFlags.Image,
+		v1.DescribeOpts{
+			Logger:                 levelLogger,
+			Concurrency:            d.Concurrency,
+			IncludeCosignArtifacts: d.IncludeCosignArtifacts,
+			Layers:                 d.Layers,
+		},
+		d.RegistryFlags.AsRegistryOpts(d.ui))
+	if err != nil {
+		return err
+	}
+
+	ttyEnabledLogger := util.NewUILevelLogger(logLevel, util.NewLoggerNoTTY(d.ui))
+	switch {
+	case d.OutputType == "text":
+		p := imageTextPrinter{logger: ttyEnabledLogger}
+		p.Print(info)
+	case d.OutputType == "yaml":
+		bs, err := yaml.Marshal(v1.NewImageDescriptionDocument(info))
+		if err != nil {
+			return err
+		}
+		ttyEnabledLogger.Logf("%s", bs)
+	case d.OutputType == "json":
+		p := describeJSONPrinter{logger: ttyEnabledLogger}
+		return p.Print(v1.NewImageDescriptionDocument(info))
+	case strings.HasPrefix(d.OutputType, describeJSONPathOutputPrefix):
+		p := describeJSONPathPrinter{logger: ttyEnabledLogger, path: jsonpath.MustParse(strings.TrimPrefix(d.OutputType, describeJSONPathOutputPrefix))}
+		return p.Print(v1.NewImageDescriptionDocument(info))
+	}
+	return nil
+}
+
+type imageTextPrinter struct {
+	logger Logger
+}
+
+func (p imageTextPrinter) Print(info v1.ImageInfo) {
+	p.logger.Logf("Image: %s\n", info.Image)
+	p.printDetails(info, p.logger)
+}
+
+func (p imageTextPrinter) printDetails(info v1.ImageInfo, logger Logger) {
+	logger.Logf("Type: %s\n", info.ImageType)
+	if info.Origin != "" && info.Origin != info.Image {
+		logger.Logf("Origin: %s\n", info.Origin)
+	}
+	if info.MediaType != "" {
+		logger.Logf("Media Type: %s\n", info.MediaType)
+	}
+	if info.Platform != nil {
+		logger.Logf("Platform: %s\n", formatPlatform(*info.Platform))
+	}
+	if info.Error != "" {
+		logger.Logf("Error: %s\n", info.Error)
+	}
+	p.printMap("Labels", info.Labels, logger)
+	p.printMap("Annotations", info.Annotations, logger)
+
+	if len(info.Layers) > 0 {
+		logger.Logf("Layers:\n")
+		for _, layer := range info.Layers {
+			logger.Logf("  - Digest: %s\n", layer.Digest)
+			logger.Logf("    Size: %s\n", formatBytes(layer.Size))
+			logger.Logf("    Media Type: %s\n", layer.MediaType)
+			if layer.NonDistributable {
+				logger.Logf("    Non-distributable: true\n")
+			}
+		}
+	}
+
+	p.printList("Manifests", info.Manifests, logger)
+	p.printList("Signatures", info.Signatures, logger)
+}
+
+func (p imageTextPrinter) printList(title string, infos []v1.ImageInfo, logger Logger) {
+	if len(infos) == 0 {
+		return
+	}
+	logger.Logf("%s:\n", title)
+	indentLogger := util.NewIndentedLogger(util.NewIndentedLogger(logger))
+	for i, info := range infos {
+		if i > 0 {
+			logger.Logf("\n")
+		}
+		logger.Logf("  - Image: %s\n", info.Image)
+		p.printDetails(info, indentLogger)
+	}
+}
+
+func (p imageTextPrinter) printMap(title string, values map[string]string, logger Logger) {
+	if len(values) == 0 {
+		return
+	}
+	logger.Logf("%s:\n", title)
+
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		logger.Logf("  %s: %s\n", key, values[key])
+	}
+}
+
+func formatPlatform(platform v1.Platform) string {
+	parts := []string{platform.OS, platform.Architecture}
+	if platform.Variant != "" {
+		parts = append(parts, platform.Variant)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	v1 "carvel.dev/imgpkg/pkg/imgpkg/v1"
+	goui "github.com/cppforlife/go-cli-ui/ui"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	// DiffOutputType Possible output options
+	DiffOutputType = []string{"text", "yaml", "json"}
+)
+
+// DiffOptions Command Line options that can be provided to the diff command
+type DiffOptions struct {
+	ui goui.UI
+
+	RegistryFlags RegistryFlags
+
+	FromBundle  string
+	ToBundle    string
+	FromTarPath string
+	ToTarPath   string
+
+	Concurrency int
+	OutputType  string
+}
+
+// NewDiffOptions constructor for building a DiffOptions, holding values derived via flags
+func NewDiffOptions(ui *goui.ConfUI) *DiffOptions {
+	return &DiffOptions{ui: ui}
+}
+
+// NewDiffCmd constructor for the diff command
+func NewDiffCmd(o *DiffOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare two versions of a bundle",
+		Long:  "Compare two versions of a bundle, reporting the files, images, metadata and nested bundles that changed",
+		RunE:  func(_ *cobra.Command, _ []string) error { return o.Run() },
+		Example: `
+    # Compare two versions of a bundle
+    imgpkg diff --from carvel.dev/app1-bundle:v1.0.0 --to carvel.dev/app1-bundle:v1.1.0
+
+    # Compare a bundle in a tarball with a bundle in a registry, as YAML
+    imgpkg diff --from-tar /tmp/app1-bundle.tar --to carvel.dev/app1-bundle:v1.1.0 -o yaml`,
+	}
+
+	o.RegistryFlags.Set(cmd)
+	cmd.Flags().StringVar(&o.FromBundle, "from", "", "Bundle reference of the version to compare from")
+	cmd.Flags().StringVar(&o.ToBundle, "to", "", "Bundle reference of the version to compare to")
+	cmd.Flags().StringVar(&o.FromTarPath, "from-tar", "", "Path to tar file, created by copy, with the version to compare from")
+	cmd.Flags().StringVar(&o.ToTarPath, "to-tar", "", "Path to tar file, created by copy, with the version to compare to")
+	cmd.Flags().IntVar(&o.Concurrency, "concurrency", 5, "Concurrency")
+	cmd.Flags().StringVarP(&o.OutputType, "output-type", "o", "text", "Type of output possible values: [text, yaml, json]")
+	return cmd
+}
+
+// Run functions called when the diff command is provided in the command line
+func (d *DiffOptions) Run() error {
+	err := d.validateFlags()
+	if err != nil {
+		return err
+	}
+	logLevel := util.LogWarn
+
+	levelLogger := util.NewUILevelLogger(logLevel, util.NewLogger(d.ui))
+	diff, err := v1.Diff(
+		v1.DiffOrigin{BundleRef: d.FromBundle, TarPath: d.FromTarPath},
+		v1.DiffOrigin{BundleRef: d.ToBundle, TarPath: d.ToTarPath},
+		v1.DiffOpts{
+			Logger:      levelLogger,
+			Concurrency: d.Concurrency,
+		},
+		d.RegistryFlags.AsRegistryOpts(d.ui))
+	if err != nil {
+		return err
+	}
+
+	ttyEnabledLogger := util.NewUILevelLogger(logLevel, util.NewLoggerNoTTY(d.ui))
+	switch d.OutputType {
+	case "text":
+		diffTextPrinter{logger: ttyEnabledLogger}.Print(diff)
+	case "yaml":
+		bs, err := yaml.Marshal(diff)
+		if err != nil {
+			return err
+		}
+		ttyEnabledLogger.Logf("%s", bs)
+	case "json":
+		bs, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return err
+		}
+		ttyEnabledLogger.Logf("%s\n", bs)
+	}
+	return nil
+}
+
+func (d *DiffOptions) validateFlags() error {
+	if (d.FromBundle == "") == (d.FromTarPath == "") {
+		return fmt.Errorf("Expected either --from or --from-tar to be provided")
+	}
+	if (d.ToBundle == "") == (d.ToTarPath == "") {
+		return fmt.Errorf("Expected either --to or --to-tar to be provided")
+	}
+
+	for _, s := range DiffOutputType {
+		if s == d.OutputType {
+			return nil
+		}
+	}
+	return fmt.Errorf("--output-type can only have the following values [%s]", strings.Join(DiffOutputType, ", "))
+}
+
+type diffTextPrinter struct {
+	logger Logger
+}
+
+func (p diffTextPrinter) Print(diff v1.BundleDiff) {
+	p.logger.Logf("Comparing bundle '%s' to '%s'\n", diff.From, diff.To)
+	if !diff.HasChanges() {
+		p.logger.Logf("\nNo differences found\n")
+		return
+	}
+	p.printBundle(diff, p.logger)
+}
+
+func (p diffTextPrinter) printBundle(diff v1.BundleDiff, logger Logger) {
+	indentLogger := util.NewIndentedLogger(logger)
+
+	if len(diff.Files.Added) > 0 || len(diff.Files.Removed) > 0 || len(diff.Files.Modified) > 0 {
+		logger.Logf("\nFiles:\n")
+		p.printFiles("Added", diff.Files.Added, indentLogger)
+		p.printFiles("Removed", diff.Files.Removed, indentLogger)
+		p.printFiles("Modified", diff.Files.Modified, indentLogger)
+	}
+
+	if len(diff.Images.Added) > 0 || len(diff.Images.Removed) > 0 || len(diff.Images.Changed) > 0 {
+		logger.Logf("\nImages:\n")
+		if len(diff.Images.Added) > 0 {
+			indentLogger.Logf("Added:\n")
+			for _, img := range diff.Images.Added {
+				indentLogger.Logf("- %s (%s): %s\n", img.Key, img.ImageType, img.To)
+			}
+		}
+		if len(diff.Images.Removed) > 0 {
+			indentLogger.Logf("Removed:\n")
+			for _, img := range diff.Images.Removed {
+				indentLogger.Logf("- %s (%s): %s\n", img.Key, img.ImageType, img.From)
+			}
+		}
+		if len(diff.Images.Changed) > 0 {
+			indentLogger.Logf("Changed:\n")
+			for _, img := range diff.Images.Changed {
+				indentLogger.Logf("- %s (%s)\n", img.Key, img.ImageType)
+				indentLogger.Logf("  From: %s\n", img.From)
+				indentLogger.Logf("  To:   %s\n", img.To)
+			}
+		}
+	}
+
+	if len(diff.Metadata) > 0 {
+		logger.Logf("\nMetadata:\n")
+		for _, change := range diff.Metadata {
+			indentLogger.Logf("- %s: '%s' -> '%s'\n", change.Field, change.From, change.To)
+		}
+	}
+
+	for _, nested := range diff.Bundles {
+		logger.Logf("\nNested bundle '%s' -> '%s':\n", nested.From, nested.To)
+		p.printBundle(nested, indentLogger)
+	}
+}
+
+func (p diffTextPrinter) printFiles(title string, files []string, logger Logger) {
+	if len(files) == 0 {
+		return
+	}
+	logger.Logf("%s:\n", title)
+	for _, file := range files {
+		logger.Logf("- %s\n", file)
+	}
+}
@@ -52,12 +52,26 @@ func NewImgpkgCmd(o *ImgpkgOptions) *cobra.Command {
 	cmd.AddCommand(NewVersionCmd(NewVersionOptions(o.ui)))
 	cmd.AddCommand(NewCopyCmd(NewCopyOptions(o.ui)))
 	cmd.AddCommand(NewDescribeCmd(NewDescribeOptions(o.ui)))
+	cmd.AddCommand(NewDiffCmd(NewDiffOptions(o.ui)))
 
 	tagCmd := NewTagCmd()
 	tagCmd.AddCommand(NewTagListCmd(NewTagListOptions(o.ui)))
 	tagCmd.AddCommand(NewTagResolveCmd(NewTagResolveOptions(o.ui)))
 	cmd.AddCommand(tagCmd)
 
+	cacheCmd := NewCacheCmd()
+	cacheCmd.AddCommand(NewCacheListCmd(NewCacheListOptions(o.ui)))
+	cacheCmd.AddCommand(NewCachePruneCmd(NewCachePruneOptions(o.ui)))
+	cmd.AddCommand(cacheCmd)
+
+	bundleMetadataCmd := NewBundleMetadataCmd()
+	bundleMetadataCmd.AddCommand(NewBundleMetadataSetCmd(NewBundleMetadataSetOptions(o.ui)))
+	bundleMetadataCmd.AddCommand(NewBundleMetadataGetCmd(NewBundleMetadataGetOptions(o.ui)))
+	bundleCmd := NewBundleCmd()
+	bundleCmd.AddCommand(bundleMetadataCmd)
+	bundleCmd.AddCommand(NewBundleLockCmd(NewBundleLockOptions(o.ui)))
+	cmd.AddCommand(bundleCmd)
+
 	// Last one runs first
 	cobrautil.VisitCommands(cmd, cobrautil.ReconfigureCmdWithSubcmd)
 	cobrautil.VisitCommands(cmd, cobrautil.DisallowExtraArgs)
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 
+	"carvel.dev/imgpkg/pkg/imgpkg/image"
 	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
 	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
 	v1 "carvel.dev/imgpkg/pkg/imgpkg/v1"
@@ -24,6 +25,15 @@ type PullOptions struct {
 	LockInputFlags       LockInputFlags
 	BundleRecursiveFlags BundleRecursiveFlags
 	OutputPath           string
+	IncludePaths         []string
+	ExcludePaths         []string
+	Merge                bool
+	OnConflict           string
+	Clean                bool
+	WithImagesAsLayout   bool
+	Concurrency          int
+	ImageMapPath         string
+	VerifyOnly           bool
 }
 
 func NewPullOptions(ui ui.UI) *PullOptions {
@@ -40,25 +50,70 @@ func NewPullCmd(o *PullOptions) *cobra.Command {
   imgpkg pull -b repo/app1-bundle -o /tmp/app1-bundle
 
   # Pull image repo/app1-image and extract into /tmp/app1-image
-  imgpkg pull -i repo/app1-image -o /tmp/app1-image`,
+  imgpkg pull -i repo/app1-image -o /tmp/app1-image
+
+  # Pull only the config folder of bundle repo/app1-bundle, skipping the tests in it
+  imgpkg pull -b repo/app1-bundle -o /tmp/app1-bundle --include-path config --exclude-path 'config/**/*_test.yml'
+
+  # Pull bundle repo/app1-bundle into an existing directory, keeping local files and removing files of the previous pull
+  imgpkg pull -b repo/app1-bundle -o ./app1 --clean --on-conflict skip
+
+  # Pull bundle repo/app1-bundle and write all the images it references into /tmp/app1-bundle/images as an OCI Image Layout
+  imgpkg pull -b repo/app1-bundle -o /tmp/app1-bundle --with-images-as-layout
+
+  # Pull bundle repo/app1-bundle pointing its images to an internal mirror
+  imgpkg pull -b repo/app1-bundle -o /tmp/app1-bundle --image-map map.yml
+
+  # where map.yml contains
+  # ---
+  # apiVersion: imgpkg.carvel.dev/v1alpha1
+  # kind: ImageMap
+  # mappings:
+  # - from: docker.io/library
+  #   to: registry.corp.com/mirror/library
+
+  # Check if the files in /tmp/app1-bundle were changed since bundle repo/app1-bundle was pulled
+  imgpkg pull -b repo/app1-bundle -o /tmp/app1-bundle --verify-only`,
 	}
 	o.ImageFlags.Set(cmd)
 	cmd.Flags().BoolVar(&o.ImageIsBundleCheck, "image-is-bundle-check", true, "Error when image is a bundle (disable pulling bundles via -i)")
 	o.RegistryFlags.Set(cmd)
+	o.RegistryFlags.SetReport(cmd)
 	o.BundleFlags.Set(cmd)
 	o.BundleRecursiveFlags.Set(cmd)
 	o.LockInputFlags.Set(cmd)
 	cmd.Flags().StringVarP(&o.OutputPath, "output", "o", "", "Output directory path")
 	cmd.MarkFlagRequired("output")
+	cmd.Flags().StringSliceVar(&o.IncludePaths, "include-path", nil, "Only extract files matching path (supports globs and '**', format: config/**/*.yml) (can be specified multiple times)")
+	cmd.Flags().StringSliceVar(&o.ExcludePaths, "exclude-path", nil, "Do not extract files matching path (supports globs and '**', format: config/**/*.yml) (can be specified multiple times)")
+	cmd.Flags().BoolVar(&o.Merge, "merge", false, "Extract into the output directory without removing the files already in it")
+	cmd.Flags().StringVar(&o.OnConflict, "on-conflict", "", "What to do with files that already exist in the output directory when merging (overwrite, skip, fail) (default fail)")
+	cmd.Flags().BoolVar(&o.Clean, "clean", false, "Only remove files extracted by a previous pull of the same bundle or image before extracting (implies --merge)")
+	cmd.Flags().BoolVar(&o.WithImagesAsLayout, "with-images-as-layout", false, "Write every image referenced by the bundle into an OCI Image Layout in the 'images' folder of the output directory")
+	cmd.Flags().IntVar(&o.Concurrency, "concurrency", 5, "Concurrency")
+	cmd.Flags().BoolVar(&o.VerifyOnly, "verify-only", false, "Compare the output directory with the contents of the bundle or image, reporting added, removed and modified files, without extracting")
+	cmd.Flags().StringVar(&o.ImageMapPath, "image-map", "", "Path to an ImageMap file used to rewrite the locations of the images in .imgpkg/images.yml (format: from prefix -> to prefix)")
 
 	return cmd
 }
 
 func (po *PullOptions) Run() error {
+	err := po.run()
+	if reportErr := po.RegistryFlags.WriteReport(); err == nil {
+		err = reportErr
+	}
+	return err
+}
+
+func (po *PullOptions) run() error {
 	err := po.validate()
 	if err != nil {
 		return err
 	}
+	err = po.RegistryFlags.ResolveLayoutReferences(&po.BundleFlags.Bundle, &po.ImageFlags.Image)
+	if err != nil {
+		return err
+	}
 
 	levelLogger := util.NewUILevelLogger(util.LogWarn, util.NewLogger(po.ui))
 	imageRef := ""
@@ -83,11 +138,31 @@ func (po *PullOptions) Run() error {
 		Logger:   levelLogger,
 		AsImage:  !po.ImageIsBundleCheck,
 		IsBundle: len(po.ImageFlags.Image) == 0,
+
+		IncludePaths: po.IncludePaths,
+		ExcludePaths: po.ExcludePaths,
+
+		Merge:      po.Merge,
+		OnConflict: po.OnConflict,
+		Clean:      po.Clean,
+
+		WithImagesAsLayout: po.WithImagesAsLayout,
+		Concurrency:        po.Concurrency,
 	}
-	if po.BundleRecursiveFlags.Recursive {
-		_, err = v1.PullRecursive(imageRef, po.OutputPath, pullOpts, po.RegistryFlags.AsRegistryOpts())
-	} else {
-		_, err = v1.Pull(imageRef, po.OutputPath, pullOpts, po.RegistryFlags.AsRegistryOpts())
+	if len(po.ImageMapPath) > 0 {
+		imageMap, err := lockconfig.NewImageMapFromPath(po.ImageMapPath)
+		if err != nil {
+			return err
+		}
+		pullOpts.ImageMap = &imageMap
+	}
+	switch {
+	case po.VerifyOnly:
+		err = po.verify(imageRef, pullOpts)
+	case po.BundleRecursiveFlags.Recursive:
+		_, err = v1.PullRecursive(imageRef, po.OutputPath, pullOpts, po.RegistryFlags.AsRegistryOpts(po.ui))
+	default:
+		_, err = v1.Pull(imageRef, po.OutputPath, pullOpts, po.RegistryFlags.AsRegistryOpts(po.ui))
 	}
 
 	if errors.Is(err, &v1.ErrIsBundle{}) {
@@ -105,6 +180,38 @@ func (po *PullOptions) Run() error {
 	return err
 }
 
+func (po *PullOptions) verify(imageRef string, pullOpts v1.PullOpts) error {
+	diff, err := v1.VerifyPulled(imageRef, po.OutputPath, pullOpts, po.RegistryFlags.AsRegistryOpts(po.ui))
+	if err != nil {
+		return err
+	}
+
+	if !diff.HasChanges() {
+		po.ui.BeginLinef("\nDirectory '%s' matches '%s'\n", po.OutputPath, imageRef)
+		return nil
+	}
+
+	for _, section := range []struct {
+		title string
+		files []string
+	}{
+		{"Added", diff.Added},
+		{"Removed", diff.Removed},
+		{"Modified", diff.Modified},
+	} {
+		if len(section.files) == 0 {
+			continue
+		}
+		po.ui.BeginLinef("\n%s files:\n", section.title)
+		for _, file := range section.files {
+			po.ui.BeginLinef("  %s\n", file)
+		}
+	}
+
+	return fmt.Errorf("Directory '%s' does not match '%s' (%d added, %d removed, %d modified)",
+		po.OutputPath, imageRef, len(diff.Added), len(diff.Removed), len(diff.Modified))
+}
+
 func (po *PullOptions) validate() error {
 	if po.OutputPath == "" {
 		return fmt.Errorf("Expected --output to be none empty")
@@ -131,6 +238,32 @@ func (po *PullOptions) validate() error {
 		return fmt.Errorf("Cannot use --recursive (-r) flag when pulling a bundle")
 	}
 
+	if po.WithImagesAsLayout && len(po.ImageFlags.Image) > 0 {
+		return fmt.Errorf("Cannot use --with-images-as-layout flag when pulling an image (hint: use -b to pull a bundle)")
+	}
+
+	if len(po.ImageMapPath) > 0 && len(po.ImageFlags.Image) > 0 {
+		return fmt.Errorf("Cannot use --image-map flag when pulling an image (hint: use -b to pull a bundle)")
+	}
+
+	if po.VerifyOnly {
+		if po.Merge || po.Clean || po.WithImagesAsLayout || len(po.ImageMapPath) > 0 {
+			return fmt.Errorf("Cannot use --verify-only with --merge, --clean, --with-images-as-layout or --image-map")
+		}
+		if po.BundleRecursiveFlags.Recursive {
+			return fmt.Errorf("Cannot use --verify-only with --recursive (-r), nested bundles are not verified")
+		}
+	}
+
+	if po.OnConflict != "" {
+		if !po.Merge && !po.Clean {
+			return fmt.Errorf("Cannot use --on-conflict without --merge or --clean")
+		}
+		if _, err := image.NewConflictPolicy(po.OnConflict); err != nil {
+			return fmt.Errorf("Invalid --on-conflict: %s", err)
+		}
+	}
+
 	if !po.ImageIsBundleCheck && len(po.BundleFlags.Bundle) != 0 {
 		return fmt.Errorf("Cannot set --image-is-bundle-check while using -b flag")
 	}
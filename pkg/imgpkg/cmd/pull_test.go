@@ -33,6 +33,13 @@ func TestPullErrors(t *testing.T) {
 		require.ErrorContains(t, err, "Cannot use --recursive (-r) flag when pulling a bundle")
 	})
 
+	t.Run("fails when on-conflict flag is provided without merge or clean flags", func(t *testing.T) {
+		pull := PullOptions{OutputPath: "/tmp/some/place", BundleFlags: BundleFlags{"my-bundle"}, ImageIsBundleCheck: true, OnConflict: "skip"}
+		err := pull.Run()
+		require.Error(t, err)
+		require.ErrorContains(t, err, "Cannot use --on-conflict without --merge or --clean")
+	})
+
 	t.Run("fails when arguments are provided without a flag", func(t *testing.T) {
 		confUI := ui.NewConfUI(ui.NewNoopLogger())
 		defer confUI.Flush()
@@ -11,6 +11,7 @@ import (
 	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
 	"carvel.dev/imgpkg/pkg/imgpkg/plainimage"
 	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	v1 "carvel.dev/imgpkg/pkg/imgpkg/v1"
 	"github.com/cppforlife/go-cli-ui/ui"
 	regname "github.com/google/go-containerregistry/pkg/name"
 	"github.com/spf13/cobra"
@@ -25,6 +26,12 @@ type PushOptions struct {
 	FileFlags       FileFlags
 	RegistryFlags   RegistryFlags
 	LabelFlags      LabelFlags
+
+	VerifyImages        bool
+	LockImagesFromFiles bool
+	LockImagesJSONPaths []string
+	RequiredMetadata    []string
+	ValidateMetadata    bool
 }
 
 func NewPushOptions(ui ui.UI) *PushOptions {
@@ -40,6 +47,15 @@ func NewPushCmd(o *PushOptions) *cobra.Command {
   # Push bundle repo/app1-config with contents of config/ directory
   imgpkg push -b repo/app1-config -f config/
 
+  # Push bundle repo/app1-config after checking that every referenced image exists
+  imgpkg push -b repo/app1-config -f config/ --verify-images
+
+  # Push bundle repo/app1-config generating .imgpkg/images.yml from the images used in config/
+  imgpkg push -b repo/app1-config -f config/ --lock-images-from-files
+
+  # Push bundle repo/app1-config requiring .imgpkg/bundle.yml to provide a version in its metadata
+  imgpkg push -b repo/app1-config -f config/ --require-metadata version
+
   # Push image repo/app1-config with contents from multiple locations
   imgpkg push -i repo/app1-config -f config/ -f additional-config.yml`,
 	}
@@ -48,13 +64,32 @@ func NewPushCmd(o *PushOptions) *cobra.Command {
 	o.LockOutputFlags.SetOnPush(cmd)
 	o.FileFlags.Set(cmd)
 	o.RegistryFlags.Set(cmd)
+	o.RegistryFlags.SetReport(cmd)
 	o.LabelFlags.Set(cmd)
+	cmd.Flags().BoolVar(&o.VerifyImages, "verify-images", false, "Verify that every image referenced in .imgpkg/images.yml exists in its registry before pushing the bundle")
+	cmd.Flags().BoolVar(&o.LockImagesFromFiles, "lock-images-from-files", false, "Generate .imgpkg/images.yml in the pushed bundle from the images referenced in the YAML files (source files are not modified)")
+	cmd.Flags().StringSliceVar(&o.LockImagesJSONPaths, "lock-images-jsonpath", []string{bundle.DefaultImageJSONPath}, "JSONPath used to find image references in the YAML files when using --lock-images-from-files (can be specified multiple times)")
+	cmd.Flags().StringSliceVar(&o.RequiredMetadata, "require-metadata", nil, "Key that must be present in the metadata of .imgpkg/bundle.yml (can be specified multiple times)")
+	cmd.Flags().BoolVar(&o.ValidateMetadata, "validate-metadata", false, "Fail when .imgpkg/bundle.yml is invalid or contains unknown fields, instead of only warning (implied by --require-metadata)")
 
 	return cmd
 }
 
 func (po *PushOptions) Run() error {
-	reg, err := registry.NewSimpleRegistry(po.RegistryFlags.AsRegistryOpts())
+	err := po.run()
+	if reportErr := po.RegistryFlags.WriteReport(); err == nil {
+		err = reportErr
+	}
+	return err
+}
+
+func (po *PushOptions) run() error {
+	err := po.RegistryFlags.ResolveLayoutReferences(&po.BundleFlags.Bundle, &po.ImageFlags.Image)
+	if err != nil {
+		return err
+	}
+
+	reg, err := registry.NewSimpleRegistry(po.RegistryFlags.AsRegistryOpts(po.ui))
 	if err != nil {
 		return err
 	}
@@ -104,7 +139,49 @@ func (po *PushOptions) pushBundle(registry registry.Registry) (string, error) {
 	}
 
 	logger := util.NewUILevelLogger(util.LogWarn, util.NewLogger(po.ui))
-	imageURL, err := bundle.NewContents(po.FileFlags.Files, po.FileFlags.ExcludedFilePaths, po.FileFlags.PreservePermissions).Push(uploadRef, po.LabelFlags.Labels, registry, logger)
+	contents := bundle.NewContents(po.FileFlags.Files, po.FileFlags.ExcludedFilePaths, po.FileFlags.PreservePermissions)
+
+	if po.LockImagesFromFiles {
+		generator, err := bundle.NewImagesLockGenerator(po.FileFlags.Files, po.FileFlags.ExcludedFilePaths, po.LockImagesJSONPaths, registry, logger)
+		if err != nil {
+			return "", err
+		}
+
+		imagesLock, err := generator.Generate()
+		if err != nil {
+			return "", fmt.Errorf("Generating images lock from files: %s", err)
+		}
+
+		stagedContents, stagingDir, err := contents.StageWithImagesLock(imagesLock, logger)
+		if err != nil {
+			return "", err
+		}
+		defer bundle.RemoveStagingDir(stagingDir)
+
+		contents = stagedContents
+	}
+
+	metadataPath, err := contents.MetadataPath()
+	if err != nil {
+		return "", err
+	}
+
+	warnings, err := v1.ValidateMetadataFile(metadataPath, po.RequiredMetadata, po.ValidateMetadata || len(po.RequiredMetadata) > 0)
+	if err != nil {
+		return "", err
+	}
+	for _, warning := range warnings {
+		logger.Warnf("%s\n", warning)
+	}
+
+	if po.VerifyImages {
+		_, err := contents.VerifyImages(registry, logger)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	imageURL, err := contents.Push(uploadRef, po.LabelFlags.Labels, registry, logger)
 	if err != nil {
 		return "", err
 	}
@@ -135,6 +212,22 @@ func (po *PushOptions) pushImage(registry registry.Registry) (string, error) {
 		return "", fmt.Errorf("Lock output is not compatible with image, use bundle for lock output")
 	}
 
+	if po.VerifyImages {
+		return "", fmt.Errorf("Flag --verify-images is only compatible with bundle, use bundle to verify images")
+	}
+
+	if po.LockImagesFromFiles {
+		return "", fmt.Errorf("Flag --lock-images-from-files is only compatible with bundle, use bundle to generate images lock")
+	}
+
+	if len(po.RequiredMetadata) > 0 {
+		return "", fmt.Errorf("Flag --require-metadata is only compatible with bundle, use bundle to validate metadata")
+	}
+
+	if po.ValidateMetadata {
+		return "", fmt.Errorf("Flag --validate-metadata is only compatible with bundle, use bundle to validate metadata")
+	}
+
 	uploadRef, err := regname.NewTag(po.ImageFlags.Image, regname.WeakValidation)
 	if err != nil {
 		return "", fmt.Errorf("Parsing '%s': %s", po.ImageFlags.Image, err)
@@ -41,7 +41,11 @@ func NewTagListCmd(o *TagListOptions) *cobra.Command {
 }
 
 func (t *TagListOptions) Run() error {
-	tagInfo, err := v1.TagList(t.ImageFlags.Image, t.Digests, t.RegistryFlags.AsRegistryOpts())
+	if err := t.RegistryFlags.ResolveLayoutReferences(&t.ImageFlags.Image); err != nil {
+		return err
+	}
+
+	tagInfo, err := v1.TagList(t.ImageFlags.Image, t.Digests, t.RegistryFlags.AsRegistryOpts(t.ui))
 	if err != nil {
 		return err
 	}
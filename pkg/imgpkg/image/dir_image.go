@@ -20,38 +20,102 @@ type Logger interface {
 	Logf(msg string, args ...interface{})
 }
 
+// ExtractOpts options that control how an image is extracted into a directory
+type ExtractOpts struct {
+	// PathFilter only the entries that match the filter are extracted
+	PathFilter PathFilter
+	// Merge when true the image is extracted into the existing directory instead of replacing it
+	Merge bool
+	// OnConflict policy applied, when merging, to files that already exist in the directory (defaults to ConflictFail)
+	OnConflict ConflictPolicy
+	// Clean when true only the files extracted by a previous pull of the same Source are removed
+	// before extracting the image, every other file in the directory is kept. Implies Merge
+	Clean bool
+	// Source identifies where the image comes from, used by Clean to find the files of a previous pull
+	Source string
+}
+
 type DirImage struct {
 	dirPath     string
 	img         regv1.Image
 	shouldChown bool
 	logger      Logger
+	opts        ExtractOpts
 }
 
 // NewDirImage given an OCI Image representation creates a struct that will allow that image to be
 // extracted into the provided directory
 func NewDirImage(dirPath string, img regv1.Image, logger Logger) *DirImage {
-	return &DirImage{dirPath, img, os.Getuid() == 0, logger}
+	return NewDirImageWithOpts(dirPath, img, logger, ExtractOpts{})
+}
+
+// NewDirImageWithOpts given an OCI Image representation creates a struct that will allow that image to be
+// extracted into the provided directory using the provided options
+func NewDirImageWithOpts(dirPath string, img regv1.Image, logger Logger, opts ExtractOpts) *DirImage {
+	return &DirImage{dirPath, img, os.Getuid() == 0, logger, opts}
 }
 
 // AsDirectory extracts the OCI image to the provided location in disk
 func (i *DirImage) AsDirectory() error {
-	err := os.RemoveAll(i.dirPath)
-	if err != nil {
-		return fmt.Errorf("Removing output directory: %s", err)
+	if !i.merging() {
+		err := os.RemoveAll(i.dirPath)
+		if err != nil {
+			return fmt.Errorf("Removing output directory: %s", err)
+		}
 	}
 
-	err = os.MkdirAll(i.dirPath, 0777)
+	err := os.MkdirAll(i.dirPath, 0777)
 	if err != nil {
 		return fmt.Errorf("Creating output directory: %s", err)
 	}
 
+	if i.opts.Clean {
+		err = i.cleanPreviousPull()
+		if err != nil {
+			return err
+		}
+	}
+
 	layers, err := i.img.Layers()
 	if err != nil {
 		return err
 	}
 
+	if i.merging() && i.onConflict() == ConflictFail {
+		err = i.checkConflicts(layers)
+		if err != nil {
+			return err
+		}
+	}
+
+	var previousFiles []string
+	if i.merging() {
+		state, _, err := i.readPullState()
+		if err != nil {
+			return err
+		}
+		previousFiles = state.Files
+	}
+
 	fileMap := map[string]bool{}
+	var extractedFiles []string
+
+	err = i.forEachLayer(layers, "Extracting", func(layerStream io.Reader) error {
+		return i.writeLayer(fileMap, previousFiles, &extractedFiles, layerStream)
+	})
+	if err != nil {
+		return err
+	}
 
+	if i.merging() {
+		return i.writePullState(extractedFiles)
+	}
+
+	return nil
+}
+
+// forEachLayer calls fn with the uncompressed contents of each layer
+func (i *DirImage) forEachLayer(layers []regv1.Layer, action string, fn func(io.Reader) error) error {
 	// we iterate through the layers in reverse order because it makes handling
 	// whiteout layers more efficient, since we can just keep track of the removed
 	// files as we see .wh. layers and ignore those in previous layers.
@@ -62,16 +126,15 @@ func (i *DirImage) AsDirectory() error {
 			return err
 		}
 
-		i.logger.Logf("Extracting layer '%s' (%d/%d)\n", digest, len(layers)-idx, len(layers))
+		i.logger.Logf("%s layer '%s' (%d/%d)\n", action, digest, len(layers)-idx, len(layers))
 
 		layerStream, err := imgLayer.Uncompressed()
 		if err != nil {
 			return err
 		}
 
-		defer layerStream.Close()
-
-		err = i.writeLayer(fileMap, layerStream)
+		err = fn(layerStream)
+		layerStream.Close()
 		if err != nil {
 			return err
 		}
@@ -82,7 +145,7 @@ func (i *DirImage) AsDirectory() error {
 
 // Taken from https://github.com/concourse/registry-image-resource/blob/b5481130ad61bc74e0a74f9b00b287b3a24bab88/cmd/in/unpack.go
 
-func (i *DirImage) writeLayer(fileMap map[string]bool, stream io.Reader) error {
+func (i *DirImage) writeLayer(fileMap map[string]bool, previousFiles []string, extractedFiles *[]string, stream io.Reader) error {
 	tarReader := tar.NewReader(stream)
 
 	for {
@@ -102,16 +165,23 @@ func (i *DirImage) writeLayer(fileMap map[string]bool, stream io.Reader) error {
 		)
 
 		if strings.HasPrefix(base, whiteoutPrefix) {
-			dir := filepath.Dir(path)
+			whiteoutName := hdr.Name[:len(hdr.Name)-len(base)] + strings.TrimPrefix(base, whiteoutPrefix)
+			if !i.opts.PathFilter.Matches(whiteoutName) {
+				continue
+			}
 
-			err := os.RemoveAll(filepath.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+			err := i.removeWhiteout(fileMap, previousFiles, filepath.Join(filepath.Dir(path), strings.TrimPrefix(base, whiteoutPrefix)))
 			if err != nil {
-				return nil
+				return err
 			}
 			fileMap[base] = true
 			continue
 		}
 
+		if !i.opts.PathFilter.Matches(hdr.Name) {
+			continue
+		}
+
 		// check for a whited out parent directory
 		if inWhiteoutDir(fileMap, path) {
 			continue
@@ -122,6 +192,11 @@ func (i *DirImage) writeLayer(fileMap map[string]bool, stream io.Reader) error {
 				continue
 			}
 			if !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+				// files that were not extracted by this pull belong to the user when merging
+				if i.merging() && !fileMap[hdr.Name] && i.onConflict() == ConflictSkip {
+					i.logger.Logf("Skipping '%s', file already exists\n", hdr.Name)
+					continue
+				}
 				if err := os.RemoveAll(path); err != nil {
 					return err
 				}
@@ -133,11 +208,39 @@ func (i *DirImage) writeLayer(fileMap map[string]bool, stream io.Reader) error {
 		if err != nil {
 			return err
 		}
+		if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+			*extractedFiles = append(*extractedFiles, hdr.Name)
+		}
 	}
 
 	return nil
 }
 
+// removeWhiteout removes the whited out path. When merging only the files extracted by this pull, or by the
+// previous one, are removed because every other file in the directory belongs to the user
+func (i *DirImage) removeWhiteout(fileMap map[string]bool, previousFiles []string, whiteoutPath string) error {
+	if !i.merging() {
+		return os.RemoveAll(whiteoutPath)
+	}
+
+	pulledFiles := append([]string{}, previousFiles...)
+	for file := range fileMap {
+		pulledFiles = append(pulledFiles, file)
+	}
+
+	for _, file := range pulledFiles {
+		path := i.hydrateFilepath(file)
+		if path != whiteoutPath && !strings.HasPrefix(path, whiteoutPath+string(filepath.Separator)) {
+			continue
+		}
+		err := os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func inWhiteoutDir(fileMap map[string]bool, file string) bool {
 	for {
 		if file == "" {
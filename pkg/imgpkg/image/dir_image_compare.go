@@ -0,0 +1,261 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DirDiff files that differ between the contents of an image and a directory
+type DirDiff struct {
+	// Added files present in the directory but not in the image
+	Added []string `json:"added,omitempty"`
+	// Removed files present in the image but not in the directory
+	Removed []string `json:"removed,omitempty"`
+	// Modified files present in both with different contents
+	Modified []string `json:"modified,omitempty"`
+}
+
+// HasChanges returns true when the directory does not match the image
+func (d DirDiff) HasChanges() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Modified) > 0
+}
+
+// Compare checks the files in the directory against the contents of the image without extracting it.
+// Only regular files are compared, and the ignoredPaths (relative to the directory) are not checked
+func (i *DirImage) Compare(ignoredPaths ...string) (DirDiff, error) {
+	fi, err := os.Stat(i.dirPath)
+	if err != nil {
+		return DirDiff{}, fmt.Errorf("Reading directory '%s': %s", i.dirPath, err)
+	}
+	if !fi.IsDir() {
+		return DirDiff{}, fmt.Errorf("Expected '%s' to be a directory", i.dirPath)
+	}
+
+	isIgnored := func(relPath string) bool {
+		for _, ignoredPath := range ignoredPaths {
+			if matchesPathOrParent(normalizePath(ignoredPath), relPath) {
+				return true
+			}
+		}
+		return false
+	}
+
+	imageFiles, err := i.fileDigests("Verifying", isIgnored)
+	if err != nil {
+		return DirDiff{}, err
+	}
+
+	dirFiles := map[string]string{}
+	err = filepath.Walk(i.dirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(i.dirPath, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPath == "." {
+			return nil
+		}
+
+		if isIgnored(relPath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.Mode().IsRegular() || relPath == PullStateFile || !i.opts.PathFilter.Matches(relPath) {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		dirFiles[relPath], err = hashContents(file)
+		return err
+	})
+	if err != nil {
+		return DirDiff{}, fmt.Errorf("Reading directory '%s': %s", i.dirPath, err)
+	}
+
+	diff := DirDiff{}
+	for path, imageHash := range imageFiles {
+		dirHash, found := dirFiles[path]
+		switch {
+		case !found:
+			diff.Removed = append(diff.Removed, path)
+		case dirHash != imageHash:
+			diff.Modified = append(diff.Modified, path)
+		}
+	}
+	for path := range dirFiles {
+		if _, found := imageFiles[path]; !found {
+			diff.Added = append(diff.Added, path)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+
+	return diff, nil
+}
+
+// FileDigests returns the sha256 of every regular file in the image, keyed by the path of the file
+func (i *DirImage) FileDigests() (map[string]string, error) {
+	return i.fileDigests("Reading", func(string) bool { return false })
+}
+
+// ReadFile returns the contents of the regular file present in filePath, false is returned when the image does not contain the file
+func (i *DirImage) ReadFile(filePath string) ([]byte, bool, error) {
+	filePath = normalizePath(filePath)
+
+	layers, err := i.img.Layers()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var contents []byte
+	found := false
+	errFound := errors.New("found")
+	err = i.forEachLayer(layers, "Reading", func(layerStream io.Reader) error {
+		tarReader := tar.NewReader(layerStream)
+		for {
+			hdr, err := tarReader.Next()
+			if err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+
+			relPath, err := filepath.Rel(i.dirPath, i.hydrateFilepath(hdr.Name))
+			if err != nil {
+				return err
+			}
+			relPath = filepath.ToSlash(relPath)
+
+			const whiteoutPrefix = ".wh."
+			if base := filepath.Base(relPath); strings.HasPrefix(base, whiteoutPrefix) {
+				// the file, or one of its parents, was removed by this layer
+				removedPath := filepath.ToSlash(filepath.Join(filepath.Dir(relPath), strings.TrimPrefix(base, whiteoutPrefix)))
+				if matchesPathOrParent(removedPath, filePath) {
+					return errFound
+				}
+				continue
+			}
+			if relPath != filePath || (hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA) {
+				continue
+			}
+
+			contents, err = io.ReadAll(tarReader)
+			if err != nil {
+				return err
+			}
+			found = true
+			return errFound
+		}
+	})
+	if err != nil && err != errFound {
+		return nil, false, err
+	}
+
+	return contents, found, nil
+}
+
+func (i *DirImage) fileDigests(action string, isIgnored func(string) bool) (map[string]string, error) {
+	layers, err := i.img.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	imageFiles := map[string]string{}
+	seen := map[string]bool{}
+	whiteouts := map[string]bool{}
+	err = i.forEachLayer(layers, action, func(layerStream io.Reader) error {
+		return i.hashLayerFiles(layerStream, imageFiles, seen, whiteouts, isIgnored)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return imageFiles, nil
+}
+
+// hashLayerFiles records the hash of every regular file in the layer that was not already found in a newer layer
+func (i *DirImage) hashLayerFiles(stream io.Reader, imageFiles map[string]string, seen, whiteouts map[string]bool, isIgnored func(string) bool) error {
+	tarReader := tar.NewReader(stream)
+
+	for {
+		hdr, err := tarReader.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		relPath, err := filepath.Rel(i.dirPath, i.hydrateFilepath(hdr.Name))
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		const whiteoutPrefix = ".wh."
+		if base := filepath.Base(relPath); strings.HasPrefix(base, whiteoutPrefix) {
+			whiteouts[filepath.ToSlash(filepath.Join(filepath.Dir(relPath), strings.TrimPrefix(base, whiteoutPrefix)))] = true
+			continue
+		}
+
+		if relPath == "." || seen[relPath] || isWhitedOut(whiteouts, relPath) || isIgnored(relPath) || !i.opts.PathFilter.Matches(hdr.Name) {
+			continue
+		}
+		seen[relPath] = true
+
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		imageFiles[relPath], err = hashContents(tarReader)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isWhitedOut checks if the path, or any of its parents, was removed by a newer layer
+func isWhitedOut(whiteouts map[string]bool, relPath string) bool {
+	for p := relPath; p != "." && p != "/" && p != ""; p = filepath.ToSlash(filepath.Dir(p)) {
+		if whiteouts[p] {
+			return true
+		}
+	}
+	return false
+}
+
+func hashContents(reader io.Reader) (string, error) {
+	hash := sha256.New()
+	_, err := io.Copy(hash, reader)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
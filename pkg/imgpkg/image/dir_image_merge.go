@@ -0,0 +1,213 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// ConflictPolicy defines what happens when, while merging, a file being extracted already exists in the directory
+type ConflictPolicy string
+
+const (
+	// ConflictOverwrite replaces the existing file with the one in the image
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictSkip keeps the existing file and does not extract the one in the image
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictFail stops before extracting anything when any file already exists
+	ConflictFail ConflictPolicy = "fail"
+
+	// PullStateFile file, created in the output directory, that keeps track of the files extracted by the last pull
+	PullStateFile = ".imgpkg-pull-state.yml"
+)
+
+// ConflictPolicies all the supported conflict policies
+var ConflictPolicies = []ConflictPolicy{ConflictOverwrite, ConflictSkip, ConflictFail}
+
+// NewConflictPolicy converts the provided string into a ConflictPolicy, failing if the policy is not supported
+func NewConflictPolicy(policy string) (ConflictPolicy, error) {
+	for _, p := range ConflictPolicies {
+		if string(p) == policy {
+			return p, nil
+		}
+	}
+
+	var supported []string
+	for _, p := range ConflictPolicies {
+		supported = append(supported, string(p))
+	}
+	return "", fmt.Errorf("Unknown conflict policy '%s' (supported: %s)", policy, strings.Join(supported, ", "))
+}
+
+// pullState information stored in the PullStateFile
+type pullState struct {
+	Source string   `json:"source"`
+	Files  []string `json:"files"`
+}
+
+func (i *DirImage) merging() bool {
+	return i.opts.Merge || i.opts.Clean
+}
+
+func (i *DirImage) onConflict() ConflictPolicy {
+	if i.opts.OnConflict == "" {
+		return ConflictFail
+	}
+	return i.opts.OnConflict
+}
+
+// cleanPreviousPull removes the files extracted by a previous pull of the same source, leaving every other file untouched
+func (i *DirImage) cleanPreviousPull() error {
+	state, found, err := i.readPullState()
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+
+	if state.Source != i.opts.Source {
+		i.logger.Logf("Not removing files from previous pull: directory was pulled from '%s'\n", state.Source)
+		return nil
+	}
+
+	dirsToCheck := map[string]bool{}
+	for _, file := range state.Files {
+		path := i.hydrateFilepath(file)
+		if !i.insideDirPath(path) {
+			return fmt.Errorf("Removing files from previous pull: file '%s' is outside of the output directory", file)
+		}
+
+		err := os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("Removing files from previous pull: %s", err)
+		}
+
+		for dir := filepath.Dir(path); i.insideDirPath(dir) && dir != filepath.Clean(i.dirPath); dir = filepath.Dir(dir) {
+			dirsToCheck[dir] = true
+		}
+	}
+
+	// remove the folders that became empty, starting from the deepest ones
+	var dirs []string
+	for dir := range dirsToCheck {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(a, b int) bool { return len(dirs[a]) > len(dirs[b]) })
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err == nil && len(entries) == 0 {
+			_ = os.Remove(dir)
+		}
+	}
+
+	return nil
+}
+
+// checkConflicts fails when any of the files in the layers already exists in the directory
+func (i *DirImage) checkConflicts(layers []regv1.Layer) error {
+	conflicts := map[string]bool{}
+
+	for _, imgLayer := range layers {
+		layerStream, err := imgLayer.Uncompressed()
+		if err != nil {
+			return err
+		}
+
+		tarReader := tar.NewReader(layerStream)
+		for {
+			hdr, err := tarReader.Next()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				layerStream.Close()
+				return err
+			}
+
+			if strings.HasPrefix(filepath.Base(hdr.Name), ".wh.") || !i.opts.PathFilter.Matches(hdr.Name) {
+				continue
+			}
+
+			fi, err := os.Lstat(i.hydrateFilepath(hdr.Name))
+			if err != nil || (fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+				continue
+			}
+			conflicts[hdr.Name] = true
+		}
+		layerStream.Close()
+	}
+
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	var files []string
+	for file := range conflicts {
+		files = append(files, "- "+file)
+	}
+	sort.Strings(files)
+
+	return fmt.Errorf("Files already present in '%s':\n%s\n(hint: use --on-conflict=overwrite or --on-conflict=skip, or --clean to remove the files of the previous pull)",
+		i.dirPath, strings.Join(files, "\n"))
+}
+
+func (i *DirImage) readPullState() (pullState, bool, error) {
+	contents, err := os.ReadFile(filepath.Join(i.dirPath, PullStateFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return pullState{}, false, nil
+		}
+		return pullState{}, false, fmt.Errorf("Reading pull state: %s", err)
+	}
+
+	var state pullState
+	err = yaml.Unmarshal(contents, &state)
+	if err != nil {
+		return pullState{}, false, fmt.Errorf("Unmarshaling pull state '%s': %s", PullStateFile, err)
+	}
+
+	return state, true, nil
+}
+
+func (i *DirImage) writePullState(extractedFiles []string) error {
+	seen := map[string]bool{}
+	var files []string
+	for _, file := range extractedFiles {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	contents, err := yaml.Marshal(pullState{Source: i.opts.Source, Files: files})
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filepath.Join(i.dirPath, PullStateFile), contents, 0600)
+	if err != nil {
+		return fmt.Errorf("Writing pull state: %s", err)
+	}
+	return nil
+}
+
+func (i *DirImage) insideDirPath(path string) bool {
+	relPath, err := filepath.Rel(i.dirPath, path)
+	if err != nil {
+		return false
+	}
+	return relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
@@ -4,7 +4,10 @@
 package image_test
 
 import (
+	"archive/tar"
+	"bytes"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -14,6 +17,10 @@ import (
 
 	"carvel.dev/imgpkg/pkg/imgpkg/image"
 	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -99,4 +106,206 @@ func TestDirImage(t *testing.T) {
 			return nil
 		})
 	})
+	t.Run("When extracting with a path filter only the matching files are written", func(t *testing.T) {
+		img, err := image.NewFileImage(filepath.Join("test_assets", "img_tar_with_permissions.tar"), nil)
+		require.NoError(t, err)
+		folder := t.TempDir()
+
+		pathFilter, err := image.NewPathFilter([]string{"folder_all"}, []string{"folder_all/*.sh"})
+		require.NoError(t, err)
+
+		imgDir := image.NewDirImageWithOpts(folder, img, util.NewNoopLogger(), image.ExtractOpts{PathFilter: pathFilter})
+		require.NoError(t, imgDir.AsDirectory())
+
+		assert.FileExists(t, filepath.Join(folder, "folder_all", "some_file.txt"))
+		assert.NoFileExists(t, filepath.Join(folder, "folder_all", "exec_perm_all.sh"))
+		assert.NoDirExists(t, filepath.Join(folder, "folder_group"))
+	})
+}
+
+func TestDirImageMerge(t *testing.T) {
+	img, err := image.NewFileImage(filepath.Join("test_assets", "img_tar_with_permissions.tar"), nil)
+	require.NoError(t, err)
+
+	imageFile := filepath.Join("folder_all", "some_file.txt")
+
+	setup := func(t *testing.T) string {
+		folder := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(folder, "local.txt"), []byte("local"), 0600))
+		return folder
+	}
+
+	t.Run("keeps the files already present in the directory", func(t *testing.T) {
+		folder := setup(t)
+
+		imgDir := image.NewDirImageWithOpts(folder, img, util.NewNoopLogger(), image.ExtractOpts{Merge: true, Source: "repo/app"})
+		require.NoError(t, imgDir.AsDirectory())
+
+		assert.FileExists(t, filepath.Join(folder, "local.txt"))
+		assert.FileExists(t, filepath.Join(folder, imageFile))
+		assert.FileExists(t, filepath.Join(folder, image.PullStateFile))
+	})
+
+	t.Run("fails without changing the directory when a file already exists", func(t *testing.T) {
+		folder := setup(t)
+		require.NoError(t, os.MkdirAll(filepath.Join(folder, "folder_all"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(folder, imageFile), []byte("local"), 0600))
+
+		imgDir := image.NewDirImageWithOpts(folder, img, util.NewNoopLogger(), image.ExtractOpts{Merge: true, OnConflict: image.ConflictFail})
+		err := imgDir.AsDirectory()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "- folder_all/some_file.txt")
+		assert.NoDirExists(t, filepath.Join(folder, "folder_group"))
+	})
+
+	t.Run("skips or overwrites the files already present based on the conflict policy", func(t *testing.T) {
+		for _, policy := range []image.ConflictPolicy{image.ConflictSkip, image.ConflictOverwrite} {
+			folder := setup(t)
+			require.NoError(t, os.MkdirAll(filepath.Join(folder, "folder_all"), 0700))
+			require.NoError(t, os.WriteFile(filepath.Join(folder, imageFile), []byte("local"), 0600))
+
+			imgDir := image.NewDirImageWithOpts(folder, img, util.NewNoopLogger(), image.ExtractOpts{Merge: true, OnConflict: policy})
+			require.NoError(t, imgDir.AsDirectory())
+
+			contents, err := os.ReadFile(filepath.Join(folder, imageFile))
+			require.NoError(t, err)
+			if policy == image.ConflictSkip {
+				assert.Equal(t, "local", string(contents))
+			} else {
+				assert.NotEqual(t, "local", string(contents))
+			}
+		}
+	})
+
+	t.Run("whiteouts only remove the files extracted by a pull", func(t *testing.T) {
+		folder := setup(t)
+		require.NoError(t, os.MkdirAll(filepath.Join(folder, "local-dir"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(folder, "local-dir", "file.txt"), []byte("local"), 0600))
+
+		pulledImg := imageWithLayers(t, map[string]string{"pulled.txt": "pulled"})
+		opts := image.ExtractOpts{Merge: true, OnConflict: image.ConflictSkip, Source: "repo/app"}
+		require.NoError(t, image.NewDirImageWithOpts(folder, pulledImg, util.NewNoopLogger(), opts).AsDirectory())
+		require.FileExists(t, filepath.Join(folder, "pulled.txt"))
+
+		whiteoutImg := imageWithLayers(t, map[string]string{".wh.local.txt": "", ".wh.local-dir": "", ".wh.pulled.txt": ""})
+		require.NoError(t, image.NewDirImageWithOpts(folder, whiteoutImg, util.NewNoopLogger(), opts).AsDirectory())
+
+		assert.FileExists(t, filepath.Join(folder, "local.txt"))
+		assert.FileExists(t, filepath.Join(folder, "local-dir", "file.txt"))
+		assert.NoFileExists(t, filepath.Join(folder, "pulled.txt"))
+	})
+
+	t.Run("clean only removes the files from a previous pull of the same source", func(t *testing.T) {
+		folder := setup(t)
+
+		opts := image.ExtractOpts{Clean: true, Source: "repo/app"}
+		require.NoError(t, image.NewDirImageWithOpts(folder, img, util.NewNoopLogger(), opts).AsDirectory())
+		require.NoError(t, os.WriteFile(filepath.Join(folder, "folder_group", "stale.txt"), []byte("stale"), 0600))
+
+		// no conflicts because the previous pulled files are removed first
+		require.NoError(t, image.NewDirImageWithOpts(folder, img, util.NewNoopLogger(), opts).AsDirectory())
+		assert.FileExists(t, filepath.Join(folder, "local.txt"))
+		assert.FileExists(t, filepath.Join(folder, "folder_group", "stale.txt"))
+		assert.FileExists(t, filepath.Join(folder, imageFile))
+
+		opts.Source = "repo/other"
+		err := image.NewDirImageWithOpts(folder, img, util.NewNoopLogger(), opts).AsDirectory()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Files already present")
+	})
+}
+
+func TestDirImageCompare(t *testing.T) {
+	img, err := image.NewFileImage(filepath.Join("test_assets", "img_tar_with_permissions.tar"), nil)
+	require.NoError(t, err)
+
+	folder := t.TempDir()
+	require.NoError(t, image.NewDirImage(folder, img, util.NewNoopLogger()).AsDirectory())
+
+	t.Run("reports no changes right after extracting", func(t *testing.T) {
+		diff, err := image.NewDirImage(folder, img, util.NewNoopLogger()).Compare()
+		require.NoError(t, err)
+		assert.False(t, diff.HasChanges())
+	})
+
+	t.Run("reports added, removed and modified files", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(folder, "new.txt"), []byte("new"), 0600))
+		require.NoError(t, os.Remove(filepath.Join(folder, "folder_all", "exec_perm_all.sh")))
+		require.NoError(t, os.WriteFile(filepath.Join(folder, "folder_group", "some_other.txt"), []byte("hand edit"), 0600))
+
+		diff, err := image.NewDirImage(folder, img, util.NewNoopLogger()).Compare()
+		require.NoError(t, err)
+		assert.Equal(t, image.DirDiff{
+			Added:    []string{"new.txt"},
+			Removed:  []string{"folder_all/exec_perm_all.sh"},
+			Modified: []string{"folder_group/some_other.txt"},
+		}, diff)
+
+		diff, err = image.NewDirImage(folder, img, util.NewNoopLogger()).Compare("new.txt", "folder_group")
+		require.NoError(t, err)
+		assert.Equal(t, image.DirDiff{Removed: []string{"folder_all/exec_perm_all.sh"}}, diff)
+	})
+
+	t.Run("fails when the directory does not exist", func(t *testing.T) {
+		_, err := image.NewDirImage(filepath.Join(folder, "missing"), img, util.NewNoopLogger()).Compare()
+		require.Error(t, err)
+	})
+}
+
+func TestPathFilter(t *testing.T) {
+	tests := []struct {
+		desc     string
+		include  []string
+		exclude  []string
+		path     string
+		expected bool
+	}{
+		{desc: "no patterns", path: "config/app.yml", expected: true},
+		{desc: "include folder", include: []string{"config"}, path: "config/nested/app.yml", expected: true},
+		{desc: "include does not match", include: []string{"config"}, path: "docs/README.md", expected: false},
+		{desc: "include glob", include: []string{"config/*.yml"}, path: "config/app.yml", expected: true},
+		{desc: "include double star", include: []string{"**/*.yml"}, path: "a/b/c/app.yml", expected: true},
+		{desc: "exclude wins over include", include: []string{"config"}, exclude: []string{"config/**/*_test.yml"}, path: "config/a/app_test.yml", expected: false},
+		{desc: "leading ./ is ignored", include: []string{"./config/"}, path: "./config/app.yml", expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			subject, err := image.NewPathFilter(test.include, test.exclude)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, subject.Matches(test.path))
+		})
+	}
+
+	t.Run("always included paths ignore the patterns", func(t *testing.T) {
+		subject, err := image.NewPathFilter([]string{"config"}, []string{".imgpkg"})
+		require.NoError(t, err)
+		subject = subject.WithAlwaysIncluded(".imgpkg")
+		assert.True(t, subject.Matches(".imgpkg/images.yml"))
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := image.NewPathFilter([]string{"config/[a"}, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid include path 'config/[a'")
+	})
+}
+
+func imageWithLayers(t *testing.T, files map[string]string) regv1.Image {
+	buf := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(buf)
+	for name, contents := range files {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+		_, err := tarWriter.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	return img
 }
@@ -0,0 +1,154 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PathFilter selects which entries of an image are extracted.
+// Patterns are slash separated paths relative to the root of the image, where each segment can use
+// the wildcards supported by path.Match and '**' matches any number of segments.
+// A pattern that matches a directory also matches everything inside of it
+type PathFilter struct {
+	include        []string
+	exclude        []string
+	alwaysIncluded []string
+}
+
+// NewPathFilter creates a PathFilter that keeps the entries matching any include pattern (or all entries when no include
+// pattern is provided) and that do not match any exclude pattern
+func NewPathFilter(include []string, exclude []string) (PathFilter, error) {
+	filter := PathFilter{}
+	for _, pattern := range include {
+		normalized, err := normalizePattern(pattern)
+		if err != nil {
+			return PathFilter{}, fmt.Errorf("Invalid include path '%s': %s", pattern, err)
+		}
+		filter.include = append(filter.include, normalized)
+	}
+	for _, pattern := range exclude {
+		normalized, err := normalizePattern(pattern)
+		if err != nil {
+			return PathFilter{}, fmt.Errorf("Invalid exclude path '%s': %s", pattern, err)
+		}
+		filter.exclude = append(filter.exclude, normalized)
+	}
+	return filter, nil
+}
+
+// WithAlwaysIncluded returns a copy of the filter where the provided paths, and everything inside of them, are always
+// extracted independently of the include and exclude patterns
+func (f PathFilter) WithAlwaysIncluded(paths ...string) PathFilter {
+	result := PathFilter{
+		include:        append([]string{}, f.include...),
+		exclude:        append([]string{}, f.exclude...),
+		alwaysIncluded: append([]string{}, f.alwaysIncluded...),
+	}
+	for _, p := range paths {
+		result.alwaysIncluded = append(result.alwaysIncluded, normalizePath(p))
+	}
+	return result
+}
+
+// IsEmpty returns true when the filter does not have any include or exclude pattern
+func (f PathFilter) IsEmpty() bool {
+	return len(f.include) == 0 && len(f.exclude) == 0
+}
+
+// Matches returns true when the entry in entryPath should be extracted
+func (f PathFilter) Matches(entryPath string) bool {
+	if f.IsEmpty() {
+		return true
+	}
+
+	entryPath = normalizePath(entryPath)
+	if entryPath == "" || entryPath == "." {
+		return true
+	}
+
+	for _, p := range f.alwaysIncluded {
+		if matchesPathOrParent(p, entryPath) {
+			return true
+		}
+	}
+
+	for _, pattern := range f.exclude {
+		if matchesPathOrParent(pattern, entryPath) {
+			return false
+		}
+	}
+
+	if len(f.include) == 0 {
+		return true
+	}
+
+	for _, pattern := range f.include {
+		if matchesPathOrParent(pattern, entryPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPathOrParent checks if the pattern matches the path or any of the parent folders of the path
+func matchesPathOrParent(pattern, entryPath string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(entryPath, "/")
+	for i := len(pathSegments); i > 0; i-- {
+		if matchSegments(patternSegments, pathSegments[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	matched, err := path.Match(pattern[0], segments[0])
+	if err != nil || !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+func normalizePattern(pattern string) (string, error) {
+	normalized := normalizePath(pattern)
+	if normalized == "" || normalized == "." {
+		return "", fmt.Errorf("pattern cannot be empty")
+	}
+	for _, segment := range strings.Split(normalized, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return "", err
+		}
+	}
+	return normalized, nil
+}
+
+// normalizePath converts the path to a slash separated path without leading or trailing slashes
+func normalizePath(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	p = path.Clean(p)
+	p = strings.TrimPrefix(p, "/")
+	p = strings.TrimPrefix(p, "./")
+	return p
+}
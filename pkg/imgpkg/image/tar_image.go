@@ -173,6 +173,10 @@ func (i *TarImage) addFileToTar(fullPath, relPath string, info os.FileInfo, tarW
 }
 
 func (i *TarImage) isExcluded(relPath string) bool {
+	// the state left by pull --merge/--clean belongs to the directory, not to its contents
+	if relPath == PullStateFile {
+		return true
+	}
 	for _, path := range i.excludePaths {
 		if path == relPath {
 			return true
@@ -4,6 +4,10 @@
 package image_test
 
 import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 
@@ -43,3 +47,31 @@ func TestTarImage(t *testing.T) {
 type testLogger struct{}
 
 func (l testLogger) Logf(string, ...interface{}) {}
+
+func TestTarImage_ExcludesPullState(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file.yml"), []byte("content"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, image.PullStateFile), []byte("files: [file.yml]"), 0600))
+
+	img, err := image.NewTarImage([]string{dir}, nil, testLogger{}, false).AsFileImage(nil)
+	require.NoError(t, err)
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+	content, err := layers[0].Uncompressed()
+	require.NoError(t, err)
+	defer content.Close()
+
+	var files []string
+	tarReader := tar.NewReader(content)
+	for {
+		hdr, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		files = append(files, hdr.Name)
+	}
+	require.Contains(t, files, "file.yml")
+	require.NotContains(t, files, image.PullStateFile)
+}
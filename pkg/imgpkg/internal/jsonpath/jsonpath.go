@@ -0,0 +1,221 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package jsonpath implements a small subset of JSONPath used to select fields from YAML/JSON documents
+//
+// Supported syntax:
+//
+//	$            root of the document (optional)
+//	.key         child named key
+//	['key']      child named key (allows keys with dots)
+//	..key        every descendant named key
+//	.* or [*]    every child of a map or array
+//	[N]          element N of an array
+//
+// Expressions can also be wrapped in curly braces, i.e. {.image}
+package jsonpath
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type stepKind int
+
+const (
+	childStep stepKind = iota
+	descendantStep
+	wildcardStep
+	indexStep
+)
+
+type step struct {
+	kind  stepKind
+	key   string
+	index int
+}
+
+// Path parsed JSONPath expression
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// Parse the provided expression into a Path
+func Parse(expr string) (Path, error) {
+	original := expr
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "{") && strings.HasSuffix(expr, "}") {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	expr = strings.TrimPrefix(expr, "$")
+	if expr == "" {
+		return Path{expr: original}, nil
+	}
+
+	var steps []step
+	for len(expr) > 0 {
+		switch {
+		case strings.HasPrefix(expr, ".."):
+			key, rest := readKey(expr[2:])
+			if key == "" {
+				return Path{}, fmt.Errorf("Parsing JSONPath '%s': expected field name after '..'", original)
+			}
+			steps = append(steps, step{kind: descendantStep, key: key})
+			expr = rest
+
+		case strings.HasPrefix(expr, ".*"):
+			steps = append(steps, step{kind: wildcardStep})
+			expr = expr[2:]
+
+		case strings.HasPrefix(expr, "."):
+			key, rest := readKey(expr[1:])
+			if key == "" {
+				return Path{}, fmt.Errorf("Parsing JSONPath '%s': expected field name after '.'", original)
+			}
+			steps = append(steps, step{kind: childStep, key: key})
+			expr = rest
+
+		case strings.HasPrefix(expr, "["):
+			end := strings.Index(expr, "]")
+			if end == -1 {
+				return Path{}, fmt.Errorf("Parsing JSONPath '%s': missing closing ']'", original)
+			}
+			selector := strings.TrimSpace(expr[1:end])
+			expr = expr[end+1:]
+
+			switch {
+			case selector == "*":
+				steps = append(steps, step{kind: wildcardStep})
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				steps = append(steps, step{kind: childStep, key: selector[1 : len(selector)-1]})
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil {
+					return Path{}, fmt.Errorf("Parsing JSONPath '%s': unsupported selector '[%s]'", original, selector)
+				}
+				steps = append(steps, step{kind: indexStep, index: index})
+			}
+
+		default:
+			return Path{}, fmt.Errorf("Parsing JSONPath '%s': unexpected '%s'", original, expr)
+		}
+	}
+
+	return Path{expr: original, steps: steps}, nil
+}
+
+// MustParse parses the expression and panics if it is not valid
+func MustParse(expr string) Path {
+	path, err := Parse(expr)
+	if err != nil {
+		panic(err.Error())
+	}
+	return path
+}
+
+// String returns the original expression
+func (p Path) String() string { return p.expr }
+
+// Find returns all the values in data that match the path.
+// data is expected to contain the result of unmarshaling YAML or JSON into an interface{}
+func (p Path) Find(data interface{}) []interface{} {
+	current := []interface{}{data}
+	for _, s := range p.steps {
+		var next []interface{}
+		for _, value := range current {
+			next = append(next, s.apply(value)...)
+		}
+		current = next
+	}
+	return current
+}
+
+// FindStrings returns all the string values in data that match the path, any non-string match is ignored
+func (p Path) FindStrings(data interface{}) []string {
+	var result []string
+	for _, value := range p.Find(data) {
+		if str, ok := value.(string); ok {
+			result = append(result, str)
+		}
+	}
+	return result
+}
+
+func (s step) apply(value interface{}) []interface{} {
+	switch s.kind {
+	case childStep:
+		if m, ok := value.(map[string]interface{}); ok {
+			if child, found := m[s.key]; found {
+				return []interface{}{child}
+			}
+		}
+		return nil
+
+	case wildcardStep:
+		return children(value)
+
+	case indexStep:
+		arr, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		index := s.index
+		if index < 0 {
+			index += len(arr)
+		}
+		if index < 0 || index >= len(arr) {
+			return nil
+		}
+		return []interface{}{arr[index]}
+
+	case descendantStep:
+		var result []interface{}
+		if m, ok := value.(map[string]interface{}); ok {
+			if child, found := m[s.key]; found {
+				result = append(result, child)
+			}
+		}
+		for _, child := range children(value) {
+			result = append(result, s.apply(child)...)
+		}
+		return result
+
+	default:
+		panic(fmt.Sprintf("Internal inconsistency: unknown JSONPath step %d", s.kind))
+	}
+}
+
+// children returns the values of a map, sorted by key to keep the results deterministic, or the elements of an array
+func children(value interface{}) []interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		var keys []string
+		for key := range typedValue {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var result []interface{}
+		for _, key := range keys {
+			result = append(result, typedValue[key])
+		}
+		return result
+
+	case []interface{}:
+		return append([]interface{}{}, typedValue...)
+
+	default:
+		return nil
+	}
+}
+
+func readKey(expr string) (string, string) {
+	end := strings.IndexAny(expr, ".[")
+	if end == -1 {
+		return expr, ""
+	}
+	return expr[:end], expr[end:]
+}
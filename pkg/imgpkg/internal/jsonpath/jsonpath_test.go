@@ -0,0 +1,68 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package jsonpath_test
+
+import (
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/jsonpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+const deploymentYAML = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    example.com/image: not-an-image-field
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox:1.36
+      containers:
+      - name: app
+        image: nginx:1.25
+      - name: sidecar
+        image: envoyproxy/envoy:v1.28
+        args: ["image"]
+`
+
+func TestPathFind(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(deploymentYAML), &doc))
+
+	tests := []struct {
+		expr     string
+		expected []string
+	}{
+		{expr: "$..image", expected: []string{"nginx:1.25", "envoyproxy/envoy:v1.28", "busybox:1.36"}},
+		{expr: "$.spec.template.spec.containers[*].image", expected: []string{"nginx:1.25", "envoyproxy/envoy:v1.28"}},
+		{expr: "{.spec.template.spec.containers[0].image}", expected: []string{"nginx:1.25"}},
+		{expr: ".spec.template.spec.containers[-1].name", expected: []string{"sidecar"}},
+		{expr: "$.metadata.annotations['example.com/image']", expected: []string{"not-an-image-field"}},
+		{expr: "$.kind", expected: []string{"Deployment"}},
+		{expr: "$.does.not.exist", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			path, err := jsonpath.Parse(test.expr)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, path.FindStrings(doc))
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"$.", "$..", "$.a[", "$.a[foo]", "image"} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := jsonpath.Parse(expr)
+			require.Error(t, err)
+		})
+	}
+}
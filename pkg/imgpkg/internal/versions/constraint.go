@@ -0,0 +1,294 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package versions implements semantic version constraints used to select image tags
+//
+// Supported syntax:
+//
+//	1.2.3 or =1.2.3     exact version
+//	1.2 or 1.2.x        any version with major 1 and minor 2
+//	*                   any version
+//	>1.2.3 >=1.2.3      greater than (or equal)
+//	<1.2.3 <=1.2.3      less than (or equal)
+//	!=1.2.3             any version except 1.2.3
+//	~1.2.3              patch updates: >=1.2.3 <1.3.0
+//	^1.2.3              minor and patch updates: >=1.2.3 <2.0.0 (>=0.2.3 <0.3.0 for 0.x versions)
+//
+// Conditions separated by spaces or commas must all be satisfied, alternatives are separated by ||.
+// Versions may be prefixed with 'v'. Pre-release versions only match when a condition references
+// a pre-release of the same major, minor and patch version
+package versions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+type condition struct {
+	op      string
+	version string
+}
+
+// Constraint set of alternatives that a version needs to satisfy
+type Constraint struct {
+	expr         string
+	alternatives [][]condition
+}
+
+// NewConstraint parses the constraint expression
+func NewConstraint(expr string) (Constraint, error) {
+	if strings.TrimSpace(expr) == "" {
+		return Constraint{}, fmt.Errorf("Expected constraint to be non-empty")
+	}
+
+	constraint := Constraint{expr: expr}
+	for _, alternative := range strings.Split(expr, "||") {
+		tokens := strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == ',' })
+		if len(tokens) == 0 {
+			return Constraint{}, fmt.Errorf("Parsing constraint '%s': Expected conditions between '||'", expr)
+		}
+
+		var conditions []condition
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			// allow a space between the operator and the version, i.e. '>= 1.2.3'
+			if strings.Trim(token, "<>=!~^") == "" && i+1 < len(tokens) {
+				i++
+				token += tokens[i]
+			}
+
+			parsedConditions, err := parseCondition(token)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("Parsing constraint '%s': %s", expr, err)
+			}
+			conditions = append(conditions, parsedConditions...)
+		}
+		constraint.alternatives = append(constraint.alternatives, conditions)
+	}
+
+	return constraint, nil
+}
+
+// String returns the constraint expression
+func (c Constraint) String() string { return c.expr }
+
+// Matches returns true when version is a valid semantic version that satisfies the constraint
+func (c Constraint) Matches(version string) bool {
+	v, ok := canonical(version)
+	if !ok {
+		return false
+	}
+
+	for _, conditions := range c.alternatives {
+		if matchesAll(conditions, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// HighestMatching returns the highest of the versions that satisfies the constraint,
+// versions that are not semantic versions are ignored
+func (c Constraint) HighestMatching(versions []string) (string, bool) {
+	highest := ""
+	highestCanonical := ""
+	for _, version := range versions {
+		if !c.Matches(version) {
+			continue
+		}
+		v, _ := canonical(version)
+		if highest == "" || semver.Compare(v, highestCanonical) > 0 {
+			highest = version
+			highestCanonical = v
+		}
+	}
+	return highest, highest != ""
+}
+
+func matchesAll(conditions []condition, v string) bool {
+	if semver.Prerelease(v) != "" {
+		allowed := false
+		for _, cond := range conditions {
+			if semver.Prerelease(cond.version) != "" && core(cond.version) == core(v) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	for _, cond := range conditions {
+		cmp := semver.Compare(v, cond.version)
+		var ok bool
+		switch cond.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		default:
+			panic(fmt.Sprintf("Internal inconsistency: unknown operator '%s'", cond.op))
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCondition converts a single condition into the equivalent list of comparisons
+func parseCondition(token string) ([]condition, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(token, prefix) {
+			op = prefix
+			break
+		}
+	}
+	versionStr := strings.TrimPrefix(token, op)
+
+	parts, prerelease, err := parsePartialVersion(versionStr)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		if op != "" && op != "=" {
+			return nil, fmt.Errorf("Expected a version after '%s'", op)
+		}
+		return nil, nil
+	}
+
+	lower := versionFromParts(parts, prerelease)
+	upper := versionFromParts(bump(parts, len(parts)-1), "")
+
+	switch op {
+	case "", "=":
+		if len(parts) == 3 {
+			return []condition{{"=", lower}}, nil
+		}
+		return []condition{{">=", lower}, {"<", upper}}, nil
+	case "!=":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("Expected a full version after '!=', got '%s'", versionStr)
+		}
+		return []condition{{"!=", lower}}, nil
+	case ">":
+		if len(parts) == 3 {
+			return []condition{{">", lower}}, nil
+		}
+		return []condition{{">=", upper}}, nil
+	case ">=":
+		return []condition{{">=", lower}}, nil
+	case "<":
+		return []condition{{"<", lower}}, nil
+	case "<=":
+		if len(parts) == 3 {
+			return []condition{{"<=", lower}}, nil
+		}
+		return []condition{{"<", upper}}, nil
+	case "~":
+		bumpIdx := 1
+		if len(parts) == 1 {
+			bumpIdx = 0
+		}
+		return []condition{{">=", lower}, {"<", versionFromParts(bump(parts, bumpIdx), "")}}, nil
+	case "^":
+		bumpIdx := 0
+		for bumpIdx < len(parts)-1 && parts[bumpIdx] == 0 {
+			bumpIdx++
+		}
+		return []condition{{">=", lower}, {"<", versionFromParts(bump(parts, bumpIdx), "")}}, nil
+	default:
+		panic(fmt.Sprintf("Internal inconsistency: unknown operator '%s'", op))
+	}
+}
+
+// parsePartialVersion parses versions like 1, 1.2, 1.2.x, 1.2.3 and 1.2.3-rc.1, returning the numeric parts before any wildcard
+func parsePartialVersion(version string) ([]int, string, error) {
+	version = strings.TrimPrefix(version, "v")
+	if version == "*" || version == "x" || version == "X" {
+		return nil, "", nil
+	}
+
+	prerelease := ""
+	if idx := strings.IndexAny(version, "-+"); idx != -1 {
+		if version[idx] == '-' {
+			prerelease = version[idx:]
+			if plusIdx := strings.Index(prerelease, "+"); plusIdx != -1 {
+				prerelease = prerelease[:plusIdx]
+			}
+		}
+		version = version[:idx]
+	}
+
+	pieces := strings.Split(version, ".")
+	if len(pieces) > 3 {
+		return nil, "", fmt.Errorf("Invalid version '%s'", version)
+	}
+
+	var parts []int
+	for _, piece := range pieces {
+		if piece == "*" || piece == "x" || piece == "X" {
+			break
+		}
+		n, err := strconv.Atoi(piece)
+		if err != nil || n < 0 {
+			return nil, "", fmt.Errorf("Invalid version '%s'", version)
+		}
+		parts = append(parts, n)
+	}
+
+	if prerelease != "" {
+		if len(parts) != 3 {
+			return nil, "", fmt.Errorf("Expected a full version with pre-release '%s'", prerelease)
+		}
+		if !semver.IsValid(versionFromParts(parts, prerelease)) {
+			return nil, "", fmt.Errorf("Invalid pre-release '%s'", prerelease)
+		}
+	}
+
+	return parts, prerelease, nil
+}
+
+func bump(parts []int, idx int) []int {
+	bumped := make([]int, idx+1)
+	copy(bumped, parts[:idx+1])
+	bumped[idx]++
+	return bumped
+}
+
+func versionFromParts(parts []int, prerelease string) string {
+	full := []string{"0", "0", "0"}
+	for i, part := range parts {
+		full[i] = strconv.Itoa(part)
+	}
+	return "v" + strings.Join(full, ".") + prerelease
+}
+
+// canonical converts a full semantic version, optionally prefixed with 'v', into the format used by the semver package
+func canonical(version string) (string, bool) {
+	v := "v" + strings.TrimPrefix(version, "v")
+	if !semver.IsValid(v) || strings.Count(core(v), ".") != 2 {
+		return "", false
+	}
+	return v, true
+}
+
+func core(v string) string {
+	if idx := strings.IndexAny(v, "-+"); idx != -1 {
+		return v[:idx]
+	}
+	return v
+}
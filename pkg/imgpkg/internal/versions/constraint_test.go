@@ -0,0 +1,69 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package versions_test
+
+import (
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/versions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		notMatches []string
+	}{
+		{constraint: "1.2.3", matches: []string{"1.2.3", "v1.2.3"}, notMatches: []string{"1.2.4", "1.2.3-rc.1"}},
+		{constraint: "1.2", matches: []string{"1.2.0", "1.2.9"}, notMatches: []string{"1.3.0", "1.1.9"}},
+		{constraint: "1.x", matches: []string{"1.0.0", "1.9.9"}, notMatches: []string{"2.0.0", "0.9.0"}},
+		{constraint: "*", matches: []string{"0.0.1", "10.0.0"}, notMatches: []string{"latest", "1.2", "1.0.0-alpha"}},
+		{constraint: ">=1.2.0 <2.0.0", matches: []string{"1.2.0", "1.99.0"}, notMatches: []string{"1.1.9", "2.0.0"}},
+		{constraint: ">= 1.2.0, < 2", matches: []string{"1.2.0", "1.99.0"}, notMatches: []string{"2.0.0"}},
+		{constraint: ">1.2", matches: []string{"1.3.0"}, notMatches: []string{"1.2.5"}},
+		{constraint: "<=1.2", matches: []string{"1.2.5"}, notMatches: []string{"1.3.0"}},
+		{constraint: "!=1.2.3", matches: []string{"1.2.4"}, notMatches: []string{"1.2.3"}},
+		{constraint: "~1.2.3", matches: []string{"1.2.3", "1.2.9"}, notMatches: []string{"1.3.0", "1.2.2"}},
+		{constraint: "^1.2.3", matches: []string{"1.2.3", "1.9.0"}, notMatches: []string{"2.0.0", "1.2.2"}},
+		{constraint: "^0.2.3", matches: []string{"0.2.3", "0.2.9"}, notMatches: []string{"0.3.0"}},
+		{constraint: "^0.0.3", matches: []string{"0.0.3"}, notMatches: []string{"0.0.4"}},
+		{constraint: "1.x || >=3.0.0", matches: []string{"1.5.0", "3.1.0"}, notMatches: []string{"2.0.0"}},
+		{constraint: ">=1.2.3-rc.1 <1.2.4", matches: []string{"1.2.3-rc.2", "1.2.3"}, notMatches: []string{"1.2.4-rc.1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.constraint, func(t *testing.T) {
+			constraint, err := versions.NewConstraint(test.constraint)
+			require.NoError(t, err)
+
+			for _, version := range test.matches {
+				assert.True(t, constraint.Matches(version), "expected %s to match", version)
+			}
+			for _, version := range test.notMatches {
+				assert.False(t, constraint.Matches(version), "expected %s not to match", version)
+			}
+		})
+	}
+
+	t.Run("invalid constraints", func(t *testing.T) {
+		for _, expr := range []string{"", "abc", ">=", "1.2.3.4", "!=1.2", "1.2 ||", "1.2-rc.1"} {
+			_, err := versions.NewConstraint(expr)
+			assert.Error(t, err, "expected '%s' to be invalid", expr)
+		}
+	})
+
+	t.Run("highest matching version", func(t *testing.T) {
+		constraint, err := versions.NewConstraint("^1.2.0")
+		require.NoError(t, err)
+
+		highest, found := constraint.HighestMatching([]string{"latest", "1.2.0", "v1.10.0", "1.9.0", "2.0.0", "1.11.0-rc.1"})
+		assert.True(t, found)
+		assert.Equal(t, "v1.10.0", highest)
+
+		_, found = constraint.HighestMatching([]string{"latest", "2.0.0"})
+		assert.False(t, found)
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package lockconfig
+
+import (
+	"fmt"
+	"os"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/versions"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	BundleDepsKind       = "BundleDeps"
+	BundleDepsAPIVersion = "imgpkg.carvel.dev/v1alpha1"
+)
+
+// BundleDeps declares the nested bundles of a bundle by repository and version constraint
+type BundleDeps struct {
+	LockVersion
+	Bundles []BundleDep `json:"bundles,omitempty"` // This generated yaml, but due to lib we need to use `json`
+}
+
+// BundleDep nested bundle that resolves to the highest tag of Repository matching Constraint
+type BundleDep struct {
+	Repository string `json:"repository"` // This generated yaml, but due to lib we need to use `json`
+	Constraint string `json:"constraint"` // This generated yaml, but due to lib we need to use `json`
+}
+
+func NewBundleDepsFromPath(path string) (BundleDeps, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return BundleDeps{}, fmt.Errorf("Reading path %s: %s", path, err)
+	}
+
+	return NewBundleDepsFromBytes(bs)
+}
+
+func NewBundleDepsFromBytes(data []byte) (BundleDeps, error) {
+	var deps BundleDeps
+
+	err := yaml.UnmarshalStrict(data, &deps)
+	if err != nil {
+		return deps, fmt.Errorf("Unmarshaling bundle deps: %s", err)
+	}
+
+	err = deps.Validate()
+	if err != nil {
+		return deps, fmt.Errorf("Validating bundle deps: %s", err)
+	}
+
+	return deps, nil
+}
+
+func (d BundleDeps) Validate() error {
+	if d.APIVersion != BundleDepsAPIVersion {
+		return fmt.Errorf("Validating apiVersion: Unknown version (known: %s)", BundleDepsAPIVersion)
+	}
+	if d.Kind != BundleDepsKind {
+		return fmt.Errorf("Validating kind: Unknown kind (known: %s)", BundleDepsKind)
+	}
+
+	seen := map[string]struct{}{}
+	for i, dep := range d.Bundles {
+		repo, err := regname.NewRepository(dep.Repository)
+		if err != nil {
+			return fmt.Errorf("Validating bundles[%d].repository: Expected a repository without tag or digest, got '%s'", i, dep.Repository)
+		}
+		if _, found := seen[repo.Name()]; found {
+			return fmt.Errorf("Validating bundles[%d].repository: Repository '%s' is declared more than once", i, dep.Repository)
+		}
+		seen[repo.Name()] = struct{}{}
+
+		if _, err := versions.NewConstraint(dep.Constraint); err != nil {
+			return fmt.Errorf("Validating bundles[%d].constraint: %s", i, err)
+		}
+	}
+	return nil
+}
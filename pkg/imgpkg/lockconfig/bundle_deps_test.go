@@ -0,0 +1,66 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package lockconfig_test
+
+import (
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBundleDepsFromBytes(t *testing.T) {
+	t.Run("parses valid bundle deps", func(t *testing.T) {
+		deps, err := lockconfig.NewBundleDepsFromBytes([]byte(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: BundleDeps
+bundles:
+- repository: registry.io/org/database
+  constraint: ^1.2.0
+- repository: registry.io/org/cache
+  constraint: ">=2.0.0, <3"
+`))
+		require.NoError(t, err)
+		assert.Equal(t, []lockconfig.BundleDep{
+			{Repository: "registry.io/org/database", Constraint: "^1.2.0"},
+			{Repository: "registry.io/org/cache", Constraint: ">=2.0.0, <3"},
+		}, deps.Bundles)
+	})
+
+	tests := []struct {
+		desc          string
+		bundles       string
+		expectedError string
+	}{
+		{
+			desc:          "repository with tag",
+			bundles:       "- repository: registry.io/org/database:1.0.0\n  constraint: ^1.0.0\n",
+			expectedError: "Validating bundles[0].repository: Expected a repository without tag or digest",
+		},
+		{
+			desc:          "invalid constraint",
+			bundles:       "- repository: registry.io/org/database\n  constraint: latest\n",
+			expectedError: "Validating bundles[0].constraint: Parsing constraint 'latest'",
+		},
+		{
+			desc:          "duplicated repository",
+			bundles:       "- repository: registry.io/org/database\n  constraint: ^1.0.0\n- repository: registry.io/org/database\n  constraint: ^2.0.0\n",
+			expectedError: "Validating bundles[1].repository: Repository 'registry.io/org/database' is declared more than once",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := lockconfig.NewBundleDepsFromBytes([]byte("apiVersion: imgpkg.carvel.dev/v1alpha1\nkind: BundleDeps\nbundles:\n" + test.bundles))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+
+	t.Run("fails on unknown kind", func(t *testing.T) {
+		_, err := lockconfig.NewBundleDepsFromBytes([]byte("apiVersion: imgpkg.carvel.dev/v1alpha1\nkind: ImagesLock\n"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Unknown kind (known: BundleDeps)")
+	})
+}
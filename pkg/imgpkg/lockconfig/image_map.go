@@ -0,0 +1,141 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package lockconfig
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	regname "github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	ImageMapKind       = "ImageMap"
+	ImageMapAPIVersion = "imgpkg.carvel.dev/v1alpha1"
+)
+
+// ImageMap maps image locations from a source prefix to a mirror prefix
+type ImageMap struct {
+	LockVersion
+	Mappings []ImageMapping `json:"mappings"` // This generated yaml, but due to lib we need to use `json`
+}
+
+// ImageMapping single mapping from a registry or repository prefix to another
+type ImageMapping struct {
+	From string `json:"from"` // This generated yaml, but due to lib we need to use `json`
+	To   string `json:"to"`   // This generated yaml, but due to lib we need to use `json`
+}
+
+func NewImageMapFromPath(path string) (ImageMap, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return ImageMap{}, fmt.Errorf("Reading path %s: %s", path, err)
+	}
+
+	return NewImageMapFromBytes(bs)
+}
+
+func NewImageMapFromBytes(data []byte) (ImageMap, error) {
+	var imageMap ImageMap
+
+	err := yaml.UnmarshalStrict(data, &imageMap)
+	if err != nil {
+		return imageMap, fmt.Errorf("Unmarshaling image map: %s", err)
+	}
+
+	err = imageMap.Validate()
+	if err != nil {
+		return imageMap, fmt.Errorf("Validating image map: %s", err)
+	}
+
+	return imageMap, nil
+}
+
+func (m ImageMap) Validate() error {
+	if m.APIVersion != ImageMapAPIVersion {
+		return fmt.Errorf("Validating apiVersion: Unknown version (known: %s)", ImageMapAPIVersion)
+	}
+	if m.Kind != ImageMapKind {
+		return fmt.Errorf("Validating kind: Unknown kind (known: %s)", ImageMapKind)
+	}
+	for i, mapping := range m.Mappings {
+		if _, err := normalizeImagePrefix(mapping.From); err != nil {
+			return fmt.Errorf("Validating mappings[%d].from: %s", i, err)
+		}
+		if _, err := normalizeImagePrefix(mapping.To); err != nil {
+			return fmt.Errorf("Validating mappings[%d].to: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// Map returns the location of the image after replacing the longest matching source prefix with its mirror prefix.
+// The provided image is expected to be in digest form. When no mapping matches the image false is returned
+func (m ImageMap) Map(image string) (string, bool, error) {
+	digestRef, err := regname.NewDigest(image)
+	if err != nil {
+		return "", false, fmt.Errorf("Expected ref to be in digest form, got '%s'", image)
+	}
+	repo := digestRef.Context().Name()
+
+	matchedFrom := ""
+	mappedPrefix := ""
+	for _, mapping := range m.Mappings {
+		from, err := normalizeImagePrefix(mapping.From)
+		if err != nil {
+			return "", false, err
+		}
+
+		if (repo == from || strings.HasPrefix(repo, from+"/")) && len(from) > len(matchedFrom) {
+			matchedFrom = from
+			mappedPrefix = strings.TrimSuffix(mapping.To, "/")
+		}
+	}
+
+	if matchedFrom == "" {
+		return "", false, nil
+	}
+
+	return mappedPrefix + strings.TrimPrefix(repo, matchedFrom) + "@" + digestRef.DigestStr(), true, nil
+}
+
+// normalizeImagePrefix converts a registry or repository prefix into its fully qualified name,
+// i.e. docker.io/library becomes index.docker.io/library
+func normalizeImagePrefix(prefix string) (string, error) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return "", fmt.Errorf("Expected non-empty registry or repository")
+	}
+
+	parts := strings.SplitN(prefix, "/", 2)
+	if len(parts) == 1 {
+		registry, err := regname.NewRegistry(prefix)
+		if err != nil {
+			return "", err
+		}
+		return registry.Name(), nil
+	}
+
+	// same heuristic used when parsing image references: the first segment is only a registry when
+	// it looks like a hostname, otherwise the prefix is a repository in the default registry
+	registryName, repoPath := parts[0], parts[1]
+	if !strings.ContainsAny(registryName, ".:") && registryName != "localhost" {
+		registryName, repoPath = regname.DefaultRegistry, prefix
+	}
+
+	registry, err := regname.NewRegistry(registryName)
+	if err != nil {
+		return "", err
+	}
+
+	// validate the repository path using a name that go-containerregistry does not modify
+	_, err = regname.NewRepository(registry.Name() + "/" + repoPath + "/x")
+	if err != nil {
+		return "", err
+	}
+
+	return registry.Name() + "/" + repoPath, nil
+}
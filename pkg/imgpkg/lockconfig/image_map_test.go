@@ -0,0 +1,81 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package lockconfig_test
+
+import (
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sha = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+func TestImageMapMap(t *testing.T) {
+	data := `
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: ImageMap
+mappings:
+- from: docker.io
+  to: mirror.corp.com/dockerhub
+- from: docker.io/library
+  to: mirror.corp.com/library/
+- from: gcr.io/project/app
+  to: mirror.corp.com/app
+`
+
+	subject, err := lockconfig.NewImageMapFromBytes([]byte(data))
+	require.NoError(t, err)
+
+	tests := []struct {
+		image    string
+		expected string
+		found    bool
+	}{
+		{image: "nginx@" + sha, expected: "mirror.corp.com/library/nginx@" + sha, found: true},
+		{image: "index.docker.io/some/image@" + sha, expected: "mirror.corp.com/dockerhub/some/image@" + sha, found: true},
+		{image: "gcr.io/project/app@" + sha, expected: "mirror.corp.com/app@" + sha, found: true},
+		{image: "gcr.io/project/app-other@" + sha, found: false},
+		{image: "quay.io/some/image@" + sha, found: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			mapped, found, err := subject.Map(test.image)
+			require.NoError(t, err)
+			assert.Equal(t, test.found, found)
+			assert.Equal(t, test.expected, mapped)
+		})
+	}
+
+	t.Run("when the image is not in digest form, it errors", func(t *testing.T) {
+		_, _, err := subject.Map("nginx:v1")
+		require.EqualError(t, err, "Expected ref to be in digest form, got 'nginx:v1'")
+	})
+}
+
+func TestNewImageMapFromBytes(t *testing.T) {
+	t.Run("when a mapping is empty, it errors", func(t *testing.T) {
+		data := `
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: ImageMap
+mappings:
+- from: docker.io
+`
+
+		_, err := lockconfig.NewImageMapFromBytes([]byte(data))
+		require.EqualError(t, err, "Validating image map: Validating mappings[0].to: Expected non-empty registry or repository")
+	})
+
+	t.Run("when kind is not ImageMap, it errors", func(t *testing.T) {
+		data := `
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: ImagesLock
+`
+
+		_, err := lockconfig.NewImageMapFromBytes([]byte(data))
+		require.EqualError(t, err, "Validating image map: Validating kind: Unknown kind (known: ImageMap)")
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	ctlimg "carvel.dev/imgpkg/pkg/imgpkg/image"
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
 	regname "github.com/google/go-containerregistry/pkg/name"
 	regv1 "github.com/google/go-containerregistry/pkg/v1"
 	regremote "github.com/google/go-containerregistry/pkg/v1/remote"
@@ -130,7 +131,7 @@ func (i *PlainImage) Fetch() (regv1.Image, error) {
 		return nil, notAnImageError{imgDescriptor.MediaType}
 	}
 
-	i.fetchedImage, err = imgDescriptor.Image()
+	i.fetchedImage, err = registry.DescriptorImage(i.imagesDescriptor, imgDescriptor)
 	if err != nil {
 		return nil, fmt.Errorf("Fetching image: %s", err)
 	}
@@ -164,6 +165,11 @@ func (i *PlainImage) IsImage() (bool, error) {
 
 // Pull the OCI Image to disk
 func (i *PlainImage) Pull(outputPath string, logger Logger) error {
+	return i.PullWithOpts(outputPath, logger, ctlimg.ExtractOpts{})
+}
+
+// PullWithOpts the OCI Image to disk using the provided extraction options
+func (i *PlainImage) PullWithOpts(outputPath string, logger Logger, opts ctlimg.ExtractOpts) error {
 	img, err := i.Fetch()
 	if err != nil {
 		return err
@@ -175,7 +181,8 @@ func (i *PlainImage) Pull(outputPath string, logger Logger) error {
 
 	logger.Logf("Pulling image '%s'\n", i.DigestRef())
 
-	err = ctlimg.NewDirImage(outputPath, img, logger).AsDirectory()
+	opts.Source = i.Repo()
+	err = ctlimg.NewDirImageWithOpts(outputPath, img, logger, opts).AsDirectory()
 	if err != nil {
 		return fmt.Errorf("Extracting image into directory: %s", err)
 	}
@@ -183,6 +190,23 @@ func (i *PlainImage) Pull(outputPath string, logger Logger) error {
 	return nil
 }
 
+// VerifyDirectory compares a directory where the OCI Image was previously pulled against the contents of the image,
+// without extracting it
+func (i *PlainImage) VerifyDirectory(outputPath string, logger Logger, opts ctlimg.ExtractOpts) (ctlimg.DirDiff, error) {
+	img, err := i.Fetch()
+	if err != nil {
+		return ctlimg.DirDiff{}, err
+	}
+
+	if img == nil {
+		panic("Not supported VerifyDirectory on pre fetched PlainImage")
+	}
+
+	logger.Logf("Verifying image '%s'\n", i.DigestRef())
+
+	return ctlimg.NewDirImageWithOpts(outputPath, img, logger, opts).Compare()
+}
+
 func IsNotAnImageError(err error) bool {
 	if err == nil {
 		return false
@@ -0,0 +1,62 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"fmt"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	regauthn "github.com/google/go-containerregistry/pkg/authn"
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+var _ regauthn.Keychain = CredentialHelperKeychain{}
+
+// CredentialHelperKeychain implements an authn.Keychain interface by executing the docker credential helper
+// (docker-credential-<name>) configured for the registry
+type CredentialHelperKeychain struct {
+	helpers map[string]string
+}
+
+// NewCredentialHelperKeychain builder for Credential Helper Keychain, helpers maps registry hostnames to
+// credential helper names, i.e. "123456789.dkr.ecr.us-east-1.amazonaws.com": "ecr-login"
+func NewCredentialHelperKeychain(helpers map[string]string) CredentialHelperKeychain {
+	normalized := map[string]string{}
+	for host, helper := range helpers {
+		if reg, err := regname.NewRegistry(host); err == nil {
+			host = reg.RegistryStr()
+		}
+		normalized[host] = helper
+	}
+	return CredentialHelperKeychain{helpers: normalized}
+}
+
+// Resolve looks up the most appropriate credential for the specified target.
+func (k CredentialHelperKeychain) Resolve(target regauthn.Resource) (regauthn.Authenticator, error) {
+	helper, found := k.helpers[target.RegistryStr()]
+	if !found {
+		return regauthn.Anonymous, nil
+	}
+	return credentialHelperAuth(helper, target.RegistryStr())
+}
+
+// credentialHelperAuth retrieves the credentials for serverURL from the docker credential helper,
+// returns anonymous auth when the helper does not have credentials for it
+func credentialHelperAuth(helper string, serverURL string) (regauthn.Authenticator, error) {
+	creds, err := client.Get(client.NewShellProgramFunc("docker-credential-"+helper), serverURL)
+	if err != nil {
+		if credentials.IsErrCredentialsNotFound(err) {
+			return regauthn.Anonymous, nil
+		}
+		return nil, fmt.Errorf("Getting credentials for '%s' from credential helper 'docker-credential-%s': %s", serverURL, helper, err)
+	}
+
+	// Identity tokens are stored with the username <token>
+	// ref: https://docs.docker.com/engine/reference/commandline/login/#credential-helper-protocol
+	if creds.Username == "<token>" {
+		return regauthn.FromConfig(regauthn.AuthConfig{Username: creds.Username, IdentityToken: creds.Secret}), nil
+	}
+	return regauthn.FromConfig(regauthn.AuthConfig{Username: creds.Username, Password: creds.Secret}), nil
+}
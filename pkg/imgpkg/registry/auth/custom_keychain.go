@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
 	regauthn "github.com/google/go-containerregistry/pkg/authn"
 )
 
@@ -36,6 +37,12 @@ type KeychainOpts struct {
 	Anon                    bool
 	EnableIaasAuthProviders bool
 	ActiveKeychains         []IAASKeychain
+	// AuthFiles docker config files, i.e. Kubernetes dockerconfigjson secrets, with credentials per registry
+	AuthFiles []string
+	// CredentialHelpers docker credential helper name to use per registry hostname
+	CredentialHelpers map[string]string
+	// DebugLogger when set, receives which keychain provided the credentials for each registry
+	DebugLogger util.Logger
 }
 
 // NewSingleAuthKeychain Builds a SingleAuthKeychain struct
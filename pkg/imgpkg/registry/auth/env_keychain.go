@@ -19,11 +19,12 @@ import (
 var _ regauthn.Keychain = &EnvKeychain{}
 
 type envKeychainInfo struct {
-	URL           string
-	Username      string
-	Password      string
-	IdentityToken string
-	RegistryToken string
+	URL              string
+	Username         string
+	Password         string
+	IdentityToken    string
+	RegistryToken    string
+	CredentialHelper string
 }
 
 // EnvKeychain implements an authn.Keychain interface by using credentials provided by imgpkg's auth environment vars
@@ -54,6 +55,11 @@ func (k *EnvKeychain) Resolve(target regauthn.Resource) (regauthn.Authenticator,
 		return nil, err
 	}
 
+	return resolveFromInfos(infos, target)
+}
+
+// resolveFromInfos returns the credentials of the first info whose URL matches the target
+func resolveFromInfos(infos []envKeychainInfo, target regauthn.Resource) (regauthn.Authenticator, error) {
 	for _, info := range infos {
 		registryURLMatches, err := credentialprovider.URLsMatchStr(info.URL, target.String())
 		if err != nil {
@@ -61,6 +67,9 @@ func (k *EnvKeychain) Resolve(target regauthn.Resource) (regauthn.Authenticator,
 		}
 
 		if registryURLMatches {
+			if info.CredentialHelper != "" {
+				return credentialHelperAuth(info.CredentialHelper, target.RegistryStr())
+			}
 			return regauthn.FromConfig(regauthn.AuthConfig{
 				Username:      info.Username,
 				Password:      info.Password,
@@ -73,6 +82,31 @@ func (k *EnvKeychain) Resolve(target regauthn.Resource) (regauthn.Authenticator,
 	return regauthn.Anonymous, nil
 }
 
+// registryURLKey converts a registry hostname, optionally with a scheme and path, into the key used to match targets
+func registryURLKey(val string) (string, error) {
+	if !strings.HasPrefix(val, "https://") && !strings.HasPrefix(val, "http://") {
+		val = "https://" + val
+	}
+	parsedURL, err := url.Parse(val)
+	if err != nil {
+		return "", fmt.Errorf("Parsing registry hostname: %s (e.g. gcr.io, index.docker.io)", err)
+	}
+
+	// Allows exact matches:
+	//    foo.bar.com/namespace
+	// Or hostname matches:
+	//    foo.bar.com
+	// It also considers /v2/  and /v1/ equivalent to the hostname
+	effectivePath := parsedURL.Path
+	if strings.HasPrefix(effectivePath, "/v2/") || strings.HasPrefix(effectivePath, "/v1/") {
+		effectivePath = effectivePath[3:]
+	}
+	if (len(effectivePath) > 0) && (effectivePath != "/") {
+		return parsedURL.Host + effectivePath, nil
+	}
+	return parsedURL.Host, nil
+}
+
 type orderedEnvKeychainInfos []envKeychainInfo
 
 func (s orderedEnvKeychainInfos) Len() int {
@@ -105,28 +139,9 @@ func (k *EnvKeychain) collect() ([]envKeychainInfo, error) {
 
 	funcsMap := map[string]func(*envKeychainInfo, string) error{
 		"HOSTNAME": func(info *envKeychainInfo, val string) error {
-			if !strings.HasPrefix(val, "https://") && !strings.HasPrefix(val, "http://") {
-				val = "https://" + val
-			}
-			parsedURL, err := url.Parse(val)
+			key, err := registryURLKey(val)
 			if err != nil {
-				return fmt.Errorf("Parsing registry hostname: %s (e.g. gcr.io, index.docker.io)", err)
-			}
-
-			// Allows exact matches:
-			//    foo.bar.com/namespace
-			// Or hostname matches:
-			//    foo.bar.com
-			// It also considers /v2/  and /v1/ equivalent to the hostname
-			effectivePath := parsedURL.Path
-			if strings.HasPrefix(effectivePath, "/v2/") || strings.HasPrefix(effectivePath, "/v1/") {
-				effectivePath = effectivePath[3:]
-			}
-			var key string
-			if (len(effectivePath) > 0) && (effectivePath != "/") {
-				key = parsedURL.Host + effectivePath
-			} else {
-				key = parsedURL.Host
+				return err
 			}
 			info.URL = key
 			return nil
@@ -147,6 +162,10 @@ func (k *EnvKeychain) collect() ([]envKeychainInfo, error) {
 			info.RegistryToken = val
 			return nil
 		},
+		"CREDENTIAL_HELPER": func(info *envKeychainInfo, val string) error {
+			info.CredentialHelper = val
+			return nil
+		},
 	}
 
 	defaultInfo := envKeychainInfo{}
@@ -200,6 +219,14 @@ func (k *EnvKeychain) collect() ([]envKeychainInfo, error) {
 		result = append(result, info)
 	}
 
+	for _, info := range result {
+		hasCredentials := info.Username != "" || info.Password != "" || info.IdentityToken != "" || info.RegistryToken != ""
+		if info.CredentialHelper != "" && hasCredentials {
+			k.collectErr = fmt.Errorf("Expected either a credential helper or credentials for registry '%s', but got both", info.URL)
+			return nil, k.collectErr
+		}
+	}
+
 	// Update the collected auth infos used to identify which credentials to use for a given
 	// image. The info is reverse-sorted by URL so more specific paths are matched
 	// first. For example, if for the given image "quay.io/coreos/etcd",
@@ -0,0 +1,132 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	regauthn "github.com/google/go-containerregistry/pkg/authn"
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+var _ regauthn.Keychain = FileKeychain{}
+
+// FileKeychain implements an authn.Keychain interface by using the credentials present in docker config files,
+// i.e. the .dockerconfigjson key of a Kubernetes imagePullSecret
+type FileKeychain struct {
+	infos []envKeychainInfo
+}
+
+type dockerConfigFile struct {
+	Auths map[string]dockerConfigAuth `json:"auths"`
+}
+
+type dockerConfigAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+	RegistryToken string `json:"registrytoken"`
+}
+
+// NewFileKeychain builder for File Keychain, reads the credentials from the files in paths. Files can be in
+// the dockerconfigjson format ({"auths": {...}}) or the legacy dockercfg format.
+// When multiple files contain credentials for the same registry, the first file takes precedence
+func NewFileKeychain(paths []string) (FileKeychain, error) {
+	var result []envKeychainInfo
+	seen := map[string]struct{}{}
+
+	for _, path := range paths {
+		infos, err := readDockerConfigFile(path)
+		if err != nil {
+			return FileKeychain{}, fmt.Errorf("Reading registry auth file '%s': %s", path, err)
+		}
+		for _, info := range infos {
+			if _, found := seen[info.URL]; found {
+				continue
+			}
+			seen[info.URL] = struct{}{}
+			result = append(result, info)
+		}
+	}
+
+	// Same as in the env keychain more specific paths are matched first
+	sort.Sort(sort.Reverse(orderedEnvKeychainInfos(result)))
+
+	return FileKeychain{infos: result}, nil
+}
+
+// Resolve looks up the most appropriate credential for the specified target.
+func (k FileKeychain) Resolve(target regauthn.Resource) (regauthn.Authenticator, error) {
+	return resolveFromInfos(k.infos, target)
+}
+
+func readDockerConfigFile(path string) ([]envKeychainInfo, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(bs, &raw); err != nil {
+		return nil, fmt.Errorf("Unmarshaling: %s", err)
+	}
+
+	var config dockerConfigFile
+	if _, found := raw["auths"]; found {
+		err = json.Unmarshal(bs, &config)
+	} else {
+		// legacy dockercfg format does not have the auths key
+		err = json.Unmarshal(bs, &config.Auths)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unmarshaling: %s", err)
+	}
+
+	var hosts []string
+	for host := range config.Auths {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var result []envKeychainInfo
+	for _, host := range hosts {
+		auth := config.Auths[host]
+		key, err := registryURLKey(host)
+		if err != nil {
+			return nil, err
+		}
+		// docker.io and index.docker.io refer to the same registry
+		registryHost, repoPath, _ := strings.Cut(key, "/")
+		if reg, err := regname.NewRegistry(registryHost); err == nil && reg.RegistryStr() != registryHost {
+			key = strings.TrimSuffix(reg.RegistryStr()+"/"+repoPath, "/")
+		}
+
+		info := envKeychainInfo{
+			URL:           key,
+			Username:      auth.Username,
+			Password:      auth.Password,
+			IdentityToken: auth.IdentityToken,
+			RegistryToken: auth.RegistryToken,
+		}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("Decoding auth of registry '%s': %s", host, err)
+			}
+			username, password, found := strings.Cut(string(decoded), ":")
+			if !found {
+				return nil, fmt.Errorf("Decoding auth of registry '%s': Expected format username:password", host)
+			}
+			info.Username, info.Password = username, password
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigFromBytes(t *testing.T) {
+	t.Run("parses hosts and rewrites", func(t *testing.T) {
+		config, err := registry.NewConfigFromBytes([]byte(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: RegistryConfig
+hosts:
+- host: docker.io
+  mirrors:
+  - mirror.corp.com/dockerhub
+  - localhost:5000
+- host: localhost:5000
+  insecure: true
+  verifyCerts: false
+rewrites:
+- from: gcr.io/old-project
+  to: registry.corp.com/new-project
+`))
+		require.NoError(t, err)
+
+		verifyCerts := false
+		require.Equal(t, registry.Config{
+			APIVersion: registry.ConfigAPIVersion,
+			Kind:       registry.ConfigKind,
+			Hosts: []registry.HostConfig{
+				{Host: "docker.io", Mirrors: []string{"mirror.corp.com/dockerhub", "localhost:5000"}},
+				{Host: "localhost:5000", Insecure: true, VerifyCerts: &verifyCerts},
+			},
+			Rewrites: []registry.RewriteRule{{From: "gcr.io/old-project", To: "registry.corp.com/new-project"}},
+		}, config)
+	})
+
+	t.Run("when a host is declared twice, it errors", func(t *testing.T) {
+		_, err := registry.NewConfigFromBytes([]byte(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: RegistryConfig
+hosts:
+- host: docker.io
+- host: index.docker.io
+`))
+		require.ErrorContains(t, err, "Validating hosts[1].host: Host 'index.docker.io' is declared more than once")
+	})
+
+	t.Run("when a host includes a repository, it errors", func(t *testing.T) {
+		_, err := registry.NewConfigFromBytes([]byte(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: RegistryConfig
+hosts:
+- host: docker.io/library
+`))
+		require.ErrorContains(t, err, "Validating hosts[0].host: Expected a registry hostname, got 'docker.io/library'")
+	})
+
+	t.Run("when a mirror is not valid, it errors", func(t *testing.T) {
+		_, err := registry.NewConfigFromBytes([]byte(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: RegistryConfig
+hosts:
+- host: docker.io
+  mirrors:
+  - https://mirror.corp.com
+`))
+		require.ErrorContains(t, err, "Validating hosts[0].mirrors[0]: Expected a registry hostname optionally followed by a repository prefix, got 'https://mirror.corp.com'")
+	})
+
+	t.Run("when a proxy does not use a supported scheme, it errors", func(t *testing.T) {
+		_, err := registry.NewConfigFromBytes([]byte(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: RegistryConfig
+hosts:
+- host: gcr.io
+  proxy: socks4://proxy.corp.com:1080
+`))
+		require.ErrorContains(t, err, "Validating hosts[0].proxy: Expected proxy 'socks4://proxy.corp.com:1080' to use one of the schemes: http, https, socks5")
+	})
+
+	t.Run("when only the client key is provided, it errors", func(t *testing.T) {
+		_, err := registry.NewConfigFromBytes([]byte(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: RegistryConfig
+hosts:
+- host: registry.corp.com
+  clientKeyPath: /tls.key
+`))
+		require.ErrorContains(t, err, "Validating hosts[0]: Expected both clientCertPath and clientKeyPath to be provided")
+	})
+
+	t.Run("when the kind is not known, it errors", func(t *testing.T) {
+		_, err := registry.NewConfigFromBytes([]byte(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: ImagesLock
+`))
+		require.ErrorContains(t, err, "Validating kind: Unknown kind (known: RegistryConfig)")
+	})
+}
+
+func TestParseHostConfig(t *testing.T) {
+	t.Run("parses all the settings", func(t *testing.T) {
+		hostConfig, err := registry.ParseHostConfig("registry.corp.com:443=ca-cert-path=/ca1.pem,ca-cert-path=/ca2.pem,client-cert-path=/tls.crt,client-key-path=/tls.key,verify-certs=false,insecure=true,credential-helper=ecr-login,proxy=socks5://proxy.corp.com:1080")
+		require.NoError(t, err)
+
+		verifyCerts := false
+		require.Equal(t, registry.HostConfig{
+			Host:             "registry.corp.com:443",
+			CACertPaths:      []string{"/ca1.pem", "/ca2.pem"},
+			ClientCertPath:   "/tls.crt",
+			ClientKeyPath:    "/tls.key",
+			VerifyCerts:      &verifyCerts,
+			Insecure:         true,
+			CredentialHelper: "ecr-login",
+			Proxy:            "socks5://proxy.corp.com:1080",
+		}, hostConfig)
+	})
+
+	t.Run("when the host has no settings, it errors", func(t *testing.T) {
+		_, err := registry.ParseHostConfig("registry.corp.com")
+		require.EqualError(t, err, "Expected host config to be in the format host=key=value[,key=value...], got 'registry.corp.com'")
+	})
+
+	t.Run("when a setting is not known, it errors", func(t *testing.T) {
+		_, err := registry.ParseHostConfig("registry.corp.com=mirror=other.corp.com")
+		require.EqualError(t, err, "Unknown setting 'mirror' (known: ca-cert-path, client-cert-path, client-key-path, verify-certs, insecure, credential-helper, proxy)")
+	})
+
+	t.Run("when only the client certificate is provided, it errors", func(t *testing.T) {
+		_, err := registry.ParseHostConfig("registry.corp.com=client-cert-path=/tls.crt")
+		require.EqualError(t, err, "Expected both client-cert-path and client-key-path to be provided")
+	})
+
+	t.Run("when verify-certs is not a boolean, it errors", func(t *testing.T) {
+		_, err := registry.ParseHostConfig("registry.corp.com=verify-certs=maybe")
+		require.EqualError(t, err, "Expected verify-certs to be true or false, got 'maybe'")
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"sync"
+)
+
+// successesBeforeIncrease number of successful requests needed to allow one more concurrent request to a host
+// that was throttled
+const successesBeforeIncrease = 10
+
+// hostLimiter limits the number of concurrent requests to a host. There is no limit until the host throttles,
+// then the limit is halved on every throttled response and increased by one after successesBeforeIncrease
+// successful responses
+type hostLimiter struct {
+	lock      sync.Mutex
+	cond      *sync.Cond
+	inFlight  int
+	limit     int
+	successes int
+}
+
+func newHostLimiter() *hostLimiter {
+	l := &hostLimiter{}
+	l.cond = sync.NewCond(&l.lock)
+	return l
+}
+
+func (l *hostLimiter) acquire() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for l.limit > 0 && l.inFlight >= l.limit {
+		l.cond.Wait()
+	}
+	l.inFlight++
+}
+
+func (l *hostLimiter) release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.inFlight--
+	l.cond.Broadcast()
+}
+
+// throttled reduces the limit of concurrent requests and returns the new limit.
+// Called while the request that was throttled is still in flight
+func (l *hostLimiter) throttled() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	current := l.limit
+	if current == 0 {
+		current = l.inFlight
+	}
+	l.limit = current / 2
+	if l.limit < 1 {
+		l.limit = 1
+	}
+	l.successes = 0
+	return l.limit
+}
+
+func (l *hostLimiter) succeeded() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.limit == 0 {
+		return
+	}
+	l.successes++
+	if l.successes >= successesBeforeIncrease {
+		l.limit++
+		l.successes = 0
+		l.cond.Broadcast()
+	}
+}
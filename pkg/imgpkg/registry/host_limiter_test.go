@@ -0,0 +1,85 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostLimiter(t *testing.T) {
+	t.Run("when the host was never throttled, it does not limit the concurrent requests", func(t *testing.T) {
+		subject := newHostLimiter()
+		for i := 0; i < 100; i++ {
+			subject.acquire()
+		}
+		assert.Equal(t, 100, subject.inFlight)
+	})
+
+	t.Run("when the host throttles, it halves the concurrent requests down to 1", func(t *testing.T) {
+		subject := newHostLimiter()
+		for i := 0; i < 8; i++ {
+			subject.acquire()
+		}
+
+		// the throttled request is still in flight, so 8 requests were in flight
+		assert.Equal(t, 4, subject.throttled())
+		assert.Equal(t, 2, subject.throttled())
+		assert.Equal(t, 1, subject.throttled())
+		assert.Equal(t, 1, subject.throttled())
+	})
+
+	t.Run("when the limit is reached, it waits until a request is released", func(t *testing.T) {
+		subject := newHostLimiter()
+		subject.acquire()
+		subject.acquire()
+		require.Equal(t, 1, subject.throttled())
+		subject.release()
+
+		acquired := make(chan struct{})
+		go func() {
+			subject.acquire()
+			close(acquired)
+		}()
+
+		select {
+		case <-acquired:
+			t.Fatalf("Expected acquire to wait while the limit is reached")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		subject.release()
+		select {
+		case <-acquired:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected acquire to continue after a request was released")
+		}
+	})
+
+	t.Run("it allows one more concurrent request after enough successful requests", func(t *testing.T) {
+		subject := newHostLimiter()
+		subject.acquire()
+		subject.acquire()
+		require.Equal(t, 1, subject.throttled())
+		subject.release()
+
+		for i := 0; i < successesBeforeIncrease-1; i++ {
+			subject.succeeded()
+		}
+		assert.Equal(t, 1, subject.limit)
+		subject.succeeded()
+		assert.Equal(t, 2, subject.limit)
+
+		// a throttled response resets the count of successful requests
+		for i := 0; i < successesBeforeIncrease-1; i++ {
+			subject.succeeded()
+		}
+		assert.Equal(t, 1, subject.throttled())
+		subject.succeeded()
+		assert.Equal(t, 1, subject.limit)
+	})
+}
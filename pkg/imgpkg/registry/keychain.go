@@ -6,7 +6,9 @@ package registry
 import (
 	"fmt"
 	"io"
+	"sync"
 
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
 	"carvel.dev/imgpkg/pkg/imgpkg/registry/auth"
 	"github.com/awslabs/amazon-ecr-credential-helper/ecr-login"
 	"github.com/chrismellard/docker-credential-acr-env/pkg/credhelper"
@@ -19,17 +21,32 @@ import (
 // It enforces an order, where the keychains that contain credentials for a specific target take precedence over
 // keychains that contain credentials for 'any' target. i.e. env keychain takes precedence over the custom keychain.
 // Since env keychain contains credentials per HOSTNAME, and custom keychain doesn't.
+//
+// The order is: env keychain, auth files, credential helpers configured per registry, IaaS keychains and finally
+// the command-line flags or the docker keychain
 func Keychain(keychainOpts auth.KeychainOpts, environFunc func() []string) (regauthn.Keychain, error) {
 	// env keychain comes first
-	keychain := []regauthn.Keychain{auth.NewEnvKeychain(environFunc)}
+	keychain := []namedKeychain{{"env", auth.NewEnvKeychain(environFunc)}}
+
+	if len(keychainOpts.AuthFiles) > 0 {
+		fileKeychain, err := auth.NewFileKeychain(keychainOpts.AuthFiles)
+		if err != nil {
+			return nil, err
+		}
+		keychain = append(keychain, namedKeychain{"auth-file", fileKeychain})
+	}
+
+	if len(keychainOpts.CredentialHelpers) > 0 {
+		keychain = append(keychain, namedKeychain{"credential-helper", auth.NewCredentialHelperKeychain(keychainOpts.CredentialHelpers)})
+	}
 
 	if keychainOpts.EnableIaasAuthProviders {
 		// if enabled, fall back to iaas keychains
 		keychain = append(keychain,
-			google.Keychain,
-			regauthn.NewKeychainFromHelper(ecr.NewECRHelper(ecr.WithLogger(io.Discard))),
-			regauthn.NewKeychainFromHelper(credhelper.NewACRCredentialsHelper()),
-			github.Keychain,
+			namedKeychain{string(auth.GKEKeychain), google.Keychain},
+			namedKeychain{string(auth.ECRKeychain), regauthn.NewKeychainFromHelper(ecr.NewECRHelper(ecr.WithLogger(io.Discard)))},
+			namedKeychain{string(auth.AKSKeychain), regauthn.NewKeychainFromHelper(credhelper.NewACRCredentialsHelper())},
+			namedKeychain{string(auth.GithubKeychain), github.Keychain},
 		)
 	} else {
 		for _, activeKeychain := range keychainOpts.ActiveKeychains {
@@ -46,12 +63,86 @@ func Keychain(keychainOpts auth.KeychainOpts, environFunc func() []string) (rega
 			default:
 				return nil, fmt.Errorf("Unable to load keychain for %s, available keychains [aks, ecr, gke, github]]", string(activeKeychain))
 			}
-			keychain = append(keychain, k)
+			keychain = append(keychain, namedKeychain{string(activeKeychain), k})
 		}
 	}
 
 	// command-line flags and docker keychain comes last
-	keychain = append(keychain, auth.CustomRegistryKeychain{Opts: keychainOpts})
+	customName := "docker-config"
+	if len(keychainOpts.Username) > 0 || len(keychainOpts.Token) > 0 || keychainOpts.Anon {
+		customName = "registry-flags"
+	}
+	keychain = append(keychain, namedKeychain{customName, auth.CustomRegistryKeychain{Opts: keychainOpts}})
+
+	return &orderedKeychain{keychains: keychain, debugLogger: keychainOpts.DebugLogger}, nil
+}
+
+type namedKeychain struct {
+	name     string
+	keychain regauthn.Keychain
+}
+
+// orderedKeychain returns the credentials of the first keychain that has them for the target, same as
+// authn.NewMultiKeychain, and keeps track of which keychain provided them
+type orderedKeychain struct {
+	keychains   []namedKeychain
+	debugLogger util.Logger
+
+	reported     map[string]struct{}
+	reportedLock sync.Mutex
+}
+
+// Resolve looks up the most appropriate credential for the specified target.
+func (o *orderedKeychain) Resolve(target regauthn.Resource) (regauthn.Authenticator, error) {
+	for _, kc := range o.keychains {
+		authenticator, err := kc.keychain.Resolve(target)
+		if err != nil {
+			return nil, err
+		}
+		if authenticator != regauthn.Anonymous {
+			o.debugf(target, "keychain '%s' (%s)", kc.name, describeAuthenticator(authenticator))
+			return authenticator, nil
+		}
+	}
+	o.debugf(target, "no keychain has credentials, using anonymous access")
+	return regauthn.Anonymous, nil
+}
+
+// debugf reports the resolution of credentials once per target
+func (o *orderedKeychain) debugf(target regauthn.Resource, msg string, args ...interface{}) {
+	if o.debugLogger == nil {
+		return
+	}
+
+	o.reportedLock.Lock()
+	defer o.reportedLock.Unlock()
+	if o.reported == nil {
+		o.reported = map[string]struct{}{}
+	}
+	if _, found := o.reported[target.String()]; found {
+		return
+	}
+	o.reported[target.String()] = struct{}{}
+
+	o.debugLogger.Logf("registry-auth | %s: "+msg+"\n", append([]interface{}{target.String()}, args...)...)
+}
 
-	return regauthn.NewMultiKeychain(keychain...), nil
+// describeAuthenticator describes the kind of credentials without exposing any secret
+func describeAuthenticator(authenticator regauthn.Authenticator) string {
+	authConfig, err := authenticator.Authorization()
+	if err != nil {
+		return "unable to read credentials"
+	}
+
+	switch {
+	case authConfig.IdentityToken != "":
+		return "identity token"
+	case authConfig.RegistryToken != "":
+		return "registry token"
+	case authConfig.Username != "":
+		return fmt.Sprintf("username '%s'", authConfig.Username)
+	case authConfig.Auth != "":
+		return "basic auth"
+	}
+	return "anonymous"
 }
@@ -0,0 +1,157 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	"carvel.dev/imgpkg/pkg/imgpkg/registry/auth"
+	regauthn "github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeychain(t *testing.T) {
+	// fake credential helper that only has credentials for my.registry.io
+	helperDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(helperDir, "docker-credential-fake"), []byte(`#!/bin/sh
+read server
+if [ "$server" = "my.registry.io" ]; then
+  echo '{"ServerURL":"my.registry.io","Username":"helper-user","Secret":"helper-secret"}'
+else
+  echo "credentials not found in native keychain"
+  exit 1
+fi
+`), 0700))
+	t.Setenv("PATH", helperDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	myRegistry, err := name.NewRegistry("my.registry.io")
+	require.NoError(t, err)
+	otherRegistry, err := name.NewRegistry("other.registry.io")
+	require.NoError(t, err)
+
+	t.Run("when a credential helper is configured via env, it uses it for the registry", func(t *testing.T) {
+		keychain, err := registry.Keychain(auth.KeychainOpts{Anon: true}, func() []string {
+			return []string{"IMGPKG_REGISTRY_HOSTNAME_0=my.registry.io", "IMGPKG_REGISTRY_CREDENTIAL_HELPER_0=fake"}
+		})
+		require.NoError(t, err)
+
+		authenticator, err := keychain.Resolve(myRegistry)
+		require.NoError(t, err)
+		authConfig, err := authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, "helper-user", authConfig.Username)
+		assert.Equal(t, "helper-secret", authConfig.Password)
+
+		authenticator, err = keychain.Resolve(otherRegistry)
+		require.NoError(t, err)
+		assert.Equal(t, regauthn.Anonymous, authenticator)
+	})
+
+	t.Run("when a credential helper and credentials are configured via env for the same registry, it errors", func(t *testing.T) {
+		keychain, err := registry.Keychain(auth.KeychainOpts{Anon: true}, func() []string {
+			return []string{"IMGPKG_REGISTRY_HOSTNAME_0=my.registry.io", "IMGPKG_REGISTRY_CREDENTIAL_HELPER_0=fake", "IMGPKG_REGISTRY_USERNAME_0=user"}
+		})
+		require.NoError(t, err)
+
+		_, err = keychain.Resolve(myRegistry)
+		require.EqualError(t, err, "Expected either a credential helper or credentials for registry 'my.registry.io', but got both")
+	})
+
+	t.Run("when a credential helper is configured for the registry, env credentials take precedence", func(t *testing.T) {
+		keychain, err := registry.Keychain(auth.KeychainOpts{
+			Anon:              true,
+			CredentialHelpers: map[string]string{"my.registry.io": "fake", "other.registry.io": "fake"},
+		}, func() []string {
+			return []string{"IMGPKG_REGISTRY_HOSTNAME_0=other.registry.io", "IMGPKG_REGISTRY_USERNAME_0=env-user", "IMGPKG_REGISTRY_PASSWORD_0=env-password"}
+		})
+		require.NoError(t, err)
+
+		authenticator, err := keychain.Resolve(myRegistry)
+		require.NoError(t, err)
+		authConfig, err := authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, "helper-user", authConfig.Username)
+
+		authenticator, err = keychain.Resolve(otherRegistry)
+		require.NoError(t, err)
+		authConfig, err = authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, "env-user", authConfig.Username)
+	})
+
+	t.Run("when auth files are provided, it uses their credentials after the env credentials", func(t *testing.T) {
+		dir := t.TempDir()
+		dockerConfigJSON := filepath.Join(dir, "config.json")
+		require.NoError(t, os.WriteFile(dockerConfigJSON, []byte(`{"auths": {
+  "my.registry.io": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("file-user:file-password"))+`"},
+  "https://index.docker.io/v1/": {"username": "hub-user", "password": "hub-password"}
+}}`), 0600))
+		legacyDockerCfg := filepath.Join(dir, "dockercfg")
+		require.NoError(t, os.WriteFile(legacyDockerCfg, []byte(`{
+  "my.registry.io": {"username": "not-used", "password": "not-used"},
+  "other.registry.io": {"username": "legacy-user", "password": "legacy-password"}
+}`), 0600))
+
+		keychain, err := registry.Keychain(auth.KeychainOpts{
+			Anon:      true,
+			AuthFiles: []string{dockerConfigJSON, legacyDockerCfg},
+		}, func() []string {
+			return []string{"IMGPKG_REGISTRY_HOSTNAME_0=other.registry.io", "IMGPKG_REGISTRY_USERNAME_0=env-user", "IMGPKG_REGISTRY_PASSWORD_0=env-password"}
+		})
+		require.NoError(t, err)
+
+		dockerHubRepo, err := name.NewRepository("library/nginx")
+		require.NoError(t, err)
+
+		for resource, expectedUsername := range map[regauthn.Resource]string{
+			myRegistry:    "file-user",
+			otherRegistry: "env-user",
+			dockerHubRepo: "hub-user",
+		} {
+			authenticator, err := keychain.Resolve(resource)
+			require.NoError(t, err)
+			authConfig, err := authenticator.Authorization()
+			require.NoError(t, err)
+			assert.Equal(t, expectedUsername, authConfig.Username, resource.String())
+		}
+	})
+
+	t.Run("when an auth file cannot be parsed, it errors", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"auths": {"my.registry.io": {"auth": "not base64"}}}`), 0600))
+
+		_, err := registry.Keychain(auth.KeychainOpts{AuthFiles: []string{path}}, func() []string { return nil })
+		require.ErrorContains(t, err, "Reading registry auth file '"+path+"': Decoding auth of registry 'my.registry.io'")
+	})
+
+	t.Run("when debug is enabled, it reports the keychain used for each registry without the secrets", func(t *testing.T) {
+		debugOutput := &bytes.Buffer{}
+		keychain, err := registry.Keychain(auth.KeychainOpts{
+			Anon:              true,
+			CredentialHelpers: map[string]string{"my.registry.io": "fake"},
+			DebugLogger:       util.NewBufferLogger(debugOutput),
+		}, func() []string { return nil })
+		require.NoError(t, err)
+
+		_, err = keychain.Resolve(myRegistry)
+		require.NoError(t, err)
+		_, err = keychain.Resolve(myRegistry)
+		require.NoError(t, err)
+		_, err = keychain.Resolve(otherRegistry)
+		require.NoError(t, err)
+
+		assert.Equal(t, `registry-auth | my.registry.io: keychain 'credential-helper' (username 'helper-user')
+registry-auth | other.registry.io: no keychain has credentials, using anonymous access
+`, debugOutput.String())
+		assert.NotContains(t, debugOutput.String(), "helper-secret")
+	})
+}
@@ -0,0 +1,628 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	regremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// LayoutBackendPrefix prefix of the registry backend that stores each repository in an OCI image layout directory
+	LayoutBackendPrefix = "oci-layout:"
+	// LayoutReferencePrefix prefix of references to repositories stored in an OCI image layout directory,
+	// i.e. layout://dir/repo:tag
+	LayoutReferencePrefix = "layout://"
+	// LayoutRegistryHost registry used by the references created from references with LayoutReferencePrefix
+	LayoutRegistryHost = "oci-layout.local"
+
+	layoutRefNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+// layoutReservedNames files and directories of an OCI image layout, repositories nested in another repository
+// cannot use them as part of their name
+var layoutReservedNames = map[string]struct{}{"blobs": {}, "index.json": {}, "oci-layout": {}}
+
+// parseBackend returns the directory of a registry backend in the format oci-layout:/path
+func parseBackend(backend string) (string, error) {
+	dir := strings.TrimPrefix(backend, LayoutBackendPrefix)
+	if dir == backend || dir == "" {
+		return "", fmt.Errorf("Expected registry backend '%s' to have the format %s/path", backend, LayoutBackendPrefix)
+	}
+	return dir, nil
+}
+
+// ParseLayoutReference splits a reference in the format layout://dir/repo:tag into the directory of the OCI image
+// layout, dir/repo, and a reference to the repository repo in LayoutRegistryHost. Returns false when ref does not
+// start with LayoutReferencePrefix
+func ParseLayoutReference(ref string) (string, string, bool) {
+	path := strings.TrimPrefix(ref, LayoutReferencePrefix)
+	if path == ref {
+		return "", "", false
+	}
+
+	identifier := ""
+	if i := strings.Index(path, "@"); i >= 0 {
+		path, identifier = path[:i], path[i:]
+	} else if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "/") {
+		path, identifier = path[:i], path[i:]
+	}
+	return filepath.Clean(path), LayoutRegistryHost + "/" + filepath.Base(path) + identifier, true
+}
+
+var _ Registry = &LayoutRegistry{}
+
+// NewLayoutRegistry creates a registry that stores the repositories in OCI image layouts. The repositories present
+// in paths, by repository name, are stored in the provided directory. When dir is not empty, every other repository
+// is stored in the directory <dir>/<registry>/<repository>, otherwise only the repositories in paths are supported
+func NewLayoutRegistry(dir string, paths map[string]string) *LayoutRegistry {
+	return &LayoutRegistry{dir: dir, paths: paths}
+}
+
+// LayoutRegistry Implements Registry interface storing each repository in an OCI image layout.
+// Tags are stored in the org.opencontainers.image.ref.name annotation of the index.json of the layout,
+// manifests written by digest are only stored as blobs
+type LayoutRegistry struct {
+	dir   string
+	paths map[string]string
+	// indexLock serializes the changes to the index.json files
+	indexLock sync.Mutex
+}
+
+// handles returns true when repo is stored in an OCI image layout
+func (l *LayoutRegistry) handles(repo regname.Repository) bool {
+	if l == nil {
+		return false
+	}
+	_, found := l.paths[repo.Name()]
+	return found || l.dir != ""
+}
+
+// Get Retrieve Image descriptor for an Image reference
+func (l *LayoutRegistry) Get(ref regname.Reference) (*regremote.Descriptor, error) {
+	if _, _, err := l.descriptor(ref); err != nil {
+		return nil, err
+	}
+	// Descriptors can only be created by the remote package, that reads the layout through layoutTransport
+	return regremote.Get(ref, regremote.WithTransport(&layoutTransport{layouts: l, repo: ref.Context()}))
+}
+
+// Digest Retrieve the Digest for an Image reference
+func (l *LayoutRegistry) Digest(ref regname.Reference) (regv1.Hash, error) {
+	_, desc, err := l.descriptor(ref)
+	if err != nil {
+		return regv1.Hash{}, err
+	}
+	return desc.Digest, nil
+}
+
+// Index Retrieve regv1.ImageIndex struct for an Index reference
+func (l *LayoutRegistry) Index(ref regname.Reference) (regv1.ImageIndex, error) {
+	path, desc, err := l.descriptor(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !desc.MediaType.IsIndex() {
+		return nil, fmt.Errorf("Expected '%s' to be an image index, got media type %s", ref, desc.MediaType)
+	}
+	return newLayoutIndex(path, desc.Digest)
+}
+
+// Image Retrieve the regv1.Image struct for an Image reference
+func (l *LayoutRegistry) Image(ref regname.Reference) (regv1.Image, error) {
+	path, desc, err := l.descriptor(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !desc.MediaType.IsImage() {
+		return nil, fmt.Errorf("Expected '%s' to be an image, got media type %s", ref, desc.MediaType)
+	}
+	return newLayoutImage(path, desc.Digest)
+}
+
+// FirstImageExists Returns the first of the provided Image Digests that exists in the Registry
+func (l *LayoutRegistry) FirstImageExists(digests []string) (string, error) {
+	var err error
+	for _, img := range digests {
+		ref, parseErr := regname.NewDigest(img)
+		if parseErr != nil {
+			return "", parseErr
+		}
+		_, err = l.Digest(ref)
+		if err == nil {
+			return img, nil
+		}
+	}
+	return "", fmt.Errorf("Checking image existence: %s", err)
+}
+
+// MultiWrite Write multiple Images in Parallel to the OCI image layouts
+func (l *LayoutRegistry) MultiWrite(imageOrIndexesToUpload map[regname.Reference]regremote.Taggable, concurrency int, _ chan regv1.Update) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	throttle := util.NewThrottle(concurrency)
+
+	var wg errgroup.Group
+	for ref, taggable := range imageOrIndexesToUpload {
+		ref, taggable := ref, taggable
+		wg.Go(func() error {
+			throttle.Take()
+			defer throttle.Done()
+
+			return l.write(ref, taggable)
+		})
+	}
+	return wg.Wait()
+}
+
+// WriteImage Write Image to the OCI image layout of the repository
+func (l *LayoutRegistry) WriteImage(ref regname.Reference, img regv1.Image, _ chan regv1.Update) error {
+	err := l.write(ref, img)
+	if err != nil {
+		return fmt.Errorf("Writing image: %s", err)
+	}
+	return nil
+}
+
+// WriteIndex Write the Index and its manifests to the OCI image layout of the repository
+func (l *LayoutRegistry) WriteIndex(ref regname.Reference, idx regv1.ImageIndex) error {
+	err := l.write(ref, idx)
+	if err != nil {
+		return fmt.Errorf("Writing image index: %s", err)
+	}
+	return nil
+}
+
+// WriteTag Tag the referenced Image
+func (l *LayoutRegistry) WriteTag(ref regname.Tag, taggable regremote.Taggable) error {
+	err := l.write(ref, taggable)
+	if err != nil {
+		return fmt.Errorf("Tagging image: %s", err)
+	}
+	return nil
+}
+
+// ListTags Retrieve all tags associated with a Repository
+func (l *LayoutRegistry) ListTags(repo regname.Repository) ([]string, error) {
+	path, err := l.layout(repo)
+	if err != nil {
+		return nil, err
+	}
+	index, err := l.indexManifest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	for _, desc := range index.Manifests {
+		if tag, found := desc.Annotations[layoutRefNameAnnotation]; found {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// CloneWithSingleAuth returns the same registry since OCI image layouts do not need authentication
+func (l *LayoutRegistry) CloneWithSingleAuth(regname.Tag) (Registry, error) {
+	return l, nil
+}
+
+// CloneWithLogger returns the same registry since writing to OCI image layouts does not report progress
+func (l *LayoutRegistry) CloneWithLogger(util.ProgressLogger) Registry {
+	return l
+}
+
+// write stores the image, index or manifest of taggable in the layout of the repository of ref,
+// and tags it when ref is a tag
+func (l *LayoutRegistry) write(ref regname.Reference, taggable regremote.Taggable) error {
+	repoPath, err := l.repoPath(ref.Context())
+	if err != nil {
+		return err
+	}
+	path, err := l.initLayout(repoPath)
+	if err != nil {
+		return err
+	}
+
+	if desc, ok := taggable.(*regremote.Descriptor); ok {
+		switch {
+		case desc.MediaType.IsIndex():
+			taggable, err = desc.ImageIndex()
+		case desc.MediaType.IsImage():
+			taggable, err = desc.Image()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	manifest, err := taggable.RawManifest()
+	if err != nil {
+		return err
+	}
+	digest, size, err := regv1.SHA256(bytes.NewReader(manifest))
+	if err != nil {
+		return err
+	}
+	if expected, isDigest := ref.(regname.Digest); isDigest && expected.DigestStr() != digest.String() {
+		return fmt.Errorf("Expected manifest digest %s, got %s", expected.DigestStr(), digest)
+	}
+
+	switch artifact := taggable.(type) {
+	case regv1.ImageIndex:
+		err = path.WriteIndex(artifact)
+	case regv1.Image:
+		err = path.WriteImage(artifact)
+	default:
+		// Only the manifest is written, its blobs are expected to be present in the layout already
+		err = path.WriteBlob(digest, io.NopCloser(bytes.NewReader(manifest)))
+	}
+	if err != nil {
+		return fmt.Errorf("Writing to OCI image layout '%s': %s", repoPath, err)
+	}
+
+	tag, isTag := ref.(regname.Tag)
+	if !isTag {
+		return nil
+	}
+
+	l.indexLock.Lock()
+	defer l.indexLock.Unlock()
+
+	err = path.RemoveDescriptors(match.Annotation(layoutRefNameAnnotation, tag.TagStr()))
+	if err == nil {
+		err = path.AppendDescriptor(regv1.Descriptor{
+			MediaType:   manifestMediaType(manifest),
+			Size:        size,
+			Digest:      digest,
+			Annotations: map[string]string{layoutRefNameAnnotation: tag.TagStr()},
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("Tagging manifest in OCI image layout '%s': %s", repoPath, err)
+	}
+	return nil
+}
+
+// descriptor returns the layout of the repository of ref and the descriptor of the manifest ref points to
+func (l *LayoutRegistry) descriptor(ref regname.Reference) (layout.Path, regv1.Descriptor, error) {
+	path, err := l.layout(ref.Context())
+	if err != nil {
+		return "", regv1.Descriptor{}, err
+	}
+
+	digest, err := regv1.NewHash(ref.Identifier())
+	if err != nil {
+		index, err := l.indexManifest(path)
+		if err != nil {
+			return "", regv1.Descriptor{}, err
+		}
+		for _, desc := range index.Manifests {
+			if desc.Annotations[layoutRefNameAnnotation] == ref.Identifier() {
+				return path, desc, nil
+			}
+		}
+		return "", regv1.Descriptor{}, layoutNotFound(transport.ManifestUnknownErrorCode, fmt.Sprintf("manifest %s not found", ref))
+	}
+
+	manifest, err := readManifest(path, digest)
+	if err != nil {
+		return "", regv1.Descriptor{}, err
+	}
+	return path, regv1.Descriptor{MediaType: manifestMediaType(manifest), Size: int64(len(manifest)), Digest: digest}, nil
+}
+
+// layout returns the existing OCI image layout of the repository
+func (l *LayoutRegistry) layout(repo regname.Repository) (layout.Path, error) {
+	repoPath, err := l.repoPath(repo)
+	if err != nil {
+		return "", err
+	}
+	path, err := layout.FromPath(repoPath)
+	if err != nil {
+		return "", layoutNotFound(transport.NameUnknownErrorCode, fmt.Sprintf("repository %s not found", repo))
+	}
+	return path, nil
+}
+
+// repoPath returns the directory of the OCI image layout of the repository
+func (l *LayoutRegistry) repoPath(repo regname.Repository) (string, error) {
+	if path, found := l.paths[repo.Name()]; found {
+		return path, nil
+	}
+	if l.dir == "" {
+		return "", fmt.Errorf("Expected repository '%s' to be stored in an OCI image layout", repo)
+	}
+
+	for _, part := range strings.Split(repo.RepositoryStr(), "/") {
+		if _, reserved := layoutReservedNames[part]; reserved {
+			return "", fmt.Errorf("Expected repository '%s' to not contain '%s', it is reserved by OCI image layouts", repo, part)
+		}
+	}
+	// Ports are separated with '_' since ':' is not allowed in Windows paths and '_' is not allowed in hostnames
+	registryDir := strings.ReplaceAll(repo.RegistryStr(), ":", "_")
+	return filepath.Join(l.dir, registryDir, filepath.FromSlash(repo.RepositoryStr())), nil
+}
+
+func (l *LayoutRegistry) indexManifest(path layout.Path) (*regv1.IndexManifest, error) {
+	l.indexLock.Lock()
+	defer l.indexLock.Unlock()
+
+	index, err := path.ImageIndex()
+	if err == nil {
+		var manifest *regv1.IndexManifest
+		manifest, err = index.IndexManifest()
+		if err == nil {
+			return manifest, nil
+		}
+	}
+	return nil, fmt.Errorf("Reading index of OCI image layout '%s': %s", path, err)
+}
+
+// initLayout creates an empty OCI image layout in repoPath when it does not exist yet
+func (l *LayoutRegistry) initLayout(repoPath string) (layout.Path, error) {
+	l.indexLock.Lock()
+	defer l.indexLock.Unlock()
+
+	path, err := layout.FromPath(repoPath)
+	if err != nil {
+		path, err = layout.Write(repoPath, empty.Index)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Creating OCI image layout '%s': %s", repoPath, err)
+	}
+	return path, nil
+}
+
+// readManifest reads the manifest blob with digest, the error is a transport.Error when it is not present
+func readManifest(path layout.Path, digest regv1.Hash) ([]byte, error) {
+	manifest, err := path.Bytes(digest)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, layoutNotFound(transport.ManifestUnknownErrorCode, fmt.Sprintf("manifest %s not found", digest))
+		}
+		return nil, fmt.Errorf("Reading manifest '%s' from OCI image layout '%s': %s", digest, path, err)
+	}
+	return manifest, nil
+}
+
+// manifestMediaType returns the media type present in the manifest, falling back to the OCI media types
+// since they are optional in OCI manifests
+func manifestMediaType(manifest []byte) types.MediaType {
+	var fields struct {
+		MediaType types.MediaType   `json:"mediaType"`
+		Manifests []json.RawMessage `json:"manifests"`
+	}
+	_ = json.Unmarshal(manifest, &fields)
+	switch {
+	case fields.MediaType != "":
+		return fields.MediaType
+	case fields.Manifests != nil:
+		return types.OCIImageIndex
+	default:
+		return types.OCIManifestSchema1
+	}
+}
+
+// layoutNotFound returns the same error a registry returns when the repository or manifest does not exist
+func layoutNotFound(code transport.ErrorCode, message string) error {
+	return &transport.Error{StatusCode: http.StatusNotFound, Errors: []transport.Diagnostic{{Code: code, Message: message}}}
+}
+
+// layoutImage image read from an OCI image layout, independently of it being referenced by the index.json
+type layoutImage struct {
+	path     layout.Path
+	manifest []byte
+}
+
+func newLayoutImage(path layout.Path, digest regv1.Hash) (regv1.Image, error) {
+	manifest, err := readManifest(path, digest)
+	if err != nil {
+		return nil, err
+	}
+	return partial.CompressedToImage(&layoutImage{path: path, manifest: manifest})
+}
+
+func (i *layoutImage) RawManifest() ([]byte, error) {
+	return i.manifest, nil
+}
+
+func (i *layoutImage) MediaType() (types.MediaType, error) {
+	return manifestMediaType(i.manifest), nil
+}
+
+func (i *layoutImage) RawConfigFile() ([]byte, error) {
+	manifest, err := regv1.ParseManifest(bytes.NewReader(i.manifest))
+	if err != nil {
+		return nil, err
+	}
+	return i.path.Bytes(manifest.Config.Digest)
+}
+
+func (i *layoutImage) LayerByDigest(digest regv1.Hash) (partial.CompressedLayer, error) {
+	manifest, err := regv1.ParseManifest(bytes.NewReader(i.manifest))
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Config.Digest == digest {
+		return &layoutBlob{path: i.path, desc: manifest.Config}, nil
+	}
+	for _, desc := range manifest.Layers {
+		if desc.Digest == digest {
+			return &layoutBlob{path: i.path, desc: desc}, nil
+		}
+	}
+	return nil, fmt.Errorf("Expected blob '%s' to be part of the image", digest)
+}
+
+// layoutBlob layer or configuration blob of a layoutImage
+type layoutBlob struct {
+	path layout.Path
+	desc regv1.Descriptor
+}
+
+func (b *layoutBlob) Digest() (regv1.Hash, error) {
+	return b.desc.Digest, nil
+}
+
+func (b *layoutBlob) Compressed() (io.ReadCloser, error) {
+	return b.path.Blob(b.desc.Digest)
+}
+
+func (b *layoutBlob) Size() (int64, error) {
+	return b.desc.Size, nil
+}
+
+func (b *layoutBlob) MediaType() (types.MediaType, error) {
+	return b.desc.MediaType, nil
+}
+
+// layoutIndex image index read from an OCI image layout, independently of it being referenced by the index.json
+type layoutIndex struct {
+	path     layout.Path
+	digest   regv1.Hash
+	manifest []byte
+}
+
+func newLayoutIndex(path layout.Path, digest regv1.Hash) (regv1.ImageIndex, error) {
+	manifest, err := readManifest(path, digest)
+	if err != nil {
+		return nil, err
+	}
+	return &layoutIndex{path: path, digest: digest, manifest: manifest}, nil
+}
+
+func (i *layoutIndex) MediaType() (types.MediaType, error) {
+	return manifestMediaType(i.manifest), nil
+}
+
+func (i *layoutIndex) Digest() (regv1.Hash, error) {
+	return i.digest, nil
+}
+
+func (i *layoutIndex) Size() (int64, error) {
+	return int64(len(i.manifest)), nil
+}
+
+func (i *layoutIndex) IndexManifest() (*regv1.IndexManifest, error) {
+	return regv1.ParseIndexManifest(bytes.NewReader(i.manifest))
+}
+
+func (i *layoutIndex) RawManifest() ([]byte, error) {
+	return i.manifest, nil
+}
+
+func (i *layoutIndex) Image(digest regv1.Hash) (regv1.Image, error) {
+	return newLayoutImage(i.path, digest)
+}
+
+func (i *layoutIndex) ImageIndex(digest regv1.Hash) (regv1.ImageIndex, error) {
+	return newLayoutIndex(i.path, digest)
+}
+
+// layoutTransport serves the manifests and blobs of the OCI image layout of repo to the remote package
+type layoutTransport struct {
+	layouts *LayoutRegistry
+	repo    regname.Repository
+}
+
+// RoundTrip answers the read requests of the registry API, any other request fails
+func (t *layoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return nil, fmt.Errorf("Expected only reads from OCI image layout, got %s %s", req.Method, req.URL.Path)
+	}
+
+	resource := strings.TrimPrefix(req.URL.Path, "/v2/"+t.repo.RepositoryStr())
+	kind, identifier, _ := strings.Cut(strings.TrimPrefix(resource, "/"), "/")
+	switch {
+	case req.URL.Path == "/v2/":
+		return layoutResponse(req, http.StatusOK, "", io.NopCloser(bytes.NewReader(nil)), 0), nil
+
+	case kind == "manifests":
+		var ref regname.Reference = t.repo.Tag(identifier)
+		if strings.Contains(identifier, ":") {
+			ref = t.repo.Digest(identifier)
+		}
+		path, desc, err := t.layouts.descriptor(ref)
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := readManifest(path, desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		resp := layoutResponse(req, http.StatusOK, string(desc.MediaType), io.NopCloser(bytes.NewReader(manifest)), desc.Size)
+		resp.Header.Set("Docker-Content-Digest", desc.Digest.String())
+		return resp, nil
+
+	case kind == "blobs":
+		digest, err := regv1.NewHash(identifier)
+		if err != nil {
+			return nil, err
+		}
+		path, err := t.layouts.layout(t.repo)
+		if err != nil {
+			return nil, err
+		}
+		blob, err := path.Blob(digest)
+		if err != nil {
+			return nil, layoutNotFound(transport.BlobUnknownErrorCode, fmt.Sprintf("blob %s not found", digest))
+		}
+		info, err := os.Stat(filepath.Join(string(path), "blobs", digest.Algorithm, digest.Hex))
+		if err != nil {
+			blob.Close()
+			return nil, err
+		}
+		return layoutResponse(req, http.StatusOK, "application/octet-stream", blob, info.Size()), nil
+	}
+	return nil, fmt.Errorf("Expected only reads from OCI image layout, got %s %s", req.Method, req.URL.Path)
+}
+
+func layoutResponse(req *http.Request, status int, contentType string, body io.ReadCloser, size int64) *http.Response {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if req.Method == http.MethodHead {
+		body.Close()
+		body = http.NoBody
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: size,
+		Request:       req,
+	}
+}
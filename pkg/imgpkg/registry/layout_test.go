@@ -0,0 +1,237 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	"carvel.dev/imgpkg/test/helpers"
+	"github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	regremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_LayoutBackend(t *testing.T) {
+	dir := t.TempDir()
+	subject, err := registry.NewSimpleRegistry(registry.Opts{Backend: registry.LayoutBackendPrefix + dir})
+	require.NoError(t, err)
+
+	img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+	imgDigest, err := img.Digest()
+	require.NoError(t, err)
+	imgRef := mustParseReference(t, "registry.io/org/app:v1")
+	require.NoError(t, subject.WriteImage(imgRef, img, nil))
+
+	t.Run("stores the repository in an OCI image layout with the tag as annotation", func(t *testing.T) {
+		idx, err := layout.ImageIndexFromPath(filepath.Join(dir, "registry.io", "org", "app"))
+		require.NoError(t, err)
+		manifest, err := idx.IndexManifest()
+		require.NoError(t, err)
+		require.Len(t, manifest.Manifests, 1)
+		assert.Equal(t, imgDigest, manifest.Manifests[0].Digest)
+		assert.Equal(t, "v1", manifest.Manifests[0].Annotations["org.opencontainers.image.ref.name"])
+	})
+
+	t.Run("reads the image by tag and digest", func(t *testing.T) {
+		digest, err := subject.Digest(imgRef)
+		require.NoError(t, err)
+		assert.Equal(t, imgDigest, digest)
+
+		desc, err := subject.Get(mustParseReference(t, "registry.io/org/app@"+imgDigest.String()))
+		require.NoError(t, err)
+		readImg, err := desc.Image()
+		require.NoError(t, err)
+		assertSameLayers(t, img, readImg)
+	})
+
+	t.Run("moves the tag when it is written again", func(t *testing.T) {
+		otherImg, err := random.Image(1024, 1)
+		require.NoError(t, err)
+		otherRef := mustParseReference(t, "registry.io/org/tags:v2")
+		require.NoError(t, subject.WriteImage(otherRef, img, nil))
+		require.NoError(t, subject.WriteImage(otherRef.Context().Tag("v1"), img, nil))
+		require.NoError(t, subject.WriteImage(otherRef, otherImg, nil))
+
+		tags, err := subject.ListTags(otherRef.Context())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v1", "v2"}, tags)
+
+		digest, err := subject.Digest(otherRef)
+		require.NoError(t, err)
+		otherDigest, err := otherImg.Digest()
+		require.NoError(t, err)
+		assert.Equal(t, otherDigest, digest)
+	})
+
+	t.Run("writes and reads image indexes", func(t *testing.T) {
+		idx, err := random.Index(1024, 1, 2)
+		require.NoError(t, err)
+		idxRef := mustParseReference(t, "registry.io/org/index:v1")
+		require.NoError(t, subject.WriteIndex(idxRef, idx))
+
+		readIdx, err := subject.Index(idxRef)
+		require.NoError(t, err)
+		manifest, err := readIdx.IndexManifest()
+		require.NoError(t, err)
+		require.Len(t, manifest.Manifests, 2)
+		childImg, err := readIdx.Image(manifest.Manifests[1].Digest)
+		require.NoError(t, err)
+		expectedChildImg, err := idx.Image(manifest.Manifests[1].Digest)
+		require.NoError(t, err)
+		assertSameLayers(t, expectedChildImg, childImg)
+	})
+
+	t.Run("copies the layers from other repositories", func(t *testing.T) {
+		readImg, err := subject.Image(imgRef)
+		require.NoError(t, err)
+		copyRef := mustParseReference(t, "registry.io/other/app:v1")
+		err = subject.MultiWrite(map[name.Reference]regremote.Taggable{copyRef: readImg}, 2, nil)
+		require.NoError(t, err)
+
+		layers, err := img.Layers()
+		require.NoError(t, err)
+		for _, layer := range layers {
+			digest, err := layer.Digest()
+			require.NoError(t, err)
+			assert.FileExists(t, filepath.Join(dir, "registry.io", "other", "app", "blobs", digest.Algorithm, digest.Hex))
+		}
+		copiedImg, err := subject.Image(copyRef)
+		require.NoError(t, err)
+		assertSameLayers(t, img, copiedImg)
+
+		entries, err := os.ReadDir(filepath.Join(dir, "registry.io", "other", "app", "blobs"))
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "expected no upload leftovers in the blobs directory")
+	})
+
+	t.Run("keeps repositories with the same name in different registries apart, and nested repositories", func(t *testing.T) {
+		otherImg, err := random.Image(1024, 1)
+		require.NoError(t, err)
+		otherRegistryRef := mustParseReference(t, "other-registry.io:5000/org/app:v1")
+		nestedRef := mustParseReference(t, "registry.io/org/app/nested:v1")
+		require.NoError(t, subject.WriteImage(otherRegistryRef, otherImg, nil))
+		require.NoError(t, subject.WriteImage(nestedRef, otherImg, nil))
+
+		assert.DirExists(t, filepath.Join(dir, "other-registry.io_5000", "org", "app"))
+		otherDigest, err := otherImg.Digest()
+		require.NoError(t, err)
+		for ref, expected := range map[name.Reference]regv1.Hash{imgRef: imgDigest, otherRegistryRef: otherDigest, nestedRef: otherDigest} {
+			digest, err := subject.Digest(ref)
+			require.NoError(t, err)
+			assert.Equal(t, expected, digest, ref.String())
+		}
+
+		err = subject.WriteImage(mustParseReference(t, "registry.io/org/blobs:v1"), otherImg, nil)
+		require.ErrorContains(t, err, "Expected repository 'registry.io/org/blobs' to not contain 'blobs'")
+	})
+
+	t.Run("when the image does not exist, it returns a not found error", func(t *testing.T) {
+		_, err := subject.Digest(mustParseReference(t, "registry.io/org/app:does-not-exist"))
+		require.ErrorContains(t, err, "MANIFEST_UNKNOWN")
+
+		_, err = subject.ListTags(mustParseReference(t, "registry.io/org/does-not-exist:v1").Context())
+		require.ErrorContains(t, err, "NAME_UNKNOWN")
+	})
+
+	t.Run("when the backend is not supported, it returns an error", func(t *testing.T) {
+		_, err := registry.NewSimpleRegistry(registry.Opts{Backend: "s3:bucket"})
+		require.ErrorContains(t, err, "Expected registry backend 's3:bucket' to have the format oci-layout:/path")
+	})
+}
+
+func TestRegistry_Layouts(t *testing.T) {
+	fakeRegistry := helpers.NewFakeRegistry(t, &helpers.Logger{LogLevel: helpers.LogDebug})
+	defer fakeRegistry.CleanUp()
+	fakeRegistry.Build()
+
+	layoutPath := filepath.Join(t.TempDir(), "app")
+	subject, err := registry.NewSimpleRegistry(registry.Opts{
+		Anon:    true,
+		Layouts: map[string]string{registry.LayoutRegistryHost + "/app": layoutPath},
+	})
+	require.NoError(t, err)
+
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	layoutRef := mustParseReference(t, registry.LayoutRegistryHost+"/app:v1")
+	registryRef := mustParseReference(t, fakeRegistry.ReferenceOnTestServer("app:v1"))
+
+	t.Run("writes the references to layouts and registries together", func(t *testing.T) {
+		err := subject.MultiWrite(map[name.Reference]regremote.Taggable{layoutRef: img, registryRef: img}, 2, nil)
+		require.NoError(t, err)
+
+		idx, err := layout.ImageIndexFromPath(layoutPath)
+		require.NoError(t, err)
+		manifest, err := idx.IndexManifest()
+		require.NoError(t, err)
+		require.Len(t, manifest.Manifests, 1)
+	})
+
+	t.Run("copies an image from a registry into a layout", func(t *testing.T) {
+		registryImg, err := subject.Image(registryRef)
+		require.NoError(t, err)
+		copyRef := layoutRef.Context().Tag("v2")
+		require.NoError(t, subject.WriteImage(copyRef, registryImg, nil))
+
+		desc, err := subject.Get(copyRef)
+		require.NoError(t, err)
+		copiedImg, err := desc.Image()
+		require.NoError(t, err)
+		assertSameLayers(t, img, copiedImg)
+	})
+
+	t.Run("when the repository is not a layout, it does not store it in a layout", func(t *testing.T) {
+		_, err := subject.Digest(mustParseReference(t, registry.LayoutRegistryHost+"/other:v1"))
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "NAME_UNKNOWN")
+	})
+}
+
+func TestParseLayoutReference(t *testing.T) {
+	for ref, expected := range map[string][2]string{
+		"layout://dir/repo:tag":                {"dir/repo", "oci-layout.local/repo:tag"},
+		"layout:///tmp/layouts/repo":           {"/tmp/layouts/repo", "oci-layout.local/repo"},
+		"layout://repo@sha256:abc":             {"repo", "oci-layout.local/repo@sha256:abc"},
+		"layout://localhost:5000/dir/repo:tag": {"localhost:5000/dir/repo", "oci-layout.local/repo:tag"},
+	} {
+		dir, layoutRef, ok := registry.ParseLayoutReference(ref)
+		require.True(t, ok, ref)
+		assert.Equal(t, expected, [2]string{dir, layoutRef}, ref)
+	}
+
+	_, _, ok := registry.ParseLayoutReference("registry.io/repo:tag")
+	assert.False(t, ok)
+}
+
+func mustParseReference(t *testing.T, ref string) name.Reference {
+	parsed, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	return parsed
+}
+
+func assertSameLayers(t *testing.T, expected, actual regv1.Image) {
+	expectedLayers, err := expected.Layers()
+	require.NoError(t, err)
+	actualLayers, err := actual.Layers()
+	require.NoError(t, err)
+	require.Len(t, actualLayers, len(expectedLayers))
+	for i := range expectedLayers {
+		expectedDigest, err := expectedLayers[i].Digest()
+		require.NoError(t, err)
+		content, err := actualLayers[i].Compressed()
+		require.NoError(t, err)
+		actualDigest, _, err := regv1.SHA256(content)
+		require.NoError(t, err)
+		require.NoError(t, content.Close())
+		assert.Equal(t, expectedDigest, actualDigest)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Metrics collects statistics of the requests sent to each registry host
+type Metrics struct {
+	hosts map[string]*hostMetrics
+	lock  sync.Mutex
+}
+
+type hostMetrics struct {
+	requests       map[string]map[string]int
+	bytesSent      int64
+	bytesReceived  int64
+	retries        int
+	authRoundTrips int
+	latencies      []time.Duration
+}
+
+// MetricsReport statistics of the requests sent to each registry host
+type MetricsReport struct {
+	Hosts map[string]HostMetricsReport `json:"hosts"`
+}
+
+// HostMetricsReport statistics of the requests sent to a registry host
+type HostMetricsReport struct {
+	// Requests number of requests by method and response status code, requests that did not receive a response
+	// are reported with the status "error"
+	Requests       map[string]map[string]int `json:"requests"`
+	TotalRequests  int                       `json:"totalRequests"`
+	BytesSent      int64                     `json:"bytesSent"`
+	BytesReceived  int64                     `json:"bytesReceived"`
+	Retries        int                       `json:"retries"`
+	AuthRoundTrips int                       `json:"authRoundTrips"`
+	LatencyMs      LatencyReport             `json:"latencyMs"`
+}
+
+// LatencyReport percentiles of the time until the response headers are received, in milliseconds
+type LatencyReport struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+}
+
+// NewMetrics creates an empty Metrics
+func NewMetrics() *Metrics {
+	return &Metrics{hosts: map[string]*hostMetrics{}}
+}
+
+// Report returns the statistics collected so far
+func (m *Metrics) Report() MetricsReport {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	result := MetricsReport{Hosts: map[string]HostMetricsReport{}}
+	for host, metrics := range m.hosts {
+		report := HostMetricsReport{
+			Requests:       map[string]map[string]int{},
+			BytesSent:      metrics.bytesSent,
+			BytesReceived:  metrics.bytesReceived,
+			Retries:        metrics.retries,
+			AuthRoundTrips: metrics.authRoundTrips,
+			LatencyMs: LatencyReport{
+				P50: percentile(metrics.latencies, 50),
+				P95: percentile(metrics.latencies, 95),
+			},
+		}
+		for method, statuses := range metrics.requests {
+			report.Requests[method] = map[string]int{}
+			for status, count := range statuses {
+				report.Requests[method][status] = count
+				report.TotalRequests += count
+			}
+		}
+		result.Hosts[host] = report
+	}
+	return result
+}
+
+// WriteReport writes the statistics collected so far to path as JSON
+func (m *Metrics) WriteReport(path string) error {
+	bs, err := json.MarshalIndent(m.Report(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("Marshaling registry metrics: %s", err)
+	}
+	if err := os.WriteFile(path, append(bs, '\n'), 0600); err != nil {
+		return fmt.Errorf("Writing registry metrics report to '%s': %s", path, err)
+	}
+	return nil
+}
+
+func (m *Metrics) host(host string) *hostMetrics {
+	metrics, found := m.hosts[host]
+	if !found {
+		metrics = &hostMetrics{requests: map[string]map[string]int{}}
+		m.hosts[host] = metrics
+	}
+	return metrics
+}
+
+func (m *Metrics) record(req *http.Request, resp *http.Response, latency time.Duration, retry, tokenRequest bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	metrics := m.host(req.URL.Host)
+	status := "error"
+	if resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	if metrics.requests[req.Method] == nil {
+		metrics.requests[req.Method] = map[string]int{}
+	}
+	metrics.requests[req.Method][status]++
+	metrics.latencies = append(metrics.latencies, latency)
+	if retry {
+		metrics.retries++
+	}
+	if tokenRequest {
+		metrics.authRoundTrips++
+	}
+}
+
+func (m *Metrics) addBytes(host string, sent, received int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.host(host).bytesSent += int64(sent)
+	m.host(host).bytesReceived += int64(received)
+}
+
+// percentile returns the p-th percentile of latencies in milliseconds using the nearest rank method
+func percentile(latencies []time.Duration, p float64) float64 {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return float64(sorted[rank-1].Microseconds()) / 1000
+}
+
+// isTokenRequest returns true for requests sent to registry token servers
+func isTokenRequest(req *http.Request) bool {
+	params := req.URL.Query()
+	if req.Method == http.MethodPost && req.Body != nil {
+		body, err := readRequestBody(req)
+		if err != nil {
+			return false
+		}
+		params, err = url.ParseQuery(string(body))
+		if err != nil {
+			return false
+		}
+	}
+	return params.Has("service") && params.Has("scope")
+}
+
+type attemptsKey struct{}
+
+// NewMetricsRoundTripper creates a RoundTripper that records in metrics every request sent through it
+func NewMetricsRoundTripper(parent http.RoundTripper, metrics *Metrics) *MetricsRoundTripper {
+	return &MetricsRoundTripper{parent: parent, metrics: metrics}
+}
+
+// MetricsRoundTripper RoundTripper that records statistics of the requests sent through it
+type MetricsRoundTripper struct {
+	parent  http.RoundTripper
+	metrics *Metrics
+}
+
+// RoundTrip sends the request using the parent RoundTripper and records its statistics
+func (m *MetricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests sent more than once, by the retry or rate limit RoundTrippers, share the attempts counter
+	retry := false
+	if attempts, ok := req.Context().Value(attemptsKey{}).(*int); ok {
+		*attempts++
+		retry = *attempts > 1
+	}
+
+	// Token requests are identified before the body is consumed
+	tokenRequest := isTokenRequest(req)
+	host := req.URL.Host
+
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = &countingReadCloser{ReadCloser: req.Body, count: func(n int) { m.metrics.addBytes(host, n, 0) }}
+	}
+
+	start := time.Now()
+	resp, err := m.parent.RoundTrip(req)
+	m.metrics.record(req, resp, time.Since(start), retry, tokenRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = &countingReadCloser{ReadCloser: resp.Body, count: func(n int) { m.metrics.addBytes(host, 0, n) }}
+	return resp, nil
+}
+
+// newAttemptsRoundTripper creates a RoundTripper that allows MetricsRoundTripper to identify retried requests,
+// it needs to wrap all the RoundTrippers that retry requests
+func newAttemptsRoundTripper(parent http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts := 0
+		return parent.RoundTrip(req.WithContext(context.WithValue(req.Context(), attemptsKey{}, &attempts)))
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	count func(int)
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		c.count(n)
+	}
+	return n, err
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	expectedDigest := "sha256:477c34d98f9e090a4441cf82d2f1f03e64c8eb730e8c1ef39a8595e685d4df65"
+
+	// registry that requires a bearer token and throttles the first manifest request
+	manifestRequests := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token": "some-token"}`))
+		case r.Header.Get("Authorization") != "Bearer some-token":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		default:
+			manifestRequests++
+			if manifestRequests == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Header().Set("Content-Type", string(types.DockerManifestSchema2))
+			w.Header().Set("Docker-Content-Digest", expectedDigest)
+			w.Write([]byte("doesn't matter"))
+		}
+	}))
+	defer server.Close()
+
+	metrics := registry.NewMetrics()
+	subject, err := registry.NewSimpleRegistry(registry.Opts{Anon: true, Metrics: metrics, MaxThrottleWait: registry.DefaultMaxThrottleWait})
+	require.NoError(t, err)
+
+	imgRef, err := name.ParseReference(fmt.Sprintf("%s/repo:latest", serverHost(t, server)))
+	require.NoError(t, err)
+	digest, err := subject.Digest(imgRef)
+	require.NoError(t, err)
+	require.Equal(t, expectedDigest, digest.String())
+
+	report := metrics.Report()
+	require.Contains(t, report.Hosts, serverHost(t, server))
+	hostReport := report.Hosts[serverHost(t, server)]
+
+	assert.Equal(t, 1, hostReport.Retries)
+	assert.Equal(t, 1, hostReport.AuthRoundTrips)
+	assert.Equal(t, 1, hostReport.Requests["HEAD"]["429"])
+	assert.Equal(t, 1, hostReport.Requests["HEAD"]["200"])
+	assert.Equal(t, 1, hostReport.Requests["GET"]["401"])
+	assert.Greater(t, hostReport.TotalRequests, 3)
+	assert.Greater(t, hostReport.BytesReceived, int64(0))
+	assert.GreaterOrEqual(t, hostReport.LatencyMs.P95, hostReport.LatencyMs.P50)
+
+	t.Run("writes the report as JSON", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "metrics.json")
+		require.NoError(t, metrics.WriteReport(path))
+
+		bs, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var written registry.MetricsReport
+		require.NoError(t, json.Unmarshal(bs, &written))
+		assert.Equal(t, report, written)
+	})
+
+	t.Run("when the token is served from the token cache, it does not count an auth round trip", func(t *testing.T) {
+		tokenCacheDir := t.TempDir()
+		digestWithMetrics := func() registry.HostMetricsReport {
+			metrics := registry.NewMetrics()
+			subject, err := registry.NewSimpleRegistry(registry.Opts{Anon: true, Metrics: metrics, TokenCacheDir: tokenCacheDir})
+			require.NoError(t, err)
+			_, err = subject.Digest(imgRef)
+			require.NoError(t, err)
+			return metrics.Report().Hosts[serverHost(t, server)]
+		}
+
+		assert.Equal(t, 1, digestWithMetrics().AuthRoundTrips)
+
+		cachedReport := digestWithMetrics()
+		assert.Equal(t, 0, cachedReport.AuthRoundTrips)
+		assert.Equal(t, 0, cachedReport.Requests["GET"]["200"], "expected the cached token to not be counted as a request")
+	})
+}
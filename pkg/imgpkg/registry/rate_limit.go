@@ -0,0 +1,218 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+const (
+	// DefaultMaxThrottleWait maximum time spent waiting for a single request when the registry is throttling
+	DefaultMaxThrottleWait = 2 * time.Minute
+	// maxThrottleBackoff maximum wait between retries when the registry does not say how long to wait
+	maxThrottleBackoff = 30 * time.Second
+)
+
+// NewRateLimitRoundTripper creates a RoundTripper that retries requests throttled by the registry (429 Too Many Requests,
+// or 503 Service Unavailable with Retry-After or RateLimit-* headers) after the time requested by the registry, as long as
+// the total wait does not exceed maxWait. When maxWait is 0 throttled requests are not retried.
+// Throttled hosts get their number of concurrent requests reduced
+func NewRateLimitRoundTripper(parent http.RoundTripper, maxWait time.Duration, logger util.Logger) *RateLimitRoundTripper {
+	return &RateLimitRoundTripper{
+		parent:  parent,
+		maxWait: maxWait,
+		logger:  logger,
+		hosts:   map[hostLimiterKey]*hostLimiter{},
+		now:     time.Now,
+		sleep:   sleepWithContext,
+	}
+}
+
+// RateLimitRoundTripper RoundTripper that honors the Retry-After and RateLimit-Reset headers of throttled responses
+type RateLimitRoundTripper struct {
+	parent  http.RoundTripper
+	maxWait time.Duration
+	logger  util.Logger
+
+	hosts     map[hostLimiterKey]*hostLimiter
+	hostsLock sync.Mutex
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// hostLimiterKey blob downloads are limited separately from the other requests to the host, because their limiter
+// slot is only released once their body is closed, and a blob being copied is read while it is uploaded
+type hostLimiterKey struct {
+	host     string
+	download bool
+}
+
+// RoundTrip sends the request, waiting and retrying while the registry is throttling
+func (r *RateLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	download := req.Method == http.MethodGet && strings.Contains(req.URL.Path, "/blobs/")
+	limiter := r.limiter(hostLimiterKey{host: req.URL.Host, download: download})
+	var waited time.Duration
+
+	for attempt := 0; ; attempt++ {
+		limiter.acquire()
+		resp, err := r.parent.RoundTrip(req)
+		if err != nil {
+			limiter.release()
+			return nil, err
+		}
+		if !isThrottled(resp) {
+			limiter.succeeded()
+			if download {
+				resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: limiter.release}
+			} else {
+				limiter.release()
+			}
+			return resp, nil
+		}
+
+		limit := limiter.throttled()
+		limiter.release()
+		wait := throttleWait(resp, attempt, r.now())
+		if r.maxWait <= 0 || waited+wait > r.maxWait || (req.Body != nil && req.GetBody == nil) {
+			// flag the request of the response so that the retry predicate does not retry it again
+			resp.Request = req.WithContext(context.WithValue(req.Context(), throttledContextKey{}, true))
+			return resp, nil
+		}
+
+		if r.logger != nil {
+			r.logger.Logf("Registry %s is throttling requests (%s), waiting %s before retrying with at most %d concurrent requests\n",
+				req.URL.Host, resp.Status, wait.Round(time.Second), limit)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if err := r.sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+		waited += wait
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+func (r *RateLimitRoundTripper) limiter(key hostLimiterKey) *hostLimiter {
+	r.hostsLock.Lock()
+	defer r.hostsLock.Unlock()
+
+	limiter, found := r.hosts[key]
+	if !found {
+		limiter = newHostLimiter()
+		r.hosts[key] = limiter
+	}
+	return limiter
+}
+
+// releaseOnClose releases the limiter slot of a response once its body is closed
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+// Close closes the body and releases the limiter slot
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+type throttledContextKey struct{}
+
+// isThrottled returns true for 429 responses, and for 503 responses that tell how long to wait
+func isThrottled(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		return false
+	}
+	for key := range resp.Header {
+		if key == "Retry-After" || strings.HasPrefix(key, "Ratelimit-") {
+			return true
+		}
+	}
+	return false
+}
+
+// isRetryableError same as the default retry predicate of go-containerregistry, except for throttled responses.
+// RateLimitRoundTripper is the only one retrying them, retrying them again would compound the waits
+func isRetryableError(err error) bool {
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) && transportErr.Request != nil && transportErr.Request.Context().Value(throttledContextKey{}) != nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var temporaryErr interface{ Temporary() bool }
+	if errors.As(err, &temporaryErr) && temporaryErr.Temporary() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, net.ErrClosed)
+}
+
+// throttleWait returns how long to wait before retrying, based on the Retry-After or RateLimit-Reset headers.
+// When none is present it uses an exponential backoff
+func throttleWait(resp *http.Response, attempt int, now time.Time) time.Duration {
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(retryAfter); err == nil {
+			if wait := date.Sub(now); wait > 0 {
+				return wait
+			}
+			return 0
+		}
+	}
+	if reset := resp.Header.Get("RateLimit-Reset"); reset != "" {
+		if seconds, err := strconv.Atoi(reset); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	wait := time.Second << attempt
+	if wait > maxThrottleBackoff || wait <= 0 {
+		wait = maxThrottleBackoff
+	}
+	return wait
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitRoundTripper(t *testing.T) {
+	expectedDigest := "sha256:477c34d98f9e090a4441cf82d2f1f03e64c8eb730e8c1ef39a8595e685d4df65"
+
+	// registry that throttles the first throttledRequests manifest requests
+	newThrottlingServer := func(throttledRequests int, headers map[string]string) (*httptest.Server, *int) {
+		manifestRequests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v2/" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			manifestRequests++
+			if manifestRequests <= throttledRequests {
+				for key, value := range headers {
+					w.Header().Set(key, value)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Header().Set("Content-Type", string(types.DockerManifestSchema2))
+			w.Header().Set("Docker-Content-Digest", expectedDigest)
+			w.Write([]byte("doesn't matter"))
+		}))
+		return server, &manifestRequests
+	}
+
+	digest := func(t *testing.T, server *httptest.Server, opts registry.Opts) (string, error) {
+		opts.Anon = true
+		subject, err := registry.NewSimpleRegistry(opts)
+		require.NoError(t, err)
+
+		imgRef, err := name.ParseReference(fmt.Sprintf("%s/repo:latest", serverHost(t, server)))
+		require.NoError(t, err)
+		d, err := subject.Digest(imgRef)
+		return d.String(), err
+	}
+
+	t.Run("when the registry sends Retry-After, it waits and retries the request", func(t *testing.T) {
+		server, manifestRequests := newThrottlingServer(1, map[string]string{"Retry-After": "1"})
+		defer server.Close()
+		logs := &bytes.Buffer{}
+
+		start := time.Now()
+		d, err := digest(t, server, registry.Opts{MaxThrottleWait: registry.DefaultMaxThrottleWait, ThrottleLogger: util.NewBufferLogger(logs)})
+		require.NoError(t, err)
+		assert.Equal(t, expectedDigest, d)
+		assert.Equal(t, 2, *manifestRequests)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+		assert.Contains(t, logs.String(), fmt.Sprintf("Registry %s is throttling requests (429 Too Many Requests), waiting 1s before retrying", serverHost(t, server)))
+	})
+
+	t.Run("when the registry sends RateLimit-Reset, it waits and retries the request", func(t *testing.T) {
+		server, manifestRequests := newThrottlingServer(2, map[string]string{"RateLimit-Limit": "10", "RateLimit-Remaining": "0", "RateLimit-Reset": "0"})
+		defer server.Close()
+
+		d, err := digest(t, server, registry.Opts{MaxThrottleWait: registry.DefaultMaxThrottleWait})
+		require.NoError(t, err)
+		assert.Equal(t, expectedDigest, d)
+		assert.Equal(t, 3, *manifestRequests)
+	})
+
+	t.Run("when the registry asks to wait longer than the max wait, it fails without waiting", func(t *testing.T) {
+		server, _ := newThrottlingServer(100, map[string]string{"Retry-After": "600"})
+		defer server.Close()
+
+		start := time.Now()
+		_, err := digest(t, server, registry.Opts{MaxThrottleWait: 5 * time.Second})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "429")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("when the max wait is 0, it fails without retrying", func(t *testing.T) {
+		server, manifestRequests := newThrottlingServer(100, map[string]string{"Retry-After": "0"})
+		defer server.Close()
+
+		_, err := digest(t, server, registry.Opts{RetryCount: 3})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "429")
+		// HEAD and the GET used as fallback
+		assert.Equal(t, 2, *manifestRequests)
+	})
+
+	t.Run("when the registry is unavailable without asking to wait, the write is retried as any other failure", func(t *testing.T) {
+		manifestWrites := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/v2/" || (r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/blobs/")):
+				w.WriteHeader(http.StatusOK)
+			case r.Method == http.MethodPut:
+				manifestWrites++
+				if manifestWrites <= 2 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		subject, err := registry.NewSimpleRegistry(registry.Opts{Anon: true, RetryCount: 3})
+		require.NoError(t, err)
+		imgRef, err := name.ParseReference(fmt.Sprintf("%s/repo:latest", serverHost(t, server)))
+		require.NoError(t, err)
+
+		require.NoError(t, subject.WriteImage(imgRef, empty.Image, nil))
+		assert.Equal(t, 3, manifestWrites)
+	})
+
+	t.Run("when a write is still throttled after the max wait, it is not retried again", func(t *testing.T) {
+		manifestWrites := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/v2/" || (r.Method == http.MethodHead && strings.Contains(r.URL.Path, "/blobs/")):
+				w.WriteHeader(http.StatusOK)
+			case r.Method == http.MethodPut:
+				manifestWrites++
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusServiceUnavailable)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		subject, err := registry.NewSimpleRegistry(registry.Opts{Anon: true, RetryCount: 3, MaxThrottleWait: time.Second})
+		require.NoError(t, err)
+		imgRef, err := name.ParseReference(fmt.Sprintf("%s/repo:latest", serverHost(t, server)))
+		require.NoError(t, err)
+
+		err = subject.WriteImage(imgRef, empty.Image, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "503")
+		assert.Equal(t, 2, manifestWrites)
+	})
+}
+
+func TestRateLimitRoundTripper_Downloads(t *testing.T) {
+	throttle := true
+	subject := registry.NewRateLimitRoundTripper(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if throttle {
+			throttle = false
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("blob")), Request: req}, nil
+	}), 0, nil)
+
+	blobRequest, err := http.NewRequest(http.MethodGet, "https://registry.io/v2/repo/blobs/sha256:abc", nil)
+	require.NoError(t, err)
+
+	// the throttled response limits the downloads from the host to 1
+	resp, err := subject.RoundTrip(blobRequest)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	resp, err = subject.RoundTrip(blobRequest)
+	require.NoError(t, err)
+
+	downloaded := make(chan struct{})
+	go func() {
+		resp, err := subject.RoundTrip(blobRequest)
+		if err == nil {
+			resp.Body.Close()
+		}
+		close(downloaded)
+	}()
+
+	select {
+	case <-downloaded:
+		t.Fatalf("Expected the download to wait until the body of the previous download is closed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	manifestRequest, err := http.NewRequest(http.MethodGet, "https://registry.io/v2/repo/manifests/latest", nil)
+	require.NoError(t, err)
+	_, err = subject.RoundTrip(manifestRequest)
+	require.NoError(t, err, "other requests are not limited by the downloads")
+
+	require.NoError(t, resp.Body.Close())
+	select {
+	case <-downloaded:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the download to continue after the body of the previous download was closed")
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -9,11 +9,13 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
 	"sync"
 	"time"
 
+	"carvel.dev/imgpkg/pkg/imgpkg/cache"
 	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
 	"carvel.dev/imgpkg/pkg/imgpkg/registry/auth"
 	regauthn "github.com/google/go-containerregistry/pkg/authn"
@@ -45,6 +47,50 @@ type Opts struct {
 	ActiveKeychains []auth.IAASKeychain
 
 	SessionID string
+
+	// ConfigPath path to a RegistryConfig file with mirrors, rewrites and per registry settings
+	ConfigPath string
+	// TokenCacheDir when set, tokens retrieved from the registry token servers are cached in this directory
+	// and reused until they expire
+	TokenCacheDir string
+	// CacheDir when set, layers and image configurations are cached in this directory and reused by
+	// following reads of the same blobs
+	CacheDir string
+	// CacheMaxSize maximum size in bytes of CacheDir, the least recently used blobs are removed when exceeded.
+	// Unlimited when 0
+	CacheMaxSize int64
+
+	// AuthFiles docker config files, i.e. Kubernetes dockerconfigjson secrets, with credentials per registry.
+	// Their credentials take precedence over all keychains except the environment variables
+	AuthFiles []string
+	// AuthDebugLogger when set, receives which keychain provided the credentials for each registry
+	AuthDebugLogger util.Logger
+
+	// MaxThrottleWait maximum time to wait for a request when the registry is throttling (429 Too Many Requests
+	// or 503 Service Unavailable asking to wait), throttled requests are not retried when 0
+	MaxThrottleWait time.Duration
+	// ThrottleLogger when set, receives a message every time a registry throttles a request
+	ThrottleLogger util.Logger
+	// Metrics when set, collects statistics of the requests sent to each registry
+	Metrics *Metrics
+
+	// HostConfigs per registry settings in the format accepted by ParseHostConfig,
+	// they take precedence over the settings in ConfigPath
+	HostConfigs []string
+
+	// Proxy URL of the proxy used for all registries, overrides the proxy in ConfigPath and
+	// the HTTPS_PROXY and HTTP_PROXY environment variables
+	Proxy string
+	// NoProxy hosts reached without a proxy, in the same format as the NO_PROXY environment variable,
+	// in addition to the hosts in NO_PROXY
+	NoProxy []string
+
+	// Backend when set, repositories are stored in a local directory instead of their registries.
+	// Format: oci-layout:/path, each repository is an OCI image layout in /path/<registry>/<repository>
+	Backend string
+	// Layouts directory of the OCI image layout of each repository, by repository name, that is stored
+	// in the layout instead of its registry
+	Layouts map[string]string
 }
 
 // DeepCopy the options to a new struct
@@ -61,13 +107,38 @@ func (o Opts) DeepCopy() Opts {
 		ResponseHeaderTimeout:         o.ResponseHeaderTimeout,
 		RetryCount:                    o.RetryCount,
 		EnvironFunc:                   o.EnvironFunc,
+		AuthDebugLogger:               o.AuthDebugLogger,
+		TokenCacheDir:                 o.TokenCacheDir,
+		CacheDir:                      o.CacheDir,
+		CacheMaxSize:                  o.CacheMaxSize,
+		ConfigPath:                    o.ConfigPath,
+		MaxThrottleWait:               o.MaxThrottleWait,
+		ThrottleLogger:                o.ThrottleLogger,
+		Metrics:                       o.Metrics,
+		Proxy:                         o.Proxy,
+		Backend:                       o.Backend,
 	}
 	for _, path := range o.CACertPaths {
 		result.CACertPaths = append(result.CACertPaths, path)
 	}
+	for _, path := range o.AuthFiles {
+		result.AuthFiles = append(result.AuthFiles, path)
+	}
+	for _, hostConfig := range o.HostConfigs {
+		result.HostConfigs = append(result.HostConfigs, hostConfig)
+	}
+	for _, host := range o.NoProxy {
+		result.NoProxy = append(result.NoProxy, host)
+	}
 	for _, keychain := range o.ActiveKeychains {
 		result.ActiveKeychains = append(result.ActiveKeychains, keychain)
 	}
+	if o.Layouts != nil {
+		result.Layouts = map[string]string{}
+		for repo, path := range o.Layouts {
+			result.Layouts[repo] = path
+		}
+	}
 	return result
 }
 
@@ -101,6 +172,38 @@ type ImagesReader interface {
 	FirstImageExists(digests []string) (string, error)
 }
 
+// BlobCache Interface implemented by the registries that cache the blobs they read
+type BlobCache interface {
+	CacheImage(regv1.Image) regv1.Image
+	CacheIndex(regv1.ImageIndex) regv1.ImageIndex
+}
+
+// DescriptorImage returns the image of desc, a descriptor retrieved with reader.Get. When reader is a BlobCache
+// the layers and configuration of the image are read from its cache
+func DescriptorImage(reader interface{}, desc *regremote.Descriptor) (regv1.Image, error) {
+	img, err := desc.Image()
+	if err != nil {
+		return nil, err
+	}
+	if blobCache, ok := reader.(BlobCache); ok {
+		img = blobCache.CacheImage(img)
+	}
+	return img, nil
+}
+
+// DescriptorIndex returns the index of desc, a descriptor retrieved with reader.Get. When reader is a BlobCache
+// the images in the index read their layers and configuration from its cache
+func DescriptorIndex(reader interface{}, desc *regremote.Descriptor) (regv1.ImageIndex, error) {
+	idx, err := desc.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	if blobCache, ok := reader.(BlobCache); ok {
+		idx = blobCache.CacheIndex(idx)
+	}
+	return idx, nil
+}
+
 // ImagesReaderWriter Interface for Reading and Writing Images
 //
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 . ImagesReaderWriter
@@ -132,6 +235,10 @@ type SimpleRegistry struct {
 	authn           map[string]regauthn.Authenticator
 	roundTrippers   RoundTripperStorage
 	transportAccess *sync.Mutex
+	config          hostsConfig
+	cache           *cache.Cache
+	// layouts when set, the repositories it handles are read from and written to OCI image layouts
+	layouts *LayoutRegistry
 }
 
 // NewBasicRegistry does not provide any special behavior and all the options as passed as is to the underlying library
@@ -145,15 +252,67 @@ func NewBasicRegistry(regOpts ...regremote.Option) (*SimpleRegistry, error) {
 
 // NewSimpleRegistry Builder for a Simple Registry
 func NewSimpleRegistry(opts Opts) (*SimpleRegistry, error) {
-	httpTran, err := newHTTPTransport(opts)
+	config, err := readConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := newProxySelector(opts, config)
+	if err != nil {
+		return nil, fmt.Errorf("Configuring registry proxy: %s", err)
+	}
+
+	httpTran, err := newHostHTTPTransport(opts, HostConfig{}, proxy.Proxy)
 	if err != nil {
 		return nil, fmt.Errorf("Creating registry HTTP transport: %s", err)
 	}
-	return NewSimpleRegistryWithTransport(opts, httpTran)
+
+	var rTripper http.RoundTripper = httpTran
+	hostsTran, err := newHostsHTTPTransports(opts, config, proxy.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("Creating registry HTTP transport: %s", err)
+	}
+	if len(hostsTran) > 0 {
+		rTripper = NewHostsRoundTripper(httpTran, hostsTran)
+	}
+
+	return newSimpleRegistry(opts, config, rTripper)
 }
 
 // NewSimpleRegistryWithTransport Creates a new Simple Registry using the provided transport
+// Mirrors and rewrites present in the configuration file are applied, but the per registry TLS and proxy settings are not
+// since all requests go through the provided transport
 func NewSimpleRegistryWithTransport(opts Opts, rTripper http.RoundTripper) (*SimpleRegistry, error) {
+	config, err := readConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	return newSimpleRegistry(opts, config, rTripper)
+}
+
+func readConfig(opts Opts) (hostsConfig, error) {
+	config := Config{APIVersion: ConfigAPIVersion, Kind: ConfigKind}
+	if opts.ConfigPath != "" {
+		var err error
+		config, err = NewConfigFromPath(opts.ConfigPath)
+		if err != nil {
+			return hostsConfig{}, fmt.Errorf("Reading registry config: %s", err)
+		}
+	}
+
+	var overrides []HostConfig
+	for _, value := range opts.HostConfigs {
+		hostConfig, err := ParseHostConfig(value)
+		if err != nil {
+			return hostsConfig{}, fmt.Errorf("Parsing registry host config: %s", err)
+		}
+		overrides = append(overrides, hostConfig)
+	}
+
+	return newHostsConfig(config, overrides)
+}
+
+func newSimpleRegistry(opts Opts, config hostsConfig, rTripper http.RoundTripper) (*SimpleRegistry, error) {
 	var refOpts []regname.Option
 	if opts.Insecure {
 		refOpts = append(refOpts, regname.Insecure)
@@ -167,6 +326,9 @@ func NewSimpleRegistryWithTransport(opts Opts, rTripper http.RoundTripper) (*Sim
 			Anon:                    opts.Anon,
 			EnableIaasAuthProviders: opts.EnableIaasAuthProviders,
 			ActiveKeychains:         opts.ActiveKeychains,
+			AuthFiles:               opts.AuthFiles,
+			CredentialHelpers:       config.credentialHelpers(),
+			DebugLogger:             opts.AuthDebugLogger,
 		},
 		opts.EnvironFunc,
 	)
@@ -190,21 +352,52 @@ func NewSimpleRegistryWithTransport(opts Opts, rTripper http.RoundTripper) (*Sim
 		Steps:    tries,
 		Cap:      1 * time.Second,
 	}
-	regRemoteOptions = append(regRemoteOptions, regremote.WithRetryBackoff(retryBackoff))
+	regRemoteOptions = append(regRemoteOptions, regremote.WithRetryBackoff(retryBackoff), regremote.WithRetryPredicate(isRetryableError))
 
 	baseRoundTripper := rTripper
 	if logs.Enabled(logs.Debug) {
 		baseRoundTripper = transport.NewLogger(rTripper)
 	}
 
+	// Metrics are recorded below the token cache so that only the requests sent to the registries are counted
+	if opts.Metrics != nil {
+		baseRoundTripper = NewMetricsRoundTripper(baseRoundTripper, opts.Metrics)
+	}
+
+	if opts.TokenCacheDir != "" {
+		baseRoundTripper = NewTokenCacheRoundTripper(baseRoundTripper, opts.TokenCacheDir)
+	}
+
 	sessionID := opts.SessionID
 	if sessionID == "" {
 		sessionID = fmt.Sprint(rand.Int31())
 	}
 	baseRoundTripper = NewImgpkgRoundTripper(baseRoundTripper, sessionID)
 
+	baseRoundTripper = NewRateLimitRoundTripper(baseRoundTripper, opts.MaxThrottleWait, opts.ThrottleLogger)
+
 	// Wrap the transport in something that can retry network flakes.
-	baseRoundTripper = transport.NewRetry(baseRoundTripper, transport.WithRetryBackoff(retryBackoff))
+	baseRoundTripper = transport.NewRetry(baseRoundTripper, transport.WithRetryBackoff(retryBackoff), transport.WithRetryPredicate(isRetryableError))
+	if opts.Metrics != nil {
+		baseRoundTripper = newAttemptsRoundTripper(baseRoundTripper)
+	}
+
+	var blobCache *cache.Cache
+	if opts.CacheDir != "" {
+		blobCache = cache.NewCache(opts.CacheDir, opts.CacheMaxSize)
+	}
+
+	var layouts *LayoutRegistry
+	if opts.Backend != "" || len(opts.Layouts) > 0 {
+		dir := ""
+		if opts.Backend != "" {
+			dir, err = parseBackend(opts.Backend)
+			if err != nil {
+				return nil, err
+			}
+		}
+		layouts = NewLayoutRegistry(dir, opts.Layouts)
+	}
 
 	return &SimpleRegistry{
 		remoteOpts:      regRemoteOptions,
@@ -213,6 +406,9 @@ func NewSimpleRegistryWithTransport(opts Opts, rTripper http.RoundTripper) (*Sim
 		roundTrippers:   NewMultiRoundTripperStorage(baseRoundTripper),
 		authn:           map[string]regauthn.Authenticator{},
 		transportAccess: &sync.Mutex{},
+		config:          config,
+		cache:           blobCache,
+		layouts:         layouts,
 	}, nil
 }
 
@@ -224,6 +420,11 @@ func (r SimpleRegistry) CloneWithSingleAuth(imageRef regname.Tag) (Registry, err
 		return NewBasicRegistry(r.remoteOpts...)
 	}
 
+	imageRef, err := r.resolveTag(imageRef)
+	if err != nil {
+		return nil, err
+	}
+
 	imgAuth, err := r.keychain.Resolve(imageRef)
 	if err != nil {
 		return nil, err
@@ -247,6 +448,10 @@ func (r SimpleRegistry) CloneWithSingleAuth(imageRef regname.Tag) (Registry, err
 		roundTrippers:   singleRt,
 		authn:           map[string]regauthn.Authenticator{},
 		transportAccess: &sync.Mutex{},
+		// The single auth is only valid for imageRef's registry so mirrors cannot be used
+		config:  r.config.withoutMirrors(),
+		cache:   r.cache,
+		layouts: r.layouts,
 	}, nil
 }
 
@@ -260,6 +465,9 @@ func (r SimpleRegistry) CloneWithLogger(_ util.ProgressLogger) Registry {
 		roundTrippers:   r.roundTrippers,
 		authn:           map[string]regauthn.Authenticator{},
 		transportAccess: &sync.Mutex{},
+		config:          r.config,
+		cache:           r.cache,
+		layouts:         r.layouts,
 	}
 }
 
@@ -330,15 +538,16 @@ func (r *SimpleRegistry) Get(ref regname.Reference) (*regremote.Descriptor, erro
 	if err := r.validateRef(ref); err != nil {
 		return nil, err
 	}
-	overriddenRef, err := regname.ParseReference(ref.String(), r.refOpts...)
-	if err != nil {
-		return nil, err
-	}
-	opts, err := r.readOpts(overriddenRef)
-	if err != nil {
-		return nil, err
+	if r.layouts.handles(ref.Context()) {
+		return r.layouts.Get(ref)
 	}
-	return regremote.Get(overriddenRef, opts...)
+	var desc *regremote.Descriptor
+	err := r.read(ref, func(overriddenRef regname.Reference, opts []regremote.Option) error {
+		var err error
+		desc, err = regremote.Get(overriddenRef, opts...)
+		return err
+	})
+	return desc, err
 }
 
 // Digest Retrieve the Digest for an Image reference
@@ -346,25 +555,25 @@ func (r *SimpleRegistry) Digest(ref regname.Reference) (regv1.Hash, error) {
 	if err := r.validateRef(ref); err != nil {
 		return regv1.Hash{}, err
 	}
-	overriddenRef, err := regname.ParseReference(ref.String(), r.refOpts...)
-	if err != nil {
-		return regv1.Hash{}, err
+	if r.layouts.handles(ref.Context()) {
+		return r.layouts.Digest(ref)
 	}
-
-	opts, err := r.readOpts(overriddenRef)
-	if err != nil {
-		return regv1.Hash{}, err
-	}
-	desc, err := regremote.Head(overriddenRef, opts...)
-	if err != nil {
-		getDesc, err := regremote.Get(overriddenRef, opts...)
+	var digest regv1.Hash
+	err := r.read(ref, func(overriddenRef regname.Reference, opts []regremote.Option) error {
+		desc, err := regremote.Head(overriddenRef, opts...)
 		if err != nil {
-			return regv1.Hash{}, err
+			getDesc, err := regremote.Get(overriddenRef, opts...)
+			if err != nil {
+				return err
+			}
+			digest = getDesc.Digest
+			return nil
 		}
-		return getDesc.Digest, nil
-	}
 
-	return desc.Digest, nil
+		digest = desc.Digest
+		return nil
+	})
+	return digest, err
 }
 
 // Image Retrieve the regv1.Image struct for an Image reference
@@ -372,28 +581,52 @@ func (r *SimpleRegistry) Image(ref regname.Reference) (regv1.Image, error) {
 	if err := r.validateRef(ref); err != nil {
 		return nil, err
 	}
-	overriddenRef, err := regname.ParseReference(ref.String(), r.refOpts...)
+	if r.layouts.handles(ref.Context()) {
+		return r.layouts.Image(ref)
+	}
+	var img regv1.Image
+	err := r.read(ref, func(overriddenRef regname.Reference, opts []regremote.Option) error {
+		var err error
+		img, err = regremote.Image(overriddenRef, opts...)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return r.CacheImage(img), nil
+}
 
-	opts, err := r.readOpts(overriddenRef)
-	if err != nil {
-		return nil, err
+// CacheImage wraps img so that its layers and configuration are read from the blob cache, when enabled
+func (r *SimpleRegistry) CacheImage(img regv1.Image) regv1.Image {
+	if r.cache == nil {
+		return img
 	}
-	return regremote.Image(overriddenRef, opts...)
+	return r.cache.Image(img)
+}
+
+// CacheIndex wraps idx so that its images read their layers and configuration from the blob cache, when enabled
+func (r *SimpleRegistry) CacheIndex(idx regv1.ImageIndex) regv1.ImageIndex {
+	if r.cache == nil {
+		return idx
+	}
+	return r.cache.Index(idx)
 }
 
 // MultiWrite Upload multiple Images in Parallel to the Registry
 func (r *SimpleRegistry) MultiWrite(imageOrIndexesToUpload map[regname.Reference]regremote.Taggable, concurrency int, updatesCh chan regv1.Update) error {
 	overriddenImageOrIndexesToUploadRef := map[regname.Reference]regremote.Taggable{}
+	layoutImageOrIndexesToUpload := map[regname.Reference]regremote.Taggable{}
 
 	var singleRef regname.Reference
 	for ref, taggable := range imageOrIndexesToUpload {
 		if err := r.validateRef(ref); err != nil {
 			return err
 		}
-		overriddenRef, err := regname.ParseReference(ref.String(), r.refOpts...)
+		if r.layouts.handles(ref.Context()) {
+			layoutImageOrIndexesToUpload[ref] = taggable
+			continue
+		}
+		overriddenRef, err := r.resolveRef(ref)
 		if err != nil {
 			return err
 		}
@@ -402,6 +635,13 @@ func (r *SimpleRegistry) MultiWrite(imageOrIndexesToUpload map[regname.Reference
 		overriddenImageOrIndexesToUploadRef[overriddenRef] = taggable
 	}
 
+	if len(layoutImageOrIndexesToUpload) > 0 {
+		err := r.layouts.MultiWrite(layoutImageOrIndexesToUpload, concurrency, nil)
+		if err != nil || len(overriddenImageOrIndexesToUploadRef) == 0 {
+			return err
+		}
+	}
+
 	opts, err := r.writeOpts(singleRef)
 	if err != nil {
 		return err
@@ -418,7 +658,10 @@ func (r *SimpleRegistry) WriteImage(ref regname.Reference, img regv1.Image, upda
 	if err := r.validateRef(ref); err != nil {
 		return err
 	}
-	overriddenRef, err := regname.ParseReference(ref.String(), r.refOpts...)
+	if r.layouts.handles(ref.Context()) {
+		return r.layouts.WriteImage(ref, img, updatesCh)
+	}
+	overriddenRef, err := r.resolveRef(ref)
 	if err != nil {
 		return err
 	}
@@ -443,15 +686,19 @@ func (r *SimpleRegistry) Index(ref regname.Reference) (regv1.ImageIndex, error)
 	if err := r.validateRef(ref); err != nil {
 		return nil, err
 	}
-	overriddenRef, err := regname.ParseReference(ref.String(), r.refOpts...)
-	if err != nil {
-		return nil, err
+	if r.layouts.handles(ref.Context()) {
+		return r.layouts.Index(ref)
 	}
-	opts, err := r.readOpts(overriddenRef)
+	var idx regv1.ImageIndex
+	err := r.read(ref, func(overriddenRef regname.Reference, opts []regremote.Option) error {
+		var err error
+		idx, err = regremote.Index(overriddenRef, opts...)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	return regremote.Index(overriddenRef, opts...)
+	return r.CacheIndex(idx), nil
 }
 
 // WriteIndex Uploads the Index manifest to the registry
@@ -459,7 +706,10 @@ func (r *SimpleRegistry) WriteIndex(ref regname.Reference, idx regv1.ImageIndex)
 	if err := r.validateRef(ref); err != nil {
 		return err
 	}
-	overriddenRef, err := regname.ParseReference(ref.String(), r.refOpts...)
+	if r.layouts.handles(ref.Context()) {
+		return r.layouts.WriteIndex(ref, idx)
+	}
+	overriddenRef, err := r.resolveRef(ref)
 	if err != nil {
 		return err
 	}
@@ -482,7 +732,10 @@ func (r *SimpleRegistry) WriteTag(ref regname.Tag, taggagle regremote.Taggable)
 	if err := r.validateRef(ref); err != nil {
 		return err
 	}
-	overriddenRef, err := regname.NewTag(ref.String(), r.refOpts...)
+	if r.layouts.handles(ref.Context()) {
+		return r.layouts.WriteTag(ref, taggagle)
+	}
+	overriddenRef, err := r.resolveTag(ref)
 	if err != nil {
 		return err
 	}
@@ -502,20 +755,16 @@ func (r *SimpleRegistry) WriteTag(ref regname.Tag, taggagle regremote.Taggable)
 
 // ListTags Retrieve all tags associated with a Repository
 func (r *SimpleRegistry) ListTags(repo regname.Repository) ([]string, error) {
-	overriddenRepo, err := regname.NewRepository(repo.Name(), r.refOpts...)
-	if err != nil {
-		return nil, err
-	}
-	repoRef, err := regname.ParseReference(overriddenRepo.String(), r.refOpts...)
-	if err != nil {
-		return nil, err
+	if r.layouts.handles(repo) {
+		return r.layouts.ListTags(repo)
 	}
-	opts, err := r.readOpts(repoRef)
-	if err != nil {
-		return nil, err
-	}
-
-	return regremote.List(overriddenRepo, opts...)
+	var tags []string
+	err := r.read(repo.Tag(regname.DefaultTag), func(overriddenRef regname.Reference, opts []regremote.Option) error {
+		var err error
+		tags, err = regremote.List(overriddenRef.Context(), opts...)
+		return err
+	})
+	return tags, err
 }
 
 // FirstImageExists Returns the first of the provided Image Digests that exists in the Registry
@@ -534,7 +783,25 @@ func (r *SimpleRegistry) FirstImageExists(digests []string) (string, error) {
 	return "", fmt.Errorf("Checking image existence: %s", err)
 }
 
-func newHTTPTransport(opts Opts) (*http.Transport, error) {
+// newHostsHTTPTransports creates a transport for each registry in config that has its own TLS settings
+func newHostsHTTPTransports(opts Opts, config hostsConfig, proxy func(*http.Request) (*url.URL, error)) (map[string]http.RoundTripper, error) {
+	result := map[string]http.RoundTripper{}
+	for registry, host := range config.hosts {
+		if !host.hasTLSConfig() {
+			continue
+		}
+
+		hostTran, err := newHostHTTPTransport(opts, host, proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Registry '%s': %s", registry, err)
+		}
+		result[registry] = hostTran
+	}
+	return result, nil
+}
+
+// newHostHTTPTransport creates a transport using the TLS settings from opts combined with the ones from host
+func newHostHTTPTransport(opts Opts, host HostConfig, proxy func(*http.Request) (*url.URL, error)) (*http.Transport, error) {
 	var pool *x509.CertPool
 
 	var err error
@@ -543,8 +810,9 @@ func newHTTPTransport(opts Opts) (*http.Transport, error) {
 		return nil, err
 	}
 
-	if len(opts.CACertPaths) > 0 {
-		for _, path := range opts.CACertPaths {
+	caCertPaths := append(append([]string{}, opts.CACertPaths...), host.CACertPaths...)
+	if len(caCertPaths) > 0 {
+		for _, path := range caCertPaths {
 			if certs, err := os.ReadFile(path); err != nil {
 				return nil, fmt.Errorf("Reading CA certificates from '%s': %s", path, err)
 			} else if ok := pool.AppendCertsFromPEM(certs); !ok {
@@ -553,12 +821,28 @@ func newHTTPTransport(opts Opts) (*http.Transport, error) {
 		}
 	}
 
+	verifyCerts := opts.VerifyCerts
+	if host.VerifyCerts != nil {
+		verifyCerts = *host.VerifyCerts
+	}
+
+	var clientCerts []tls.Certificate
+	if host.ClientCertPath != "" {
+		clientCert, err := tls.LoadX509KeyPair(host.ClientCertPath, host.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Loading client certificate from '%s' and '%s': %s", host.ClientCertPath, host.ClientKeyPath, err)
+		}
+		clientCerts = append(clientCerts, clientCert)
+	}
+
 	clonedDefaultTransport := http.DefaultTransport.(*http.Transport).Clone()
 	clonedDefaultTransport.ForceAttemptHTTP2 = false
 	clonedDefaultTransport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	clonedDefaultTransport.Proxy = proxy
 	clonedDefaultTransport.TLSClientConfig = &tls.Config{
 		RootCAs:            pool,
-		InsecureSkipVerify: opts.VerifyCerts == false,
+		Certificates:       clientCerts,
+		InsecureSkipVerify: verifyCerts == false,
 	}
 
 	return clonedDefaultTransport, nil
@@ -572,3 +856,66 @@ func (SimpleRegistry) validateRef(ref regname.Reference) error {
 	}
 	return nil
 }
+
+// read calls do with the mirrors of ref, in order, until one of them succeeds, falling back to ref itself.
+// When all of them fail the error of the last attempt is returned
+func (r *SimpleRegistry) read(ref regname.Reference, do func(regname.Reference, []regremote.Option) error) error {
+	overriddenRef, err := r.resolveRef(ref)
+	if err != nil {
+		return err
+	}
+
+	var refs []regname.Reference
+	for _, mirror := range r.config.mirrors(overriddenRef.Context()) {
+		mirrorRef, err := r.parseReference(joinReference(mirror, overriddenRef))
+		if err != nil {
+			return err
+		}
+		refs = append(refs, mirrorRef)
+	}
+	refs = append(refs, overriddenRef)
+
+	for _, readRef := range refs {
+		var opts []regremote.Option
+		opts, err = r.readOpts(readRef)
+		if err != nil {
+			continue
+		}
+		err = do(readRef, opts)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// resolveRef applies the rewrite rules to ref and parses it with the options of the resulting registry
+func (r *SimpleRegistry) resolveRef(ref regname.Reference) (regname.Reference, error) {
+	return r.parseReference(joinReference(r.config.rewrite(ref.Context()), ref))
+}
+
+// resolveTag applies the rewrite rules to ref and parses it with the options of the resulting registry
+func (r *SimpleRegistry) resolveTag(ref regname.Tag) (regname.Tag, error) {
+	name := joinReference(r.config.rewrite(ref.Context()), ref)
+	return regname.NewTag(name, r.refOptsFor(name)...)
+}
+
+func (r *SimpleRegistry) parseReference(name string) (regname.Reference, error) {
+	return regname.ParseReference(name, r.refOptsFor(name)...)
+}
+
+// refOptsFor returns the options used to parse name, taking into account if its registry is configured as insecure
+func (r *SimpleRegistry) refOptsFor(name string) []regname.Option {
+	if r.config.insecure(name) {
+		return append([]regname.Option{regname.Insecure}, r.refOpts...)
+	}
+	return r.refOpts
+}
+
+// joinReference creates the name of the reference with the same tag or digest as ref in repo
+func joinReference(repo string, ref regname.Reference) string {
+	if _, ok := ref.(regname.Digest); ok {
+		return repo + "@" + ref.Identifier()
+	}
+	return repo + ":" + ref.Identifier()
+}
@@ -4,15 +4,30 @@
 package registry_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	"carvel.dev/imgpkg/test/helpers"
 	"github.com/google/go-containerregistry/pkg/name"
 	regremote "github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
@@ -108,6 +123,408 @@ func TestRegistry_TransportHeaders(t *testing.T) {
 	})
 }
 
+func TestRegistry_Config(t *testing.T) {
+	upstreamDigest := "sha256:477c34d98f9e090a4441cf82d2f1f03e64c8eb730e8c1ef39a8595e685d4df65"
+	mirrorDigest := "sha256:9e1b8b3d0b3e3b0c5e6c1d2a2b0c5e8f7d6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c"
+
+	writeConfig := func(t *testing.T, config string) string {
+		path := filepath.Join(t.TempDir(), "config.yml")
+		require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+		return path
+	}
+
+	t.Run("when reading, it uses the first mirror that has the image", func(t *testing.T) {
+		var upstreamPaths, mirrorPaths []string
+		upstream := createServer(func(w http.ResponseWriter, r *http.Request) {
+			upstreamPaths = append(upstreamPaths, r.URL.Path)
+			w.Header().Set("Docker-Content-Digest", upstreamDigest)
+		})
+		defer upstream.Close()
+		missingMirror := createServer(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+		defer missingMirror.Close()
+		mirror := createServer(func(w http.ResponseWriter, r *http.Request) {
+			mirrorPaths = append(mirrorPaths, r.URL.Path)
+			w.Header().Set("Docker-Content-Digest", mirrorDigest)
+		})
+		defer mirror.Close()
+
+		configPath := writeConfig(t, fmt.Sprintf(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: RegistryConfig
+hosts:
+- host: %s
+  mirrors:
+  - %s
+  - %s/cache
+`, serverHost(t, upstream), serverHost(t, missingMirror), serverHost(t, mirror)))
+
+		subject, err := registry.NewSimpleRegistry(registry.Opts{ConfigPath: configPath})
+		require.NoError(t, err)
+
+		imgRef, err := name.ParseReference(fmt.Sprintf("%s/repo:latest", serverHost(t, upstream)))
+		require.NoError(t, err)
+		digest, err := subject.Digest(imgRef)
+		require.NoError(t, err)
+		require.Equal(t, mirrorDigest, digest.String())
+		require.Equal(t, []string{"/v2/cache/repo/manifests/latest"}, mirrorPaths)
+		require.Empty(t, upstreamPaths)
+	})
+
+	t.Run("when no mirror has the image, it falls back to the registry", func(t *testing.T) {
+		upstream := createServer(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Docker-Content-Digest", upstreamDigest)
+		})
+		defer upstream.Close()
+		missingMirror := createServer(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+		defer missingMirror.Close()
+
+		configPath := writeConfig(t, fmt.Sprintf(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: RegistryConfig
+hosts:
+- host: %s
+  mirrors:
+  - %s
+`, serverHost(t, upstream), serverHost(t, missingMirror)))
+
+		subject, err := registry.NewSimpleRegistry(registry.Opts{ConfigPath: configPath})
+		require.NoError(t, err)
+
+		imgRef, err := name.ParseReference(fmt.Sprintf("%s/repo:latest", serverHost(t, upstream)))
+		require.NoError(t, err)
+		digest, err := subject.Digest(imgRef)
+		require.NoError(t, err)
+		require.Equal(t, upstreamDigest, digest.String())
+	})
+
+	t.Run("when a rewrite matches the repository, it uses the rewritten repository", func(t *testing.T) {
+		var paths []string
+		server := createServer(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			w.Header().Set("Docker-Content-Digest", upstreamDigest)
+		})
+		defer server.Close()
+
+		configPath := writeConfig(t, fmt.Sprintf(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: RegistryConfig
+rewrites:
+- from: registry.example.com/old
+  to: %s/new
+`, serverHost(t, server)))
+
+		subject, err := registry.NewSimpleRegistry(registry.Opts{ConfigPath: configPath})
+		require.NoError(t, err)
+
+		imgRef, err := name.ParseReference("registry.example.com/old/app:1.0")
+		require.NoError(t, err)
+		digest, err := subject.Digest(imgRef)
+		require.NoError(t, err)
+		require.Equal(t, upstreamDigest, digest.String())
+		require.Equal(t, []string{"/v2/new/app/manifests/1.0"}, paths)
+	})
+
+	t.Run("when the config file is not valid, it errors", func(t *testing.T) {
+		configPath := writeConfig(t, `
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: RegistryConfig
+hosts:
+- host: docker.io/library
+`)
+
+		_, err := registry.NewSimpleRegistry(registry.Opts{ConfigPath: configPath})
+		require.ErrorContains(t, err, "Reading registry config: Validating registry config: Validating hosts[0].host")
+	})
+}
+
+func TestRegistry_HostConfig(t *testing.T) {
+	expectedDigest := "sha256:477c34d98f9e090a4441cf82d2f1f03e64c8eb730e8c1ef39a8595e685d4df65"
+	certs := newTestCertificates(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", string(types.DockerManifestSchema2))
+		w.Header().Set("Docker-Content-Digest", expectedDigest)
+		w.Write([]byte("doesn't matter"))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{certs.server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    certs.caPool,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	imgRef, err := name.ParseReference(fmt.Sprintf("%s/repo:latest", serverHost(t, server)))
+	require.NoError(t, err)
+
+	t.Run("when the client certificate is provided for the registry, it authenticates with it", func(t *testing.T) {
+		subject, err := registry.NewSimpleRegistry(registry.Opts{
+			VerifyCerts: true,
+			HostConfigs: []string{fmt.Sprintf("%s=ca-cert-path=%s,client-cert-path=%s,client-key-path=%s", serverHost(t, server), certs.caPath, certs.clientCertPath, certs.clientKeyPath)},
+		})
+		require.NoError(t, err)
+
+		digest, err := subject.Digest(imgRef)
+		require.NoError(t, err)
+		require.Equal(t, expectedDigest, digest.String())
+	})
+
+	t.Run("when the client certificate is provided for another registry, it is not sent", func(t *testing.T) {
+		subject, err := registry.NewSimpleRegistry(registry.Opts{
+			CACertPaths: []string{certs.caPath},
+			VerifyCerts: true,
+			HostConfigs: []string{fmt.Sprintf("other.registry.io=client-cert-path=%s,client-key-path=%s", certs.clientCertPath, certs.clientKeyPath)},
+		})
+		require.NoError(t, err)
+
+		_, err = subject.Digest(imgRef)
+		require.Error(t, err)
+	})
+
+	t.Run("when the client certificate cannot be loaded, it errors", func(t *testing.T) {
+		_, err := registry.NewSimpleRegistry(registry.Opts{
+			HostConfigs: []string{fmt.Sprintf("%s=client-cert-path=/does/not/exist.crt,client-key-path=%s", serverHost(t, server), certs.clientKeyPath)},
+		})
+		require.ErrorContains(t, err, "Loading client certificate from '/does/not/exist.crt'")
+	})
+}
+
+func TestRegistry_Proxy(t *testing.T) {
+	expectedDigest := "sha256:477c34d98f9e090a4441cf82d2f1f03e64c8eb730e8c1ef39a8595e685d4df65"
+
+	registryServer := createServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Content-Digest", expectedDigest)
+	})
+	defer registryServer.Close()
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"token": "some-token"}`))
+	}))
+	defer tokenServer.Close()
+
+	// proxy that answers plain HTTP requests as if it was the registry, refuses to tunnel (CONNECT) requests
+	var proxiedHosts []string
+	var proxyAuth []string
+	var tokenRealm string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		proxiedHosts = append(proxiedHosts, r.URL.Host)
+		proxyAuth = append(proxyAuth, r.Header.Get("Proxy-Authorization"))
+		switch {
+		case r.URL.Path == "/v2/" && tokenRealm != "":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s",service="registry"`, tokenRealm))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+			return
+		case r.URL.Path == "/token":
+			w.Write([]byte(`{"token": "some-token"}`))
+			return
+		}
+		w.Header().Set("Content-Type", string(types.DockerManifestSchema2))
+		w.Header().Set("Docker-Content-Digest", expectedDigest)
+		w.Write([]byte("doesn't matter"))
+	}))
+	defer proxyServer.Close()
+
+	imgRef, err := name.ParseReference(fmt.Sprintf("%s/repo:latest", serverHost(t, registryServer)))
+	require.NoError(t, err)
+
+	digest := func(t *testing.T, opts registry.Opts) {
+		proxiedHosts, proxyAuth = nil, nil
+		subject, err := registry.NewSimpleRegistry(opts)
+		require.NoError(t, err)
+
+		digest, err := subject.Digest(imgRef)
+		require.NoError(t, err)
+		require.Equal(t, expectedDigest, digest.String())
+	}
+
+	t.Run("when a proxy with credentials is configured for the registry, requests go through it", func(t *testing.T) {
+		proxyURL := fmt.Sprintf("http://user:pass@%s", serverHost(t, proxyServer))
+		digest(t, registry.Opts{
+			HostConfigs: []string{fmt.Sprintf("%s=proxy=%s", serverHost(t, registryServer), proxyURL)},
+			NoProxy:     []string{"127.0.0.1"},
+		})
+
+		require.NotEmpty(t, proxiedHosts)
+		assert.Equal(t, serverHost(t, registryServer), proxiedHosts[0])
+		assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("user:pass")), proxyAuth[0])
+	})
+
+	t.Run("when a proxy is configured for the registry, requests to its token realm go through it", func(t *testing.T) {
+		tokenRealm = fmt.Sprintf("http://%s/token", serverHost(t, tokenServer))
+		defer func() { tokenRealm = "" }()
+
+		digest(t, registry.Opts{
+			HostConfigs: []string{fmt.Sprintf("%s=proxy=http://%s", serverHost(t, registryServer), serverHost(t, proxyServer))},
+		})
+		assert.Contains(t, proxiedHosts, serverHost(t, registryServer))
+		assert.Contains(t, proxiedHosts, serverHost(t, tokenServer))
+	})
+
+	t.Run("when a proxy is configured for another registry, requests do not go through it", func(t *testing.T) {
+		digest(t, registry.Opts{
+			HostConfigs: []string{fmt.Sprintf("other.registry.io=proxy=http://%s", serverHost(t, proxyServer))},
+		})
+		assert.Empty(t, proxiedHosts)
+	})
+
+	t.Run("when a proxy is configured for all registries, requests go through it", func(t *testing.T) {
+		digest(t, registry.Opts{Proxy: fmt.Sprintf("http://%s", serverHost(t, proxyServer))})
+		assert.NotEmpty(t, proxiedHosts)
+	})
+
+	t.Run("when the registry is excluded from the proxy, requests do not go through it", func(t *testing.T) {
+		digest(t, registry.Opts{
+			Proxy:   fmt.Sprintf("http://%s", serverHost(t, proxyServer)),
+			NoProxy: []string{"10.0.0.0/8", "127.0.0.0/8"},
+		})
+		assert.Empty(t, proxiedHosts)
+	})
+
+	t.Run("when the registry is in NO_PROXY, requests do not go through the proxy configured for all registries", func(t *testing.T) {
+		digest(t, registry.Opts{
+			Proxy:       fmt.Sprintf("http://%s", serverHost(t, proxyServer)),
+			NoProxy:     []string{"10.0.0.0/8"},
+			EnvironFunc: func() []string { return []string{"NO_PROXY=localhost,127.0.0.0/8"} },
+		})
+		assert.Empty(t, proxiedHosts)
+	})
+
+	t.Run("when the proxy is not a valid URL, it errors", func(t *testing.T) {
+		_, err := registry.NewSimpleRegistry(registry.Opts{Proxy: "ftp://proxy.corp.com"})
+		require.ErrorContains(t, err, "Expected proxy 'ftp://proxy.corp.com' to use one of the schemes: http, https, socks5")
+	})
+}
+
+func TestRegistry_Cache(t *testing.T) {
+	fakeRegistry := helpers.NewFakeRegistry(t, &helpers.Logger{LogLevel: helpers.LogDebug})
+	defer fakeRegistry.CleanUp()
+	fakeRegistry.WithRandomImage("repo/image")
+	fakeRegistry.Build()
+
+	blobRequests := 0
+	fakeRegistry.WithHandlerFunc(func(_ http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			blobRequests++
+		}
+		return false
+	})
+
+	cacheDir := t.TempDir()
+	readLayers := func(t *testing.T) {
+		subject, err := registry.NewSimpleRegistry(registry.Opts{CacheDir: cacheDir, Anon: true})
+		require.NoError(t, err)
+
+		imgRef, err := name.ParseReference(fakeRegistry.ReferenceOnTestServer("repo/image"))
+		require.NoError(t, err)
+		img, err := subject.Image(imgRef)
+		require.NoError(t, err)
+		layers, err := img.Layers()
+		require.NoError(t, err)
+		for _, layer := range layers {
+			content, err := layer.Compressed()
+			require.NoError(t, err)
+			_, err = io.ReadAll(content)
+			require.NoError(t, err)
+			require.NoError(t, content.Close())
+		}
+	}
+
+	readLayers(t)
+	requestsWithoutCache := blobRequests
+	require.NotZero(t, requestsWithoutCache)
+
+	readLayers(t)
+	assert.Equal(t, requestsWithoutCache, blobRequests, "expected the layers to be read from the cache")
+}
+
+type testCertificates struct {
+	caPool         *x509.CertPool
+	caPath         string
+	server         tls.Certificate
+	clientCertPath string
+	clientKeyPath  string
+}
+
+// newTestCertificates generates a CA, a certificate for 127.0.0.1 and a client certificate signed by it
+func newTestCertificates(t *testing.T) testCertificates {
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	newCert := func(serial int64, extKeyUsage x509.ExtKeyUsage, ips []net.IP) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "test"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{extKeyUsage},
+			IPAddresses:  ips,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+
+	result := testCertificates{
+		caPool:         x509.NewCertPool(),
+		caPath:         filepath.Join(dir, "ca.pem"),
+		clientCertPath: filepath.Join(dir, "client.crt"),
+		clientKeyPath:  filepath.Join(dir, "client.key"),
+	}
+	result.caPool.AddCert(caCert)
+	require.NoError(t, os.WriteFile(result.caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600))
+
+	serverCert, serverKey := newCert(2, x509.ExtKeyUsageServerAuth, []net.IP{net.ParseIP("127.0.0.1")})
+	result.server, err = tls.X509KeyPair(serverCert, serverKey)
+	require.NoError(t, err)
+
+	clientCert, clientKey := newCert(3, x509.ExtKeyUsageClientAuth, nil)
+	require.NoError(t, os.WriteFile(result.clientCertPath, clientCert, 0600))
+	require.NoError(t, os.WriteFile(result.clientKeyPath, clientKey, 0600))
+
+	return result
+}
+
+func serverHost(t *testing.T, server *httptest.Server) string {
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	return u.Host
+}
+
 func createServer(handler func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
 	response := []byte("doesn't matter")
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,255 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultTokenExpiration used when the token server does not provide expires_in, as defined in
+	// https://distribution.github.io/distribution/spec/auth/token/#token-response-fields
+	defaultTokenExpiration = 60 * time.Second
+	// maxTokenExpirationMargin tokens are considered expired this long before their actual expiration,
+	// to ensure they are still valid while the operations that use them are running
+	maxTokenExpirationMargin = 30 * time.Second
+)
+
+// NewTokenCacheRoundTripper creates a RoundTripper that stores the responses of registry token servers in dir
+// and reuses them, across imgpkg invocations, until the tokens expire
+func NewTokenCacheRoundTripper(parent http.RoundTripper, dir string) *TokenCacheRoundTripper {
+	return &TokenCacheRoundTripper{
+		parent: parent,
+		dir:    dir,
+		now:    time.Now,
+
+		servedTokens: map[string]string{},
+	}
+}
+
+// TokenCacheRoundTripper RoundTripper that caches the tokens retrieved from the registry token servers.
+// Tokens are cached per token server, scope and credentials used to request them
+type TokenCacheRoundTripper struct {
+	parent http.RoundTripper
+	dir    string
+	now    func() time.Time
+
+	// servedTokens cache key of each token returned from the cache, used to evict the tokens rejected by the registry
+	servedTokens     map[string]string
+	servedTokensLock sync.Mutex
+}
+
+type tokenCacheEntry struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+	Body      string    `json:"body"`
+}
+
+type tokenResponse struct {
+	Token       string    `json:"token"`
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int       `json:"expires_in"`
+	IssuedAt    time.Time `json:"issued_at"`
+}
+
+// RoundTrip returns the cached token when available, otherwise calls the parent RoundTrip and caches the token
+func (t *TokenCacheRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, isTokenRequest, err := t.cacheKey(req)
+	if err != nil || !isTokenRequest {
+		return t.registryRoundTrip(req)
+	}
+
+	if body, found := t.read(key); found {
+		t.recordServedToken(key, body)
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": []string{"application/json"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := t.parent.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Failing to cache the token should not fail the request
+	_ = t.write(key, body)
+
+	return resp, nil
+}
+
+// registryRoundTrip calls the parent RoundTrip and, when the registry rejects a token that was returned
+// from the cache, i.e. it was revoked, removes it from the cache. This way the new token requested
+// when retrying the challenge is retrieved from the token server
+func (t *TokenCacheRoundTripper) registryRoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.parent.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	t.servedTokensLock.Lock()
+	key, found := t.servedTokens[token]
+	delete(t.servedTokens, token)
+	t.servedTokensLock.Unlock()
+
+	if found {
+		os.Remove(filepath.Join(t.dir, key+".json"))
+	}
+	return resp, nil
+}
+
+func (t *TokenCacheRoundTripper) recordServedToken(key string, body string) {
+	var token tokenResponse
+	if err := json.Unmarshal([]byte(body), &token); err != nil {
+		return
+	}
+
+	t.servedTokensLock.Lock()
+	defer t.servedTokensLock.Unlock()
+	for _, value := range []string{token.Token, token.AccessToken} {
+		if value != "" {
+			t.servedTokens[value] = key
+		}
+	}
+}
+
+// cacheKey identifies token requests, the ones that include the service parameter, and
+// returns a key based on the token server, the requested scopes and the credentials used
+func (t *TokenCacheRoundTripper) cacheKey(req *http.Request) (string, bool, error) {
+	params := req.URL.Query()
+	var body []byte
+	if req.Method == http.MethodPost && req.Body != nil {
+		var err error
+		body, err = readRequestBody(req)
+		if err != nil {
+			return "", false, err
+		}
+		params, err = url.ParseQuery(string(body))
+		if err != nil {
+			return "", false, nil
+		}
+	}
+	if !params.Has("service") || !params.Has("scope") {
+		return "", false, nil
+	}
+
+	hash := sha256.New()
+	for _, part := range []string{req.Method, req.URL.String(), req.Header.Get("Authorization"), string(body)} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)), true, nil
+}
+
+func (t *TokenCacheRoundTripper) read(key string) (string, bool) {
+	path := filepath.Join(t.dir, key+".json")
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	var entry tokenCacheEntry
+	if err := json.Unmarshal(bs, &entry); err != nil || !t.now().Before(entry.ExpiresAt) {
+		os.Remove(path)
+		return "", false
+	}
+	return entry.Body, true
+}
+
+func (t *TokenCacheRoundTripper) write(key string, body []byte) error {
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return err
+	}
+	if token.Token == "" && token.AccessToken == "" {
+		return fmt.Errorf("Expected token server response to contain a token")
+	}
+
+	issuedAt := token.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = t.now()
+	}
+	expiresIn := defaultTokenExpiration
+	if token.ExpiresIn > 0 {
+		expiresIn = time.Duration(token.ExpiresIn) * time.Second
+	}
+	margin := expiresIn / 10
+	if margin > maxTokenExpirationMargin {
+		margin = maxTokenExpirationMargin
+	}
+	entry := tokenCacheEntry{ExpiresAt: issuedAt.Add(expiresIn - margin), Body: string(body)}
+	if !t.now().Before(entry.ExpiresAt) {
+		return nil
+	}
+
+	bs, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		return err
+	}
+	// Write to a temporary file and rename it so that concurrent invocations never read a partial entry
+	tmpFile, err := os.CreateTemp(t.dir, key+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(bs)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filepath.Join(t.dir, key+".json"))
+}
+
+// readRequestBody returns the body of the request without consuming it
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package registry_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenCacheRoundTripper(t *testing.T) {
+	expectedDigest := "sha256:477c34d98f9e090a4441cf82d2f1f03e64c8eb730e8c1ef39a8595e685d4df65"
+
+	// registry that requires a bearer token issued by its own token server
+	newTokenServer := func(tokenResponse func() string) (*httptest.Server, *int) {
+		tokenRequests := 0
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/token":
+				tokenRequests++
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tokenResponse()))
+			case r.Header.Get("Authorization") != "Bearer some-token":
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+			case r.URL.Path == "/v2/":
+				w.WriteHeader(http.StatusOK)
+			default:
+				w.Header().Set("Content-Type", string(types.DockerManifestSchema2))
+				w.Header().Set("Docker-Content-Digest", expectedDigest)
+				w.Write([]byte("doesn't matter"))
+			}
+		}))
+		return server, &tokenRequests
+	}
+
+	digestWithNewRegistry := func(t *testing.T, server *httptest.Server, cacheDir string) {
+		subject, err := registry.NewSimpleRegistry(registry.Opts{TokenCacheDir: cacheDir, Anon: true})
+		require.NoError(t, err)
+
+		imgRef, err := name.ParseReference(fmt.Sprintf("%s/repo:latest", serverHost(t, server)))
+		require.NoError(t, err)
+		digest, err := subject.Digest(imgRef)
+		require.NoError(t, err)
+		require.Equal(t, expectedDigest, digest.String())
+	}
+
+	t.Run("when the token is still valid, it is reused across registries", func(t *testing.T) {
+		server, tokenRequests := newTokenServer(func() string {
+			return fmt.Sprintf(`{"token": "some-token", "expires_in": 300, "issued_at": "%s"}`, time.Now().UTC().Format(time.RFC3339))
+		})
+		defer server.Close()
+		cacheDir := filepath.Join(t.TempDir(), "tokens")
+
+		digestWithNewRegistry(t, server, cacheDir)
+		digestWithNewRegistry(t, server, cacheDir)
+		assert.Equal(t, 1, *tokenRequests)
+
+		entries, err := os.ReadDir(cacheDir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		info, err := entries[0].Info()
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		dirInfo, err := os.Stat(cacheDir)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0700), dirInfo.Mode().Perm())
+	})
+
+	t.Run("when the token expired, it requests a new one", func(t *testing.T) {
+		server, tokenRequests := newTokenServer(func() string {
+			return fmt.Sprintf(`{"token": "some-token", "expires_in": 60, "issued_at": "%s"}`, time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+		})
+		defer server.Close()
+		cacheDir := t.TempDir()
+
+		digestWithNewRegistry(t, server, cacheDir)
+		digestWithNewRegistry(t, server, cacheDir)
+		assert.Equal(t, 2, *tokenRequests)
+	})
+
+	t.Run("when the registry rejects a cached token, it requests a new one once", func(t *testing.T) {
+		issuedTokens := 0
+		acceptedToken := "token-1"
+		tokenRequests := 0
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/token":
+				tokenRequests++
+				issuedTokens++
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(fmt.Sprintf(`{"token": "token-%d", "expires_in": 300}`, issuedTokens)))
+			case r.Header.Get("Authorization") != "Bearer "+acceptedToken:
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+			case r.URL.Path == "/v2/":
+				w.WriteHeader(http.StatusOK)
+			default:
+				w.Header().Set("Content-Type", string(types.DockerManifestSchema2))
+				w.Header().Set("Docker-Content-Digest", expectedDigest)
+				w.Write([]byte("doesn't matter"))
+			}
+		}))
+		defer server.Close()
+		cacheDir := t.TempDir()
+
+		digestWithNewRegistry(t, server, cacheDir)
+		require.Equal(t, 1, tokenRequests)
+
+		// the cached token is revoked
+		acceptedToken = "token-2"
+		digestWithNewRegistry(t, server, cacheDir)
+		assert.Equal(t, 2, tokenRequests)
+
+		digestWithNewRegistry(t, server, cacheDir)
+		assert.Equal(t, 2, tokenRequests)
+	})
+
+	t.Run("when the cache is not enabled, it requests a token for each registry", func(t *testing.T) {
+		server, tokenRequests := newTokenServer(func() string {
+			return `{"token": "some-token", "expires_in": 300}`
+		})
+		defer server.Close()
+
+		digestWithNewRegistry(t, server, "")
+		digestWithNewRegistry(t, server, "")
+		assert.Equal(t, 2, *tokenRequests)
+	})
+}
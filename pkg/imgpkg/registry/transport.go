@@ -89,7 +89,7 @@ func (r *MultiRoundTripperStorage) CreateRoundTripper(reg regname.Registry, auth
 	r.readWriteAccess.Lock()
 	defer r.readWriteAccess.Unlock()
 
-	rt, err := transport.NewWithContext(context.Background(), reg, auth, r.baseRoundTripper, []string{scope})
+	rt, err := transport.NewWithContext(context.Background(), reg, auth, newRegistryRoundTripper(r.baseRoundTripper, reg), []string{scope})
 	if err != nil {
 		return nil, fmt.Errorf("Unable to create round tripper: %s", err)
 	}
@@ -142,7 +142,7 @@ func (r *SingleTripperStorage) CreateRoundTripper(reg regname.Registry, auth aut
 	r.readWriteAccess.Lock()
 	defer r.readWriteAccess.Unlock()
 
-	rt, err := transport.NewWithContext(context.Background(), reg, auth, r.baseRoundTripper, []string{scope})
+	rt, err := transport.NewWithContext(context.Background(), reg, auth, newRegistryRoundTripper(r.baseRoundTripper, reg), []string{scope})
 	if err != nil {
 		return nil, fmt.Errorf("Unable to create round tripper: %s", err)
 	}
@@ -189,3 +189,26 @@ func (i *ImgpkgRoundTripper) RoundTrip(req *http.Request) (*http.Response, error
 	req.Header.Add("imgpkg-session-id", i.sessionID)
 	return i.parent.RoundTrip(req)
 }
+
+// NewHostsRoundTripper creates a RoundTripper that sends the requests to the RoundTripper configured
+// for the request host, or to defaultRoundTripper when the host has none
+func NewHostsRoundTripper(defaultRoundTripper http.RoundTripper, hosts map[string]http.RoundTripper) *HostsRoundTripper {
+	return &HostsRoundTripper{
+		defaultRoundTripper: defaultRoundTripper,
+		hosts:               hosts,
+	}
+}
+
+// HostsRoundTripper RoundTripper that selects the RoundTripper to use based on the request host
+type HostsRoundTripper struct {
+	defaultRoundTripper http.RoundTripper
+	hosts               map[string]http.RoundTripper
+}
+
+// RoundTrip sends the request using the RoundTripper of the request host
+func (h *HostsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt, ok := h.hosts[req.URL.Host]; ok {
+		return rt.RoundTrip(req)
+	}
+	return h.defaultRoundTripper.RoundTrip(req)
+}
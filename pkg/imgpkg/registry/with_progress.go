@@ -44,6 +44,22 @@ func (w *WithProgress) Image(reference regname.Reference) (regv1.Image, error) {
 	return w.delegate.Image(reference)
 }
 
+// CacheImage wraps img with the blob cache of the delegate, when it has one
+func (w *WithProgress) CacheImage(img regv1.Image) regv1.Image {
+	if blobCache, ok := w.delegate.(BlobCache); ok {
+		return blobCache.CacheImage(img)
+	}
+	return img
+}
+
+// CacheIndex wraps idx with the blob cache of the delegate, when it has one
+func (w *WithProgress) CacheIndex(idx regv1.ImageIndex) regv1.ImageIndex {
+	if blobCache, ok := w.delegate.(BlobCache); ok {
+		return blobCache.CacheIndex(idx)
+	}
+	return idx
+}
+
 // FirstImageExists Returns the first of the provided Image Digests that exists in the Registry
 func (w *WithProgress) FirstImageExists(digests []string) (string, error) {
 	return w.delegate.FirstImageExists(digests)
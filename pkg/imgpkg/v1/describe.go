@@ -13,10 +13,9 @@ import (
 	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
 	"carvel.dev/imgpkg/pkg/imgpkg/registry"
 	"carvel.dev/imgpkg/pkg/imgpkg/signature"
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	regname "github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 // Author information from a Bundle
@@ -39,10 +38,22 @@ type Metadata struct {
 
 // Layers image layers info
 type Layers struct {
-	Digest string `json:"digest,omitempty"`
+	Digest           string `json:"digest,omitempty"`
+	Size             int64  `json:"size,omitempty"`
+	MediaType        string `json:"mediaType,omitempty"`
+	NonDistributable bool   `json:"nonDistributable,omitempty"`
 }
 
-// ImageInfo URLs where the image can be found as well as annotations provided in the Images Lock
+// Platform operating system and architecture of an image
+type Platform struct {
+	OS           string `json:"os,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// ImageInfo URLs where the image can be found as well as annotations provided in the Images Lock.
+// When an image is described directly, Annotations contains the annotations of its manifest and
+// the manifest details (MediaType, Platform, Labels, Manifests and Signatures) are also provided
 type ImageInfo struct {
 	Image       string            `json:"image,omitempty"`
 	Origin      string            `json:"origin,omitempty"`
@@ -50,6 +61,12 @@ type ImageInfo struct {
 	ImageType   bundle.ImageType  `json:"imageType"`
 	Error       string            `json:"error,omitempty"`
 	Layers      []Layers          `json:"layers,omitempty"`
+	MediaType   string            `json:"mediaType,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	// Manifests images present in an index
+	Manifests  []ImageInfo `json:"manifests,omitempty"`
+	Signatures []ImageInfo `json:"signatures,omitempty"`
 }
 
 // Content Contents present in a Bundle
@@ -68,12 +85,52 @@ type Description struct {
 	Layers      []Layers          `json:"layers,omitempty"`
 }
 
+const (
+	// DescriptionAPIVersion version of the schema of DescriptionDocument
+	DescriptionAPIVersion = "imgpkg.carvel.dev/v1alpha1"
+	// DescriptionKind kind of DescriptionDocument
+	DescriptionKind = "BundleDescription"
+)
+
+// DescriptionDocument Description with apiVersion and kind, used as the machine-readable output of describe.
+// Fields are only added to the schema of an apiVersion, they are never removed or renamed
+//
+//	apiVersion: imgpkg.carvel.dev/v1alpha1
+//	kind: BundleDescription
+//	image: registry.io/bundle@sha256:...    # location of the bundle
+//	origin: registry.io/bundle@sha256:...   # location in the ImagesLock of the parent bundle
+//	annotations: {}                         # annotations in the ImagesLock of the parent bundle
+//	metadata: {metadata: {}, authors: [], websites: []}
+//	layers: [{digest: sha256:..., size: 123}]  # size only when requested with LayerSizes
+//	content:
+//	  bundles: {sha256:...: <nested bundle, same fields as the root without apiVersion and kind>}
+//	  images: {sha256:...: {image, origin, annotations, imageType, error, layers}}
+type DescriptionDocument struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Description
+}
+
+// NewDescriptionDocument wraps description in a DescriptionDocument with the current apiVersion
+func NewDescriptionDocument(description Description) DescriptionDocument {
+	return DescriptionDocument{
+		APIVersion:  DescriptionAPIVersion,
+		Kind:        DescriptionKind,
+		Description: description,
+	}
+}
+
 // DescribeOpts Options used when calling the Describe function
 type DescribeOpts struct {
 	Logger                 bundle.Logger
 	Concurrency            int
 	IncludeCosignArtifacts bool
 	Layers                 bool
+	// IndexLayers when Layers is set, retrieve the layers of every image in an index instead of only the layers
+	// of the image that matches the default platform
+	IndexLayers bool
+	// LayerSizes when Layers is set, also retrieve the size of each layer
+	LayerSizes bool
 }
 
 // SignatureFetcher Interface to retrieve signatures associated with Images
@@ -118,6 +175,10 @@ func DescribeWithRegistryAndSignatureFetcher(bundleImage string, opts DescribeOp
 
 	topBundle := refWithDescription{
 		imgRef: bundle.NewBundleImageRef(lockconfig.ImageRef{Image: newBundle.DigestRef()}),
+		reg:    reg,
+
+		indexLayers: opts.IndexLayers,
+		layerSizes:  opts.LayerSizes,
 	}
 	return topBundle.DescribeBundle(allBundles, opts.Layers)
 }
@@ -125,6 +186,10 @@ func DescribeWithRegistryAndSignatureFetcher(bundleImage string, opts DescribeOp
 type refWithDescription struct {
 	imgRef bundle.ImageRef
 	bundle Description
+	reg    bundle.ImagesMetadata
+
+	indexLayers bool
+	layerSizes  bool
 }
 
 func (r *refWithDescription) DescribeBundle(bundles []*bundle.Bundle, layers bool) (Description, error) {
@@ -143,7 +208,7 @@ func (r *refWithDescription) describeBundleRec(visitedImgs map[string]refWithDes
 	}
 
 	if showLayers {
-		layers, err = getImageLayersInfo(currentBundle.PrimaryLocation())
+		layers, err = getImageLayersInfo(r.reg, currentBundle.PrimaryLocation(), r.indexLayers, r.layerSizes)
 		if err != nil {
 			return desc.bundle, err
 		}
@@ -202,7 +267,7 @@ func (r *refWithDescription) describeBundleRec(visitedImgs map[string]refWithDes
 					return desc.bundle, fmt.Errorf("Internal inconsistency: image %s should be fully resolved", ref.Image)
 				}
 				if showLayers {
-					layers, err = getImageLayersInfo(ref.PrimaryLocation())
+					layers, err = getImageLayersInfo(r.reg, ref.PrimaryLocation(), r.indexLayers, r.layerSizes)
 					if err != nil {
 						return desc.bundle, err
 					}
@@ -226,29 +291,96 @@ func (r *refWithDescription) describeBundleRec(visitedImgs map[string]refWithDes
 	return desc.bundle, nil
 }
 
-func getImageLayersInfo(image string) ([]Layers, error) {
-	layers := []Layers{}
+// getImageLayersInfo retrieves the layers of image, when image is an index and indexLayers is set
+// the layers of every image in the index are returned. The size of the layers is only set when sizes is set
+func getImageLayersInfo(reg bundle.ImagesMetadata, image string, indexLayers bool, sizes bool) ([]Layers, error) {
 	parsedImgRef, err := regname.ParseReference(image, regname.WeakValidation)
 	if err != nil {
 		return nil, fmt.Errorf("Error: %s in parsing image %s", err.Error(), image)
 	}
 
-	v1Img, err := remote.Image(parsedImgRef, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	imgDescriptor, err := reg.Get(parsedImgRef)
 	if err != nil {
 		return nil, fmt.Errorf("Error: %s in getting remote access of image %s", err.Error(), image)
 	}
 
-	imgLayers, err := v1Img.Layers()
-	if err != nil {
-		return nil, fmt.Errorf("Error: %s in getting layers of image %s", err.Error(), image)
+	var v1Imgs []regv1.Image
+	if indexLayers && imgDescriptor.MediaType.IsIndex() {
+		imgIndex, err := imgDescriptor.ImageIndex()
+		if err != nil {
+			return nil, fmt.Errorf("Error: %s in getting remote access of image %s", err.Error(), image)
+		}
+		v1Imgs, err = imagesInIndex(imgIndex)
+		if err != nil {
+			return nil, fmt.Errorf("Error: %s in getting images of index %s", err.Error(), image)
+		}
+	} else {
+		v1Img, err := imgDescriptor.Image()
+		if err != nil {
+			return nil, fmt.Errorf("Error: %s in getting remote access of image %s", err.Error(), image)
+		}
+		v1Imgs = append(v1Imgs, v1Img)
 	}
 
-	for _, imgLayer := range imgLayers {
-		digHash, err := imgLayer.Digest()
+	layers := []Layers{}
+	seenLayers := map[string]struct{}{}
+	for _, v1Img := range v1Imgs {
+		imgLayers, err := v1Img.Layers()
 		if err != nil {
-			return nil, fmt.Errorf("Error: %s in getting digest of layer's of image %s", err.Error(), image)
+			return nil, fmt.Errorf("Error: %s in getting layers of image %s", err.Error(), image)
+		}
+
+		for _, imgLayer := range imgLayers {
+			digHash, err := imgLayer.Digest()
+			if err != nil {
+				return nil, fmt.Errorf("Error: %s in getting digest of layer's of image %s", err.Error(), image)
+			}
+			if _, found := seenLayers[digHash.String()]; found {
+				continue
+			}
+			seenLayers[digHash.String()] = struct{}{}
+
+			layer := Layers{Digest: digHash.String()}
+			if sizes {
+				layer.Size, err = imgLayer.Size()
+				if err != nil {
+					return nil, fmt.Errorf("Error: %s in getting size of layer's of image %s", err.Error(), image)
+				}
+			}
+			layers = append(layers, layer)
 		}
-		layers = append(layers, Layers{Digest: digHash.String()})
 	}
 	return layers, nil
 }
+
+func imagesInIndex(imgIndex regv1.ImageIndex) ([]regv1.Image, error) {
+	indexManifest, err := imgIndex.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var imgs []regv1.Image
+	for _, manifest := range indexManifest.Manifests {
+		if manifest.MediaType.IsIndex() {
+			nestedIndex, err := imgIndex.ImageIndex(manifest.Digest)
+			if err != nil {
+				return nil, err
+			}
+			nestedImgs, err := imagesInIndex(nestedIndex)
+			if err != nil {
+				return nil, err
+			}
+			imgs = append(imgs, nestedImgs...)
+			continue
+		}
+		if !manifest.MediaType.IsImage() {
+			continue
+		}
+		img, err := imgIndex.Image(manifest.Digest)
+		if err != nil {
+			return nil, err
+		}
+		imgs = append(imgs, img)
+	}
+	return imgs, nil
+}
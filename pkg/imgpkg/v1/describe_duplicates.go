@@ -0,0 +1,55 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1
+
+import (
+	"sort"
+)
+
+// DuplicatedBundle nested bundle that can be reached through more than one path from the root bundle
+// (diamond dependency), possibly at different depths
+type DuplicatedBundle struct {
+	Image string `json:"image"`
+	// Paths every list of bundles, starting with the root bundle, that lead to this bundle
+	Paths [][]string `json:"paths"`
+}
+
+// FindDuplicatedBundles returns the nested bundles of description that are referenced by more than one bundle
+func FindDuplicatedBundles(description Description) []DuplicatedBundle {
+	paths := map[string][][]string{}
+	images := map[string]string{}
+	collectBundlePaths(description, []string{description.Image}, paths, images)
+
+	var duplicated []DuplicatedBundle
+	for id, bundlePaths := range paths {
+		if len(bundlePaths) > 1 {
+			duplicated = append(duplicated, DuplicatedBundle{Image: images[id], Paths: bundlePaths})
+		}
+	}
+	sort.Slice(duplicated, func(i, j int) bool {
+		return duplicated[i].Image < duplicated[j].Image
+	})
+	return duplicated
+}
+
+func collectBundlePaths(description Description, path []string, paths map[string][][]string, images map[string]string) {
+	var keys []string
+	for key := range description.Content.Bundles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		nestedBundle := description.Content.Bundles[key]
+		nestedPath := append(append([]string{}, path...), nestedBundle.Image)
+
+		id := graphNodeID(nestedBundle.Image)
+		if _, found := images[id]; !found {
+			images[id] = nestedBundle.Image
+		}
+		paths[id] = append(paths[id], nestedPath)
+
+		collectBundlePaths(nestedBundle, nestedPath, paths, images)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1
+
+import (
+	"sort"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	regname "github.com/google/go-containerregistry/pkg/name"
+)
+
+// DescriptionGraph Bundles and images of a Description as a directed acyclic graph, where images
+// referenced by multiple bundles are represented by a single node
+type DescriptionGraph struct {
+	Root  string                 `json:"root"`
+	Nodes []DescriptionGraphNode `json:"nodes"`
+	Edges []DescriptionGraphEdge `json:"edges"`
+}
+
+// DescriptionGraphNode Bundle or image in the graph, identified by its digest
+type DescriptionGraphNode struct {
+	ID          string            `json:"id"`
+	Image       string            `json:"image,omitempty"`
+	Origin      string            `json:"origin,omitempty"`
+	ImageType   bundle.ImageType  `json:"imageType"`
+	Size        int64             `json:"size,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// DescriptionGraphEdge Reference from a bundle to one of its images or nested bundles
+type DescriptionGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// NewDescriptionGraph creates the graph of the bundle described in description.
+// The size of the nodes is only available when the description includes the layers
+func NewDescriptionGraph(description Description) DescriptionGraph {
+	builder := descriptionGraphBuilder{
+		nodes: map[string]DescriptionGraphNode{},
+		edges: map[DescriptionGraphEdge]struct{}{},
+	}
+	root := builder.addBundle(description)
+
+	graph := DescriptionGraph{Root: root}
+	for _, node := range builder.nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		// root bundle is always the first node
+		if graph.Nodes[i].ID == root || graph.Nodes[j].ID == root {
+			return graph.Nodes[i].ID == root
+		}
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+
+	for edge := range builder.edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+
+	return graph
+}
+
+// Node returns the node with the provided ID
+func (g DescriptionGraph) Node(id string) (DescriptionGraphNode, bool) {
+	for _, node := range g.Nodes {
+		if node.ID == id {
+			return node, true
+		}
+	}
+	return DescriptionGraphNode{}, false
+}
+
+type descriptionGraphBuilder struct {
+	nodes map[string]DescriptionGraphNode
+	edges map[DescriptionGraphEdge]struct{}
+}
+
+func (b descriptionGraphBuilder) addBundle(description Description) string {
+	id := graphNodeID(description.Image)
+	if _, found := b.nodes[id]; found {
+		return id
+	}
+
+	b.nodes[id] = DescriptionGraphNode{
+		ID:          id,
+		Image:       description.Image,
+		Origin:      description.Origin,
+		ImageType:   bundle.BundleImage,
+		Size:        layersSize(description.Layers),
+		Annotations: description.Annotations,
+	}
+
+	for _, nestedBundle := range description.Content.Bundles {
+		b.edges[DescriptionGraphEdge{From: id, To: b.addBundle(nestedBundle)}] = struct{}{}
+	}
+
+	for key, image := range description.Content.Images {
+		imageID := key
+		if image.Error == "" {
+			imageID = graphNodeID(image.Image)
+		}
+		if _, found := b.nodes[imageID]; !found {
+			b.nodes[imageID] = DescriptionGraphNode{
+				ID:          imageID,
+				Image:       image.Image,
+				Origin:      image.Origin,
+				ImageType:   image.ImageType,
+				Size:        layersSize(image.Layers),
+				Annotations: image.Annotations,
+				Error:       image.Error,
+			}
+		}
+		b.edges[DescriptionGraphEdge{From: id, To: imageID}] = struct{}{}
+	}
+
+	return id
+}
+
+// graphNodeID uses the digest as the ID so the same image in different repositories is a single node
+func graphNodeID(image string) string {
+	digest, err := regname.NewDigest(image)
+	if err != nil {
+		return image
+	}
+	return digest.DigestStr()
+}
+
+func layersSize(layers []Layers) int64 {
+	var size int64
+	for _, layer := range layers {
+		size += layer.Size
+	}
+	return size
+}
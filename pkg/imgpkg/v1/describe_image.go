@@ -0,0 +1,198 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1
+
+import (
+	"fmt"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	"carvel.dev/imgpkg/pkg/imgpkg/signature"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const (
+	// ImageDescriptionKind kind of ImageDescriptionDocument
+	ImageDescriptionKind = "ImageDescription"
+)
+
+// ImageDescriptionDocument ImageInfo with apiVersion and kind, used as the machine-readable output of describe for images.
+// It shares the DescriptionAPIVersion and its versioning rules with DescriptionDocument
+type ImageDescriptionDocument struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	ImageInfo
+}
+
+// NewImageDescriptionDocument wraps info in an ImageDescriptionDocument with the current apiVersion
+func NewImageDescriptionDocument(info ImageInfo) ImageDescriptionDocument {
+	return ImageDescriptionDocument{
+		APIVersion: DescriptionAPIVersion,
+		Kind:       ImageDescriptionKind,
+		ImageInfo:  info,
+	}
+}
+
+// DescribeImage Given an image or index URL fetch the information about its manifest, layers and signatures
+func DescribeImage(image string, opts DescribeOpts, registryOpts registry.Opts) (ImageInfo, error) {
+	reg, err := registry.NewSimpleRegistry(registryOpts)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+
+	var signatureRetriever SignatureFetcher
+	if !opts.IncludeCosignArtifacts {
+		signatureRetriever = signature.NewNoop()
+	} else {
+		signatureRetriever = signature.NewSignatures(signature.NewCosign(reg), opts.Concurrency)
+	}
+
+	return DescribeImageWithRegistryAndSignatureFetcher(image, opts, reg, signatureRetriever)
+}
+
+// DescribeImageWithRegistryAndSignatureFetcher Given an image or index URL fetch the information about its manifest, layers and signatures
+func DescribeImageWithRegistryAndSignatureFetcher(image string, opts DescribeOpts, reg bundle.ImagesMetadata, sigFetcher SignatureFetcher) (ImageInfo, error) {
+	ref, err := regname.ParseReference(image, regname.WeakValidation)
+	if err != nil {
+		return ImageInfo{}, fmt.Errorf("Parsing image '%s': %s", image, err)
+	}
+
+	imgDescriptor, err := reg.Get(ref)
+	if err != nil {
+		return ImageInfo{}, fmt.Errorf("Fetching image '%s': %s", image, err)
+	}
+
+	digestRef := ref.Context().Digest(imgDescriptor.Digest.String())
+	var info ImageInfo
+	if imgDescriptor.MediaType.IsIndex() {
+		imgIndex, err := imgDescriptor.ImageIndex()
+		if err != nil {
+			return ImageInfo{}, fmt.Errorf("Fetching index '%s': %s", image, err)
+		}
+		info, err = describeIndex(digestRef, imgIndex, opts.Layers)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+	} else {
+		img, err := imgDescriptor.Image()
+		if err != nil {
+			return ImageInfo{}, fmt.Errorf("Fetching image '%s': %s", image, err)
+		}
+		info, err = describeImage(digestRef, img, opts.Layers)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+	}
+	info.Origin = image
+
+	signatures, err := sigFetcher.FetchForImageRefs([]lockconfig.ImageRef{{Image: digestRef.Name()}})
+	if err != nil {
+		fetchErr, ok := err.(*signature.FetchError)
+		if !ok {
+			return ImageInfo{}, err
+		}
+		for _, sigErr := range fetchErr.AllErrors {
+			info.Signatures = append(info.Signatures, ImageInfo{Image: sigErr.ImageRef(), ImageType: bundle.SignatureImage, Error: sigErr.Error()})
+		}
+	}
+	for _, sig := range signatures {
+		info.Signatures = append(info.Signatures, ImageInfo{Image: sig.Image, ImageType: bundle.SignatureImage, Annotations: sig.Annotations})
+	}
+
+	return info, nil
+}
+
+func describeIndex(digestRef regname.Digest, imgIndex regv1.ImageIndex, showLayers bool) (ImageInfo, error) {
+	indexManifest, err := imgIndex.IndexManifest()
+	if err != nil {
+		return ImageInfo{}, fmt.Errorf("Reading manifest of index '%s': %s", digestRef.Name(), err)
+	}
+
+	info := ImageInfo{
+		Image:       digestRef.Name(),
+		ImageType:   bundle.ContentImage,
+		MediaType:   string(indexManifest.MediaType),
+		Annotations: indexManifest.Annotations,
+	}
+
+	for _, manifest := range indexManifest.Manifests {
+		childRef := digestRef.Context().Digest(manifest.Digest.String())
+
+		var childInfo ImageInfo
+		switch {
+		case manifest.MediaType.IsIndex():
+			childIndex, err := imgIndex.ImageIndex(manifest.Digest)
+			if err != nil {
+				return ImageInfo{}, fmt.Errorf("Fetching index '%s': %s", childRef.Name(), err)
+			}
+			childInfo, err = describeIndex(childRef, childIndex, showLayers)
+			if err != nil {
+				return ImageInfo{}, err
+			}
+		case manifest.MediaType.IsImage():
+			childImg, err := imgIndex.Image(manifest.Digest)
+			if err != nil {
+				return ImageInfo{}, fmt.Errorf("Fetching image '%s': %s", childRef.Name(), err)
+			}
+			childInfo, err = describeImage(childRef, childImg, showLayers)
+			if err != nil {
+				return ImageInfo{}, err
+			}
+		default:
+			childInfo = ImageInfo{Image: childRef.Name(), ImageType: bundle.ContentImage, MediaType: string(manifest.MediaType)}
+		}
+
+		if manifest.Platform != nil {
+			childInfo.Platform = &Platform{OS: manifest.Platform.OS, Architecture: manifest.Platform.Architecture, Variant: manifest.Platform.Variant}
+		}
+		info.Manifests = append(info.Manifests, childInfo)
+	}
+
+	return info, nil
+}
+
+func describeImage(digestRef regname.Digest, img regv1.Image, showLayers bool) (ImageInfo, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return ImageInfo{}, fmt.Errorf("Reading manifest of image '%s': %s", digestRef.Name(), err)
+	}
+
+	info := ImageInfo{
+		Image:       digestRef.Name(),
+		ImageType:   bundle.ContentImage,
+		MediaType:   string(manifest.MediaType),
+		Annotations: manifest.Annotations,
+	}
+
+	// config of non-container artifacts, i.e. helm charts, cannot be parsed as a ConfigFile
+	if manifest.Config.MediaType.IsConfig() {
+		configFile, err := img.ConfigFile()
+		if err != nil {
+			return ImageInfo{}, fmt.Errorf("Reading config of image '%s': %s", digestRef.Name(), err)
+		}
+		info.Labels = configFile.Config.Labels
+		if _, isBundle := configFile.Config.Labels[bundle.BundleConfigLabel]; isBundle {
+			info.ImageType = bundle.BundleImage
+		}
+		if configFile.OS != "" || configFile.Architecture != "" {
+			info.Platform = &Platform{OS: configFile.OS, Architecture: configFile.Architecture, Variant: configFile.Variant}
+		}
+	}
+
+	if showLayers {
+		info.Layers = []Layers{}
+		for _, layer := range manifest.Layers {
+			info.Layers = append(info.Layers, Layers{
+				Digest:           layer.Digest.String(),
+				Size:             layer.Size,
+				MediaType:        string(layer.MediaType),
+				NonDistributable: !layer.MediaType.IsDistributable(),
+			})
+		}
+	}
+
+	return info, nil
+}
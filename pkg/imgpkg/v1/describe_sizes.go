@@ -0,0 +1,93 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1
+
+import (
+	"fmt"
+	"sort"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+)
+
+// ImageSize compressed size of the layers of a bundle or image
+type ImageSize struct {
+	Image     string           `json:"image"`
+	ImageType bundle.ImageType `json:"imageType"`
+	Size      int64            `json:"size"`
+}
+
+// Sizes compressed sizes of the bundle, its nested bundles and images
+type Sizes struct {
+	// Images size of each bundle and image, images referenced by multiple bundles are only present once
+	Images []ImageSize `json:"images"`
+	// TotalSize sum of the size of every image
+	TotalSize int64 `json:"totalSize"`
+	// UniqueSize sum of the size of every layer, where layers shared between images are only counted once.
+	// This is the amount of data that needs to be transferred to copy the bundle
+	UniqueSize int64 `json:"uniqueSize"`
+}
+
+// CalculateSizes computes the sizes of the bundle described in description.
+// The description needs to be retrieved with the Layers, IndexLayers and LayerSizes options enabled
+func CalculateSizes(description Description) (Sizes, error) {
+	if len(description.Layers) == 0 {
+		return Sizes{}, fmt.Errorf("Expected bundle description to include layers information")
+	}
+
+	calculator := sizesCalculator{
+		images: map[string]ImageSize{},
+		layers: map[string]int64{},
+	}
+	calculator.addBundle(description)
+
+	sizes := Sizes{}
+	for _, image := range calculator.images {
+		sizes.Images = append(sizes.Images, image)
+		sizes.TotalSize += image.Size
+	}
+	sort.Slice(sizes.Images, func(i, j int) bool {
+		if sizes.Images[i].Size != sizes.Images[j].Size {
+			return sizes.Images[i].Size > sizes.Images[j].Size
+		}
+		return sizes.Images[i].Image < sizes.Images[j].Image
+	})
+
+	for _, size := range calculator.layers {
+		sizes.UniqueSize += size
+	}
+
+	return sizes, nil
+}
+
+type sizesCalculator struct {
+	images map[string]ImageSize
+	layers map[string]int64
+}
+
+func (c sizesCalculator) addBundle(description Description) {
+	if c.add(description.Image, bundle.BundleImage, description.Layers) {
+		for _, nestedBundle := range description.Content.Bundles {
+			c.addBundle(nestedBundle)
+		}
+		for _, image := range description.Content.Images {
+			if image.Error == "" {
+				c.add(image.Image, image.ImageType, image.Layers)
+			}
+		}
+	}
+}
+
+// add records the image and its layers, returns false when the image was already recorded
+func (c sizesCalculator) add(image string, imageType bundle.ImageType, layers []Layers) bool {
+	id := graphNodeID(image)
+	if _, found := c.images[id]; found {
+		return false
+	}
+
+	c.images[id] = ImageSize{Image: image, ImageType: imageType, Size: layersSize(layers)}
+	for _, layer := range layers {
+		c.layers[layer.Digest] = layer.Size
+	}
+	return true
+}
@@ -4,6 +4,7 @@
 package v1_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -18,6 +19,7 @@ import (
 	"carvel.dev/imgpkg/test/helpers"
 	"github.com/google/go-containerregistry/pkg/name"
 	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	regv1types "github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -571,3 +573,242 @@ func createBundleRec(t *testing.T, reg *helpers.FakeTestRegistryBuilder, bToCrea
 	result.refDigest = b.RefDigest
 	return *result
 }
+
+func TestNewDescriptionGraph(t *testing.T) {
+	digest := func(n string) string { return "sha256:" + strings.Repeat(n, 64) }
+
+	sharedImage := v1.ImageInfo{
+		Image:     "registry.io/shared@" + digest("3"),
+		ImageType: ctlbundle.ContentImage,
+		Layers:    []v1.Layers{{Digest: digest("a"), Size: 100}, {Digest: digest("b"), Size: 50}},
+	}
+	description := v1.Description{
+		Image: "registry.io/root@" + digest("1"),
+		Content: v1.Content{
+			Bundles: map[string]v1.Description{
+				digest("2"): {
+					Image:   "registry.io/nested@" + digest("2"),
+					Content: v1.Content{Images: map[string]v1.ImageInfo{digest("3"): sharedImage}},
+				},
+			},
+			Images: map[string]v1.ImageInfo{
+				digest("3"):                       sharedImage,
+				"registry.io/sig:sha256-3333.sig": {ImageType: ctlbundle.SignatureImage, Error: "access denied"},
+			},
+		},
+	}
+
+	graph := v1.NewDescriptionGraph(description)
+
+	require.Equal(t, digest("1"), graph.Root)
+	require.Len(t, graph.Nodes, 4)
+	assert.Equal(t, digest("1"), graph.Nodes[0].ID)
+
+	shared, found := graph.Node(digest("3"))
+	require.True(t, found)
+	assert.Equal(t, ctlbundle.ContentImage, shared.ImageType)
+	assert.Equal(t, int64(150), shared.Size)
+
+	signature, found := graph.Node("registry.io/sig:sha256-3333.sig")
+	require.True(t, found)
+	assert.Equal(t, "access denied", signature.Error)
+
+	assert.Equal(t, []v1.DescriptionGraphEdge{
+		{From: digest("1"), To: "registry.io/sig:sha256-3333.sig"},
+		{From: digest("1"), To: digest("2")},
+		{From: digest("1"), To: digest("3")},
+		{From: digest("2"), To: digest("3")},
+	}, graph.Edges)
+}
+
+func TestFindDuplicatedBundles(t *testing.T) {
+	digest := func(n string) string { return "sha256:" + strings.Repeat(n, 64) }
+	shared := v1.Description{Image: "registry.io/shared@" + digest("4")}
+
+	description := v1.Description{
+		Image: "registry.io/root@" + digest("1"),
+		Content: v1.Content{
+			Bundles: map[string]v1.Description{
+				digest("2"): {
+					Image:   "registry.io/a@" + digest("2"),
+					Content: v1.Content{Bundles: map[string]v1.Description{digest("4"): shared}},
+				},
+				digest("3"): {Image: "registry.io/b@" + digest("3")},
+				digest("4"): shared,
+			},
+		},
+	}
+
+	duplicated := v1.FindDuplicatedBundles(description)
+	require.Equal(t, []v1.DuplicatedBundle{{
+		Image: shared.Image,
+		Paths: [][]string{
+			{description.Image, "registry.io/a@" + digest("2"), shared.Image},
+			{description.Image, shared.Image},
+		},
+	}}, duplicated)
+
+	require.Empty(t, v1.FindDuplicatedBundles(description.Content.Bundles[digest("2")]))
+}
+
+func TestDescribeSizes(t *testing.T) {
+	logger := &helpers.Logger{LogLevel: helpers.LogDebug}
+
+	t.Run("retrieves the layers of every image in an index and reports each shared image once", func(t *testing.T) {
+		fakeRegBuilder := helpers.NewFakeRegistry(t, logger)
+		defer fakeRegBuilder.CleanUp()
+
+		img := fakeRegBuilder.WithRandomImage("app/img")
+		index := fakeRegBuilder.WithARandomImageIndex("app/index", 2)
+		nestedBundle := fakeRegBuilder.WithRandomBundle("app/nested").WithImageRefs([]lockconfig.ImageRef{{Image: img.RefDigest}})
+		rootBundle := fakeRegBuilder.WithRandomBundle("app/root").WithImageRefs([]lockconfig.ImageRef{
+			{Image: nestedBundle.RefDigest}, {Image: img.RefDigest}, {Image: index.RefDigest},
+		})
+		fakeRegBuilder.Build()
+
+		description, err := v1.Describe(rootBundle.RefDigest, v1.DescribeOpts{
+			Logger:      logger,
+			Concurrency: 1,
+			Layers:      true,
+			IndexLayers: true,
+			LayerSizes:  true,
+		}, registry.Opts{EnvironFunc: os.Environ, RetryCount: 3})
+		require.NoError(t, err)
+
+		indexDigest, err := name.NewDigest(index.RefDigest)
+		require.NoError(t, err)
+		assert.Len(t, description.Content.Images[indexDigest.DigestStr()].Layers, 2)
+
+		sizes, err := v1.CalculateSizes(description)
+		require.NoError(t, err)
+		require.Len(t, sizes.Images, 4)
+
+		var expectedTotal int64
+		for _, image := range sizes.Images {
+			assert.Greater(t, image.Size, int64(0))
+			expectedTotal += image.Size
+		}
+		assert.Equal(t, expectedTotal, sizes.TotalSize)
+		assert.Equal(t, sizes.TotalSize, sizes.UniqueSize)
+	})
+
+	t.Run("when the sizes are not requested, it only reports the digest of the layers", func(t *testing.T) {
+		fakeRegBuilder := helpers.NewFakeRegistry(t, logger)
+		defer fakeRegBuilder.CleanUp()
+
+		img := fakeRegBuilder.WithRandomImage("app/img")
+		rootBundle := fakeRegBuilder.WithRandomBundle("app/root").WithImageRefs([]lockconfig.ImageRef{{Image: img.RefDigest}})
+		fakeRegBuilder.Build()
+
+		description, err := v1.Describe(rootBundle.RefDigest, v1.DescribeOpts{
+			Logger:      logger,
+			Concurrency: 1,
+			Layers:      true,
+		}, registry.Opts{EnvironFunc: os.Environ, RetryCount: 3})
+		require.NoError(t, err)
+
+		imgDigest, err := name.NewDigest(img.RefDigest)
+		require.NoError(t, err)
+		layers := append(description.Layers, description.Content.Images[imgDigest.DigestStr()].Layers...)
+		require.NotEmpty(t, layers)
+		for _, layer := range layers {
+			assert.Equal(t, v1.Layers{Digest: layer.Digest}, layer)
+		}
+	})
+
+	t.Run("counts layers shared between images once in the unique size", func(t *testing.T) {
+		sharedLayer := v1.Layers{Digest: "sha256:" + strings.Repeat("a", 64), Size: 100}
+		description := v1.Description{
+			Image:  "registry.io/root@sha256:" + strings.Repeat("1", 64),
+			Layers: []v1.Layers{{Digest: "sha256:" + strings.Repeat("b", 64), Size: 10}},
+			Content: v1.Content{Images: map[string]v1.ImageInfo{
+				"sha256:" + strings.Repeat("2", 64): {Image: "registry.io/img1@sha256:" + strings.Repeat("2", 64), ImageType: ctlbundle.ContentImage, Layers: []v1.Layers{sharedLayer}},
+				"sha256:" + strings.Repeat("3", 64): {Image: "registry.io/img2@sha256:" + strings.Repeat("3", 64), ImageType: ctlbundle.ContentImage, Layers: []v1.Layers{sharedLayer, {Digest: "sha256:" + strings.Repeat("c", 64), Size: 5}}},
+			}},
+		}
+
+		sizes, err := v1.CalculateSizes(description)
+		require.NoError(t, err)
+		assert.Equal(t, int64(215), sizes.TotalSize)
+		assert.Equal(t, int64(115), sizes.UniqueSize)
+		assert.Equal(t, "registry.io/img2@sha256:"+strings.Repeat("3", 64), sizes.Images[0].Image)
+	})
+
+	t.Run("fails when the description has no layers information", func(t *testing.T) {
+		_, err := v1.CalculateSizes(v1.Description{Image: "registry.io/root@sha256:" + strings.Repeat("1", 64)})
+		require.Error(t, err)
+	})
+}
+
+func TestNewDescriptionDocument(t *testing.T) {
+	description := v1.Description{
+		Image:  "registry.io/root@sha256:" + strings.Repeat("1", 64),
+		Origin: "registry.io/root@sha256:" + strings.Repeat("1", 64),
+	}
+
+	bs, err := json.Marshal(v1.NewDescriptionDocument(description))
+	require.NoError(t, err)
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(bs, &doc))
+	assert.Equal(t, v1.DescriptionAPIVersion, doc["apiVersion"])
+	assert.Equal(t, v1.DescriptionKind, doc["kind"])
+	assert.Equal(t, description.Image, doc["image"])
+	assert.Contains(t, doc, "content")
+}
+
+func TestDescribeImage(t *testing.T) {
+	logger := &helpers.Logger{LogLevel: helpers.LogDebug}
+	fakeRegBuilder := helpers.NewFakeRegistry(t, logger)
+	defer fakeRegBuilder.CleanUp()
+
+	img := fakeRegBuilder.WithRandomImage("app/img")
+	index := fakeRegBuilder.WithARandomImageIndex("app/index", 2)
+	imgHash, err := regv1.NewHash(img.Digest)
+	require.NoError(t, err)
+	fakeRegBuilder.WithRandomTaggedImage(img.RefDigest, cosign.Munge(regv1.Descriptor{Digest: imgHash}))
+	bundleInfo := fakeRegBuilder.WithRandomBundle("app/bundle").WithImageRefs(nil)
+	fakeRegBuilder.Build()
+
+	opts := v1.DescribeOpts{Logger: logger, Concurrency: 1, Layers: true, IncludeCosignArtifacts: true}
+	regOpts := registry.Opts{EnvironFunc: os.Environ, RetryCount: 3}
+
+	t.Run("describes the manifest, layers and signatures of an image", func(t *testing.T) {
+		info, err := v1.DescribeImage(img.RefDigest, opts, regOpts)
+		require.NoError(t, err)
+
+		assert.Equal(t, img.RefDigest, info.Image)
+		assert.Equal(t, ctlbundle.ContentImage, info.ImageType)
+		assert.NotEmpty(t, info.MediaType)
+		require.NotEmpty(t, info.Layers)
+		for _, layer := range info.Layers {
+			assert.Greater(t, layer.Size, int64(0))
+			assert.NotEmpty(t, layer.MediaType)
+			assert.False(t, layer.NonDistributable)
+		}
+		require.Len(t, info.Signatures, 1)
+		assert.Equal(t, ctlbundle.SignatureImage, info.Signatures[0].ImageType)
+	})
+
+	t.Run("describes every image of an index", func(t *testing.T) {
+		info, err := v1.DescribeImage(index.RefDigest, opts, regOpts)
+		require.NoError(t, err)
+
+		assert.Equal(t, index.RefDigest, info.Image)
+		assert.True(t, regv1types.MediaType(info.MediaType).IsIndex())
+		require.Len(t, info.Manifests, 2)
+		for _, manifest := range info.Manifests {
+			assert.True(t, regv1types.MediaType(manifest.MediaType).IsImage())
+			assert.NotEmpty(t, manifest.Layers)
+		}
+		assert.Empty(t, info.Signatures)
+	})
+
+	t.Run("identifies bundles", func(t *testing.T) {
+		info, err := v1.DescribeImage(bundleInfo.RefDigest, opts, regOpts)
+		require.NoError(t, err)
+
+		assert.Equal(t, ctlbundle.BundleImage, info.ImageType)
+		assert.Contains(t, info.Labels, ctlbundle.BundleConfigLabel)
+	})
+}
@@ -0,0 +1,518 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"carvel.dev/imgpkg/pkg/imgpkg/imagedesc"
+	"carvel.dev/imgpkg/pkg/imgpkg/imageset"
+	"carvel.dev/imgpkg/pkg/imgpkg/imagetar"
+	"carvel.dev/imgpkg/pkg/imgpkg/plainimage"
+	"carvel.dev/imgpkg/pkg/imgpkg/registry"
+	regname "github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
+	regremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// DiffOrigin location of one of the bundles being compared, either a bundle reference or a tarball created by copy
+type DiffOrigin struct {
+	BundleRef string
+	TarPath   string
+}
+
+// DiffOpts Options used when calling the Diff function
+type DiffOpts struct {
+	Logger      bundle.Logger
+	Concurrency int
+}
+
+// FilesDiff files that differ between the configuration of two bundles
+type FilesDiff struct {
+	Added    []string `json:"added,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+	Modified []string `json:"modified,omitempty"`
+}
+
+// ImageDiff image that was added, removed or changed between two bundles
+type ImageDiff struct {
+	// Key used to match the image in both bundles, the kbld.carvel.dev/id annotation when present or the image repository
+	Key       string           `json:"key"`
+	From      string           `json:"from,omitempty"`
+	To        string           `json:"to,omitempty"`
+	ImageType bundle.ImageType `json:"imageType"`
+}
+
+// ImagesDiff images in the ImagesLock that differ between two bundles
+type ImagesDiff struct {
+	Added   []ImageDiff `json:"added,omitempty"`
+	Removed []ImageDiff `json:"removed,omitempty"`
+	Changed []ImageDiff `json:"changed,omitempty"`
+}
+
+// MetadataChange field of the bundle metadata whose value changed
+type MetadataChange struct {
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// BundleDiff differences between two versions of a bundle
+type BundleDiff struct {
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	Files    FilesDiff        `json:"files"`
+	Images   ImagesDiff       `json:"images"`
+	Metadata []MetadataChange `json:"metadata,omitempty"`
+	// Bundles nested bundles, present in both versions, that changed
+	Bundles []BundleDiff `json:"bundles,omitempty"`
+}
+
+// HasChanges returns true when the two versions of the bundle are different
+func (d BundleDiff) HasChanges() bool {
+	return len(d.Files.Added) > 0 || len(d.Files.Removed) > 0 || len(d.Files.Modified) > 0 ||
+		len(d.Images.Added) > 0 || len(d.Images.Removed) > 0 || len(d.Images.Changed) > 0 ||
+		len(d.Metadata) > 0 || len(d.Bundles) > 0
+}
+
+// Diff Given two versions of a Bundle, compare the files, images and metadata of both and of their Nested Bundles
+func Diff(from, to DiffOrigin, opts DiffOpts, registryOpts registry.Opts) (BundleDiff, error) {
+	reg, err := registry.NewSimpleRegistry(registryOpts)
+	if err != nil {
+		return BundleDiff{}, err
+	}
+
+	return DiffWithRegistry(from, to, opts, reg)
+}
+
+// DiffWithRegistry Given two versions of a Bundle, compare the files, images and metadata of both and of their Nested Bundles
+func DiffWithRegistry(from, to DiffOrigin, opts DiffOpts, reg bundle.ImagesMetadata) (BundleDiff, error) {
+	fromBundle, fromBundles, err := diffBundle(from, opts, reg)
+	if err != nil {
+		return BundleDiff{}, err
+	}
+
+	toBundle, toBundles, err := diffBundle(to, opts, reg)
+	if err != nil {
+		return BundleDiff{}, err
+	}
+
+	return bundleDiffer{fromBundles: fromBundles, toBundles: toBundles}.Diff(fromBundle, toBundle)
+}
+
+// diffBundle retrieves the bundle present in origin and all its nested bundles
+func diffBundle(origin DiffOrigin, opts DiffOpts, reg bundle.ImagesMetadata) (*bundle.Bundle, []*bundle.Bundle, error) {
+	if (origin.BundleRef == "") == (origin.TarPath == "") {
+		return nil, nil, fmt.Errorf("Expected either a bundle reference or a tarball to compare")
+	}
+
+	var rootBundle *bundle.Bundle
+	if origin.TarPath != "" {
+		var err error
+		rootBundle, err = bundleFromTar(origin.TarPath)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		lockReader := bundle.NewImagesLockReader()
+		rootBundle = bundle.NewBundleFromRef(origin.BundleRef, reg, lockReader, bundle.NewRegistryFetcher(reg, lockReader))
+		isBundle, err := rootBundle.IsBundle()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Unable to check if %s is a bundle: %s", origin.BundleRef, err)
+		}
+		if !isBundle {
+			return nil, nil, fmt.Errorf("Only bundles can be compared, and %s is not a bundle", origin.BundleRef)
+		}
+	}
+
+	bundles, _, err := rootBundle.AllImagesLockRefs(opts.Concurrency, opts.Logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Retrieving Images from bundle: %s", err)
+	}
+
+	return rootBundle, bundles, nil
+}
+
+// bundleFromTar finds the root bundle in a tarball created by copy, nested bundles are also read from the tarball
+func bundleFromTar(tarPath string) (*bundle.Bundle, error) {
+	imgOrIndexes, err := imagetar.NewTarReader(tarPath).Read()
+	if err != nil {
+		return nil, fmt.Errorf("Reading tarball '%s': %s", tarPath, err)
+	}
+
+	tarImages := tarImagesMetadata{}
+	var processedImages []imageset.ProcessedImage
+	rootImageIdx := -1
+	for _, item := range imgOrIndexes {
+		processedImage := imageset.ProcessedImage{
+			UnprocessedImageRef: imageset.UnprocessedImageRef{Labels: item.Labels, OrigRef: item.OrigRef},
+		}
+
+		var digestRef string
+		if item.Image != nil {
+			digestRef = (*item.Image).Ref()
+			processedImage.Tag = (*item.Image).Tag()
+			processedImage.Image = *item.Image
+		} else {
+			digestRef = (*item.Index).Ref()
+			processedImage.Tag = (*item.Index).Tag()
+			processedImage.ImageIndex = *item.Index
+		}
+		processedImage.DigestRef = digestRef
+		processedImage.UnprocessedImageRef.DigestRef = digestRef
+
+		digest, err := regname.NewDigest(digestRef)
+		if err != nil {
+			return nil, fmt.Errorf("Parsing reference '%s' in tarball: %s", digestRef, err)
+		}
+		tarImages[digest.DigestStr()] = item
+
+		processedImages = append(processedImages, processedImage)
+		if processedImage.Image != nil && IsRootBundle(processedImage) {
+			rootImageIdx = len(processedImages) - 1
+		}
+	}
+
+	if rootImageIdx == -1 {
+		return nil, fmt.Errorf("Expected tarball '%s' to contain a bundle", tarPath)
+	}
+
+	rootImage := processedImages[rootImageIdx]
+	lockReader := bundle.NewImagesLockReader()
+	return bundle.NewBundle(
+		plainimage.NewFetchedPlainImageWithTag(rootImage.DigestRef, rootImage.Tag, rootImage.Image),
+		tarImages, lockReader, bundle.NewFetcherFromProcessedImages(processedImages, tarImages, lockReader)), nil
+}
+
+// tarImagesMetadata read only access to the images present in a tarball, images are only found by digest
+type tarImagesMetadata map[string]imagedesc.ImageOrIndex
+
+func (t tarImagesMetadata) find(ref regname.Reference) (imagedesc.ImageOrIndex, error) {
+	if digest, ok := ref.(regname.Digest); ok {
+		if item, found := t[digest.DigestStr()]; found {
+			return item, nil
+		}
+	}
+	return imagedesc.ImageOrIndex{}, &transport.Error{StatusCode: http.StatusNotFound,
+		Errors: []transport.Diagnostic{{Code: transport.ManifestUnknownErrorCode, Message: fmt.Sprintf("Image '%s' not found in tarball", ref.Name())}}}
+}
+
+// Get is not supported because the images in the tarball do not have a remote descriptor
+func (t tarImagesMetadata) Get(ref regname.Reference) (*regremote.Descriptor, error) {
+	return nil, fmt.Errorf("Retrieving descriptor of '%s' from tarball is not supported", ref.Name())
+}
+
+// Image returns the image with the digest present in ref
+func (t tarImagesMetadata) Image(ref regname.Reference) (regv1.Image, error) {
+	item, err := t.find(ref)
+	if err != nil {
+		return nil, err
+	}
+	if item.Image == nil {
+		return nil, fmt.Errorf("Expected '%s' to be an image", ref.Name())
+	}
+	return *item.Image, nil
+}
+
+// Digest returns the digest of the image with the digest present in ref
+func (t tarImagesMetadata) Digest(ref regname.Reference) (regv1.Hash, error) {
+	item, err := t.find(ref)
+	if err != nil {
+		return regv1.Hash{}, err
+	}
+	if item.Image != nil {
+		return (*item.Image).Digest()
+	}
+	return (*item.Index).Digest()
+}
+
+// FirstImageExists returns the first of the digest references present in the tarball
+func (t tarImagesMetadata) FirstImageExists(digests []string) (string, error) {
+	for _, digest := range digests {
+		ref, err := regname.NewDigest(digest)
+		if err != nil {
+			return "", err
+		}
+		if _, err := t.find(ref); err == nil {
+			return digest, nil
+		}
+	}
+	return "", fmt.Errorf("Checking image existence: none of the images were found in the tarball: %s", strings.Join(digests, ", "))
+}
+
+// bundleDiffer compares bundles and nested bundles retrieved with AllImagesLockRefs
+type bundleDiffer struct {
+	fromBundles []*bundle.Bundle
+	toBundles   []*bundle.Bundle
+}
+
+func (d bundleDiffer) Diff(from, to *bundle.Bundle) (BundleDiff, error) {
+	diff := BundleDiff{From: from.DigestRef(), To: to.DigestRef()}
+	if from.Digest() == to.Digest() {
+		return diff, nil
+	}
+
+	var err error
+	diff.Files, err = d.filesDiff(from, to)
+	if err != nil {
+		return BundleDiff{}, err
+	}
+
+	diff.Metadata, err = d.metadataDiff(from, to)
+	if err != nil {
+		return BundleDiff{}, err
+	}
+
+	var changedBundles []ImageDiff
+	diff.Images, changedBundles = d.imagesDiff(from.ImagesRefsWithErrors(), to.ImagesRefsWithErrors())
+
+	for _, changedBundle := range changedBundles {
+		fromNested, err := d.findBundle(d.fromBundles, changedBundle.From)
+		if err != nil {
+			return BundleDiff{}, err
+		}
+		toNested, err := d.findBundle(d.toBundles, changedBundle.To)
+		if err != nil {
+			return BundleDiff{}, err
+		}
+
+		nestedDiff, err := d.Diff(fromNested, toNested)
+		if err != nil {
+			return BundleDiff{}, fmt.Errorf("Comparing nested bundle '%s': %s", changedBundle.Key, err)
+		}
+		diff.Bundles = append(diff.Bundles, nestedDiff)
+	}
+
+	return diff, nil
+}
+
+func (d bundleDiffer) filesDiff(from, to *bundle.Bundle) (FilesDiff, error) {
+	fromFiles, err := from.FileDigests()
+	if err != nil {
+		return FilesDiff{}, fmt.Errorf("Reading files of bundle '%s': %s", from.DigestRef(), err)
+	}
+	toFiles, err := to.FileDigests()
+	if err != nil {
+		return FilesDiff{}, fmt.Errorf("Reading files of bundle '%s': %s", to.DigestRef(), err)
+	}
+
+	diff := FilesDiff{}
+	for file, fromDigest := range fromFiles {
+		toDigest, found := toFiles[file]
+		switch {
+		case !found:
+			diff.Removed = append(diff.Removed, file)
+		case toDigest != fromDigest:
+			diff.Modified = append(diff.Modified, file)
+		}
+	}
+	for file := range toFiles {
+		if _, found := fromFiles[file]; !found {
+			diff.Added = append(diff.Added, file)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+
+	return diff, nil
+}
+
+func (d bundleDiffer) metadataDiff(from, to *bundle.Bundle) ([]MetadataChange, error) {
+	fromMetadata, err := d.metadata(from)
+	if err != nil {
+		return nil, err
+	}
+	toMetadata, err := d.metadata(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []MetadataChange
+	keys := map[string]bool{}
+	for key := range fromMetadata.Metadata {
+		keys[key] = true
+	}
+	for key := range toMetadata.Metadata {
+		keys[key] = true
+	}
+	var sortedKeys []string
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	for _, key := range sortedKeys {
+		if fromMetadata.Metadata[key] != toMetadata.Metadata[key] {
+			changes = append(changes, MetadataChange{Field: "metadata." + key, From: fromMetadata.Metadata[key], To: toMetadata.Metadata[key]})
+		}
+	}
+
+	var fromAuthors, toAuthors []string
+	for _, author := range fromMetadata.Authors {
+		fromAuthors = append(fromAuthors, formatAuthor(author))
+	}
+	for _, author := range toMetadata.Authors {
+		toAuthors = append(toAuthors, formatAuthor(author))
+	}
+	if strings.Join(fromAuthors, ", ") != strings.Join(toAuthors, ", ") {
+		changes = append(changes, MetadataChange{Field: "authors", From: strings.Join(fromAuthors, ", "), To: strings.Join(toAuthors, ", ")})
+	}
+
+	var fromWebsites, toWebsites []string
+	for _, website := range fromMetadata.Websites {
+		fromWebsites = append(fromWebsites, website.URL)
+	}
+	for _, website := range toMetadata.Websites {
+		toWebsites = append(toWebsites, website.URL)
+	}
+	if strings.Join(fromWebsites, ", ") != strings.Join(toWebsites, ", ") {
+		changes = append(changes, MetadataChange{Field: "websites", From: strings.Join(fromWebsites, ", "), To: strings.Join(toWebsites, ", ")})
+	}
+
+	return changes, nil
+}
+
+func formatAuthor(author Author) string {
+	if author.Email == "" {
+		return author.Name
+	}
+	return fmt.Sprintf("%s <%s>", author.Name, author.Email)
+}
+
+func (d bundleDiffer) metadata(b *bundle.Bundle) (Metadata, error) {
+	contents, found, err := b.ReadFile(path.Join(bundle.ImgpkgDir, bundle.BundleMetadataFile))
+	if err != nil {
+		return Metadata{}, fmt.Errorf("Reading metadata of bundle '%s': %s", b.DigestRef(), err)
+	}
+	if !found {
+		return Metadata{}, nil
+	}
+
+	metadata, _, err := ParseMetadata(contents, false)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("Unmarshaling metadata of bundle '%s': %s", b.DigestRef(), err)
+	}
+	return metadata, nil
+}
+
+// imagesDiff matches the images of both bundles, first by the original reference annotation added by kbld and then
+// by repository. Matched images are reported as changed when the digest is different. Bundles that changed are also returned
+func (d bundleDiffer) imagesDiff(fromRefs, toRefs []bundle.ImageRef) (ImagesDiff, []ImageDiff) {
+	fromRefs, toRefs = d.withoutUnchangedImages(fromRefs, toRefs)
+	sort.Slice(fromRefs, func(i, j int) bool { return fromRefs[i].Image < fromRefs[j].Image })
+	sort.Slice(toRefs, func(i, j int) bool { return toRefs[i].Image < toRefs[j].Image })
+
+	diff := ImagesDiff{}
+	var changedBundles []ImageDiff
+	for _, matchKey := range []func(bundle.ImageRef) string{d.annotationKey, d.repositoryKey} {
+		var unmatchedFromRefs []bundle.ImageRef
+		for _, fromRef := range fromRefs {
+			key := matchKey(fromRef)
+			toIdx := -1
+			for idx, toRef := range toRefs {
+				if key != "" && matchKey(toRef) == key && toRef.ImageType == fromRef.ImageType {
+					toIdx = idx
+					break
+				}
+			}
+			if toIdx == -1 {
+				unmatchedFromRefs = append(unmatchedFromRefs, fromRef)
+				continue
+			}
+
+			toRef := toRefs[toIdx]
+			toRefs = append(toRefs[:toIdx:toIdx], toRefs[toIdx+1:]...)
+
+			changed := ImageDiff{Key: d.imageKey(toRef), From: fromRef.Image, To: toRef.Image, ImageType: toRef.ImageType}
+			diff.Changed = append(diff.Changed, changed)
+			if toRef.ImageType == bundle.BundleImage && fromRef.Error == "" && toRef.Error == "" {
+				changedBundles = append(changedBundles, changed)
+			}
+		}
+		fromRefs = unmatchedFromRefs
+	}
+
+	for _, fromRef := range fromRefs {
+		diff.Removed = append(diff.Removed, ImageDiff{Key: d.imageKey(fromRef), From: fromRef.Image, ImageType: fromRef.ImageType})
+	}
+	for _, toRef := range toRefs {
+		diff.Added = append(diff.Added, ImageDiff{Key: d.imageKey(toRef), To: toRef.Image, ImageType: toRef.ImageType})
+	}
+
+	sortImageDiffs := func(diffs []ImageDiff) {
+		sort.SliceStable(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	}
+	sortImageDiffs(diff.Added)
+	sortImageDiffs(diff.Removed)
+	sortImageDiffs(diff.Changed)
+
+	return diff, changedBundles
+}
+
+// withoutUnchangedImages removes the images present in both bundles
+func (d bundleDiffer) withoutUnchangedImages(fromRefs, toRefs []bundle.ImageRef) ([]bundle.ImageRef, []bundle.ImageRef) {
+	fromImages := map[string]bool{}
+	for _, ref := range fromRefs {
+		fromImages[ref.Image] = true
+	}
+	toImages := map[string]bool{}
+	for _, ref := range toRefs {
+		toImages[ref.Image] = true
+	}
+
+	var changedFromRefs, changedToRefs []bundle.ImageRef
+	for _, ref := range fromRefs {
+		if !toImages[ref.Image] {
+			changedFromRefs = append(changedFromRefs, ref)
+		}
+	}
+	for _, ref := range toRefs {
+		if !fromImages[ref.Image] {
+			changedToRefs = append(changedToRefs, ref)
+		}
+	}
+	return changedFromRefs, changedToRefs
+}
+
+// imageKey identifies the image in the diff, using the original reference annotation
+// added by kbld when present and the repository of the image otherwise
+func (d bundleDiffer) imageKey(ref bundle.ImageRef) string {
+	if key := d.annotationKey(ref); key != "" {
+		return key
+	}
+	return d.repositoryKey(ref)
+}
+
+func (d bundleDiffer) annotationKey(ref bundle.ImageRef) string {
+	return ref.Annotations[bundle.ImageOriginalRefAnnotation]
+}
+
+func (d bundleDiffer) repositoryKey(ref bundle.ImageRef) string {
+	digest, err := regname.NewDigest(ref.Image)
+	if err != nil {
+		return ref.Image
+	}
+	return digest.Context().Name()
+}
+
+func (d bundleDiffer) findBundle(bundles []*bundle.Bundle, image string) (*bundle.Bundle, error) {
+	digest, err := regname.NewDigest(image)
+	if err != nil {
+		return nil, fmt.Errorf("Internal inconsistency: image %s should be fully resolved", image)
+	}
+
+	for _, b := range bundles {
+		if b.Digest() == digest.DigestStr() {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("Internal inconsistency: bundle with ref '%s' could not be found in list of bundles", image)
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
+	v1 "carvel.dev/imgpkg/pkg/imgpkg/v1"
+	"carvel.dev/imgpkg/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	logger := &helpers.Logger{LogLevel: helpers.LogDebug}
+	fakeRegistry := helpers.NewFakeRegistry(t, logger)
+	defer fakeRegistry.CleanUp()
+
+	writeBundleDir := func(files map[string]string) string {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, bundle.ImgpkgDir), 0700))
+		for file, contents := range files {
+			require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0700))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(contents), 0600))
+		}
+		return dir
+	}
+
+	nestedImage := fakeRegistry.WithRandomImage("app/nested-img")
+	fromNested := fakeRegistry.WithBundleFromPath("repo/nested-bundle", writeBundleDir(map[string]string{"config.yml": "v1"})).
+		WithImageRefs([]lockconfig.ImageRef{{Image: nestedImage.RefDigest}})
+	toNested := fakeRegistry.WithBundleFromPath("repo/nested-bundle", writeBundleDir(map[string]string{"config.yml": "v2"})).
+		WithImageRefs([]lockconfig.ImageRef{{Image: nestedImage.RefDigest}})
+
+	unchangedImage := fakeRegistry.WithRandomImage("app/unchanged")
+	fromImage := fakeRegistry.WithRandomImage("app/changed")
+	toImage := fakeRegistry.WithRandomImage("app/changed")
+	fromAnnotatedImage := fakeRegistry.WithRandomImage("app/annotated-v1")
+	toAnnotatedImage := fakeRegistry.WithRandomImage("app/annotated-v2")
+	removedImage := fakeRegistry.WithRandomImage("app/removed")
+	addedImage := fakeRegistry.WithRandomImage("app/added")
+
+	fromBundle := fakeRegistry.WithBundleFromPath("repo/bundle", writeBundleDir(map[string]string{
+		"modified.yml":         "a: 1",
+		"removed.yml":          "b: 2",
+		".imgpkg/bundle.yml":   "apiVersion: imgpkg.carvel.dev/v1alpha1\nkind: Bundle\nmetadata:\n  name: app\n  version: 1.0.0\nauthors:\n- name: Carvel Team\n",
+		"unchanged/config.yml": "c: 3",
+	})).WithImageRefs([]lockconfig.ImageRef{
+		{Image: unchangedImage.RefDigest},
+		{Image: fromImage.RefDigest},
+		{Image: fromAnnotatedImage.RefDigest, Annotations: map[string]string{bundle.ImageOriginalRefAnnotation: "app"}},
+		{Image: removedImage.RefDigest},
+		{Image: fromNested.RefDigest},
+	})
+	toBundle := fakeRegistry.WithBundleFromPath("repo/bundle", writeBundleDir(map[string]string{
+		"modified.yml":         "a: 2",
+		"added.yml":            "d: 4",
+		".imgpkg/bundle.yml":   "apiVersion: imgpkg.carvel.dev/v1alpha1\nkind: Bundle\nmetadata:\n  name: app\n  version: 2.0.0\nauthors:\n- name: Carvel Team\n",
+		"unchanged/config.yml": "c: 3",
+	})).WithImageRefs([]lockconfig.ImageRef{
+		{Image: unchangedImage.RefDigest},
+		{Image: toImage.RefDigest},
+		{Image: toAnnotatedImage.RefDigest, Annotations: map[string]string{bundle.ImageOriginalRefAnnotation: "app"}},
+		{Image: addedImage.RefDigest},
+		{Image: toNested.RefDigest},
+	})
+
+	reg := fakeRegistry.Build()
+	opts := v1.DiffOpts{Logger: logger, Concurrency: 1}
+
+	t.Run("reports the files, images, metadata and nested bundles that changed", func(t *testing.T) {
+		diff, err := v1.DiffWithRegistry(v1.DiffOrigin{BundleRef: fromBundle.RefDigest}, v1.DiffOrigin{BundleRef: toBundle.RefDigest}, opts, reg)
+		require.NoError(t, err)
+
+		assert.True(t, diff.HasChanges())
+		assert.Equal(t, fromBundle.RefDigest, diff.From)
+		assert.Equal(t, toBundle.RefDigest, diff.To)
+		assert.Equal(t, v1.FilesDiff{
+			Added:    []string{"added.yml"},
+			Removed:  []string{"removed.yml"},
+			Modified: []string{".imgpkg/bundle.yml", ".imgpkg/images.yml", "modified.yml"},
+		}, diff.Files)
+		assert.Equal(t, []v1.MetadataChange{{Field: "metadata.version", From: "1.0.0", To: "2.0.0"}}, diff.Metadata)
+
+		assert.Equal(t, v1.ImagesDiff{
+			Added:   []v1.ImageDiff{{Key: fakeRegistry.ReferenceOnTestServer("app/added"), To: addedImage.RefDigest, ImageType: bundle.ContentImage}},
+			Removed: []v1.ImageDiff{{Key: fakeRegistry.ReferenceOnTestServer("app/removed"), From: removedImage.RefDigest, ImageType: bundle.ContentImage}},
+			Changed: []v1.ImageDiff{
+				{Key: fakeRegistry.ReferenceOnTestServer("app/changed"), From: fromImage.RefDigest, To: toImage.RefDigest, ImageType: bundle.ContentImage},
+				{Key: fakeRegistry.ReferenceOnTestServer("repo/nested-bundle"), From: fromNested.RefDigest, To: toNested.RefDigest, ImageType: bundle.BundleImage},
+				{Key: "app", From: fromAnnotatedImage.RefDigest, To: toAnnotatedImage.RefDigest, ImageType: bundle.ContentImage},
+			},
+		}, diff.Images)
+
+		require.Len(t, diff.Bundles, 1)
+		assert.Equal(t, fromNested.RefDigest, diff.Bundles[0].From)
+		assert.Equal(t, toNested.RefDigest, diff.Bundles[0].To)
+		assert.Equal(t, v1.FilesDiff{Modified: []string{"config.yml"}}, diff.Bundles[0].Files)
+		assert.Empty(t, diff.Bundles[0].Images)
+	})
+
+	t.Run("reports no changes when comparing the same bundle", func(t *testing.T) {
+		diff, err := v1.DiffWithRegistry(v1.DiffOrigin{BundleRef: toBundle.RefDigest}, v1.DiffOrigin{BundleRef: toBundle.RefDigest}, opts, reg)
+		require.NoError(t, err)
+		assert.False(t, diff.HasChanges())
+	})
+
+	t.Run("compares a bundle in a tarball", func(t *testing.T) {
+		origin, copyOpts, _ := testSetup(nil, "", fromBundle.RefDigest, "", "")
+		tarPath := filepath.Join(t.TempDir(), "bundle.tar")
+		_, err := v1.CopyToTar(origin, tarPath, copyOpts, reg)
+		require.NoError(t, err)
+
+		diff, err := v1.DiffWithRegistry(v1.DiffOrigin{TarPath: tarPath}, v1.DiffOrigin{BundleRef: toBundle.RefDigest}, opts, reg)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"added.yml"}, diff.Files.Added)
+		assert.Len(t, diff.Images.Changed, 3)
+		require.Len(t, diff.Bundles, 1)
+		assert.Equal(t, v1.FilesDiff{Modified: []string{"config.yml"}}, diff.Bundles[0].Files)
+	})
+
+	t.Run("fails when the reference is not a bundle", func(t *testing.T) {
+		_, err := v1.DiffWithRegistry(v1.DiffOrigin{BundleRef: unchangedImage.RefDigest}, v1.DiffOrigin{BundleRef: toBundle.RefDigest}, opts, reg)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is not a bundle")
+	})
+}
@@ -0,0 +1,252 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"sigs.k8s.io/yaml"
+)
+
+// metadataFile contents of the bundle.BundleMetadataFile
+type metadataFile struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Metadata
+}
+
+// knownMetadataFields fields allowed in the bundle.BundleMetadataFile, per object
+var knownMetadataFields = map[string][]string{
+	"":         {"apiVersion", "kind", "metadata", "authors", "websites"},
+	"authors":  {"name", "email"},
+	"websites": {"url"},
+}
+
+// ParseMetadata parses the contents of a bundle.BundleMetadataFile.
+// Unknown fields, apiVersion or kind are returned as warnings, when strict is true they fail the parsing instead
+func ParseMetadata(data []byte, strict bool) (Metadata, []string, error) {
+	var file metadataFile
+	err := yaml.Unmarshal(data, &file)
+	if err != nil {
+		return Metadata{}, nil, fmt.Errorf("Unmarshaling bundle metadata: %s", err)
+	}
+
+	var fields map[string]interface{}
+	err = yaml.Unmarshal(data, &fields)
+	if err != nil {
+		return Metadata{}, nil, fmt.Errorf("Unmarshaling bundle metadata: %s", err)
+	}
+
+	var problems []string
+	if file.APIVersion != "" && file.APIVersion != bundle.BundleMetadataAPIVersion {
+		problems = append(problems, fmt.Sprintf("Validating apiVersion: Unknown version (known: %s)", bundle.BundleMetadataAPIVersion))
+	}
+	if file.Kind != "" && file.Kind != bundle.BundleMetadataKind {
+		problems = append(problems, fmt.Sprintf("Validating kind: Unknown kind (known: %s)", bundle.BundleMetadataKind))
+	}
+	for _, field := range unknownMetadataFields(fields) {
+		problems = append(problems, fmt.Sprintf("Validating %s: Unknown field", field))
+	}
+
+	if strict && len(problems) > 0 {
+		return Metadata{}, nil, fmt.Errorf("Validating bundle metadata:\n- %s", strings.Join(problems, "\n- "))
+	}
+
+	return file.Metadata, problems, nil
+}
+
+// unknownMetadataFields returns the fields, top level or inside authors and websites, that are not part of the metadata
+func unknownMetadataFields(fields map[string]interface{}) []string {
+	var unknown []string
+	for key, value := range fields {
+		if !isKnownMetadataField("", key) {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		items, isList := value.([]interface{})
+		if !isList {
+			continue
+		}
+		for i, item := range items {
+			itemFields, isMap := item.(map[string]interface{})
+			if !isMap {
+				continue
+			}
+			for itemKey := range itemFields {
+				if !isKnownMetadataField(key, itemKey) {
+					unknown = append(unknown, fmt.Sprintf("%s[%d].%s", key, i, itemKey))
+				}
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func isKnownMetadataField(parent, field string) bool {
+	for _, known := range knownMetadataFields[parent] {
+		if field == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadMetadataFile reads the bundle.BundleMetadataFile in path, see ParseMetadata
+func ReadMetadataFile(path string, strict bool) (Metadata, []string, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return Metadata{}, nil, fmt.Errorf("Reading path %s: %s", path, err)
+	}
+
+	return ParseMetadata(bs, strict)
+}
+
+// ValidateMetadataFile validates the bundle.BundleMetadataFile in path and ensures requiredKeys are present in it.
+// When strict is false invalid metadata is only returned as warnings, when keys are required the file must exist
+func ValidateMetadataFile(path string, requiredKeys []string, strict bool) ([]string, error) {
+	if _, err := os.Stat(path); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if len(requiredKeys) > 0 {
+			return nil, fmt.Errorf("Validating bundle metadata: Expected %s/%s to exist to provide required keys: %s",
+				bundle.ImgpkgDir, bundle.BundleMetadataFile, strings.Join(requiredKeys, ", "))
+		}
+		return nil, nil
+	}
+
+	metadata, warnings, err := ReadMetadataFile(path, strict)
+	if err != nil {
+		if strict {
+			return nil, err
+		}
+		return []string{err.Error()}, nil
+	}
+
+	err = metadata.Validate(requiredKeys)
+	if err != nil {
+		if strict {
+			return nil, err
+		}
+		warnings = append(warnings, err.Error())
+	}
+
+	return warnings, nil
+}
+
+// Validate checks that authors emails and websites URLs are valid and that every one of requiredKeys is present in the metadata
+func (m Metadata) Validate(requiredKeys []string) error {
+	var errs []string
+
+	for key := range m.Metadata {
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, "Validating metadata: Expected keys to be non-empty")
+		}
+	}
+
+	for i, author := range m.Authors {
+		if author.Name == "" && author.Email == "" {
+			errs = append(errs, fmt.Sprintf("Validating authors[%d]: Expected name or email to be provided", i))
+		}
+		if author.Email != "" {
+			addr, err := mail.ParseAddress(author.Email)
+			if err != nil || addr.Address != author.Email {
+				errs = append(errs, fmt.Sprintf("Validating authors[%d].email: Invalid email address '%s'", i, author.Email))
+			}
+		}
+	}
+
+	for i, website := range m.Websites {
+		err := validateWebsiteURL(website.URL)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("Validating websites[%d].url: %s", i, err))
+		}
+	}
+
+	var missingKeys []string
+	for _, key := range requiredKeys {
+		if strings.TrimSpace(m.Metadata[key]) == "" {
+			missingKeys = append(missingKeys, key)
+		}
+	}
+	if len(missingKeys) > 0 {
+		sort.Strings(missingKeys)
+		errs = append(errs, fmt.Sprintf("Validating metadata: Missing required keys: %s", strings.Join(missingKeys, ", ")))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Validating bundle metadata:\n- %s", strings.Join(errs, "\n- "))
+	}
+	return nil
+}
+
+// validateWebsiteURL accepts URLs with http or https scheme, or without scheme, i.e. carvel.dev/imgpkg
+func validateWebsiteURL(websiteURL string) error {
+	toParse := websiteURL
+	if !strings.Contains(websiteURL, "://") {
+		toParse = "https://" + websiteURL
+	}
+
+	parsedURL, err := url.Parse(toParse)
+	if err != nil || parsedURL.Host == "" || strings.ContainsAny(parsedURL.Host, " ") {
+		return fmt.Errorf("Invalid URL '%s'", websiteURL)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return fmt.Errorf("Invalid URL '%s': Expected http or https scheme", websiteURL)
+	}
+	return nil
+}
+
+// Get returns the value of key in the metadata
+func (m Metadata) Get(key string) (string, bool) {
+	value, found := m.Metadata[key]
+	return value, found
+}
+
+// Set changes the value of key in the metadata
+func (m *Metadata) Set(key, value string) {
+	if m.Metadata == nil {
+		m.Metadata = map[string]string{}
+	}
+	m.Metadata[key] = value
+}
+
+// Remove deletes key from the metadata
+func (m *Metadata) Remove(key string) {
+	delete(m.Metadata, key)
+}
+
+// AsBytes returns the metadata as the YAML contents of a bundle.BundleMetadataFile
+func (m Metadata) AsBytes() ([]byte, error) {
+	bs, err := yaml.Marshal(metadataFile{APIVersion: bundle.BundleMetadataAPIVersion, Kind: bundle.BundleMetadataKind, Metadata: m})
+	if err != nil {
+		return nil, fmt.Errorf("Marshaling bundle metadata: %s", err)
+	}
+
+	return bs, nil
+}
+
+// WriteToPath writes the metadata as a bundle.BundleMetadataFile into path
+func (m Metadata) WriteToPath(path string) error {
+	bs, err := m.AsBytes()
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, bs, 0600)
+	if err != nil {
+		return fmt.Errorf("Writing bundle metadata: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 The Carvel Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package v1_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	v1 "carvel.dev/imgpkg/pkg/imgpkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetadata(t *testing.T) {
+	t.Run("parses valid metadata", func(t *testing.T) {
+		metadata, warnings, err := v1.ParseMetadata([]byte(`
+apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: Bundle
+metadata:
+  name: app
+authors:
+- name: Carvel Team
+  email: carvel@vmware.com
+websites:
+- url: carvel.dev/imgpkg
+- url: https://github.com/carvel-dev/imgpkg
+`), true)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+		require.NoError(t, metadata.Validate(nil))
+
+		value, found := metadata.Get("name")
+		assert.True(t, found)
+		assert.Equal(t, "app", value)
+		assert.Equal(t, []v1.Author{{Name: "Carvel Team", Email: "carvel@vmware.com"}}, metadata.Authors)
+	})
+
+	t.Run("warns about unknown fields", func(t *testing.T) {
+		metadata, warnings, err := v1.ParseMetadata([]byte("kind: Bundle\nmetadata:\n  name: app\nauthor:\n- name: someone\nwebsites:\n- url: carvel.dev\n  title: Carvel\n"), false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Validating author: Unknown field", "Validating websites[0].title: Unknown field"}, warnings)
+		assert.Equal(t, map[string]string{"name": "app"}, metadata.Metadata)
+	})
+
+	t.Run("when strict, fails on unknown fields", func(t *testing.T) {
+		_, _, err := v1.ParseMetadata([]byte("kind: Bundle\nauthor:\n- name: someone\n"), true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Validating author: Unknown field")
+	})
+
+	t.Run("reports every invalid email and URL", func(t *testing.T) {
+		metadata, _, err := v1.ParseMetadata([]byte(`
+kind: Bundle
+authors:
+- name: Someone
+  email: not-an-email
+- email: Someone <someone@example.com>
+- {}
+websites:
+- url: ftp://carvel.dev
+- url: "http://"
+`), true)
+		require.NoError(t, err)
+
+		err = metadata.Validate(nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "authors[0].email: Invalid email address 'not-an-email'")
+		assert.Contains(t, err.Error(), "authors[1].email: Invalid email address 'Someone <someone@example.com>'")
+		assert.Contains(t, err.Error(), "authors[2]: Expected name or email to be provided")
+		assert.Contains(t, err.Error(), "websites[0].url: Invalid URL 'ftp://carvel.dev': Expected http or https scheme")
+		assert.Contains(t, err.Error(), "websites[1].url: Invalid URL 'http://'")
+	})
+
+	t.Run("fails when required keys are missing", func(t *testing.T) {
+		metadata := v1.Metadata{}
+		metadata.Set("name", "app")
+		metadata.Set("version", " ")
+
+		err := metadata.Validate([]string{"name", "version", "owner"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Missing required keys: owner, version")
+
+		require.NoError(t, metadata.Validate([]string{"name"}))
+	})
+
+	t.Run("round trips through a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), bundle.BundleMetadataFile)
+		metadata := v1.Metadata{}
+		metadata.Set("version", "1.0.0")
+		metadata.Websites = []v1.Website{{URL: "carvel.dev"}}
+		require.NoError(t, metadata.WriteToPath(path))
+
+		bs, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(bs), "apiVersion: imgpkg.carvel.dev/v1alpha1\nkind: Bundle\n")
+
+		readMetadata, warnings, err := v1.ReadMetadataFile(path, true)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+		assert.Equal(t, metadata, readMetadata)
+	})
+}
+
+func TestValidateMetadataFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), bundle.BundleMetadataFile)
+
+	t.Run("succeeds without the file when no keys are required", func(t *testing.T) {
+		warnings, err := v1.ValidateMetadataFile(path, nil, true)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("fails without the file when keys are required", func(t *testing.T) {
+		_, err := v1.ValidateMetadataFile(path, []string{"version"}, true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Expected .imgpkg/bundle.yml to exist")
+	})
+
+	t.Run("checks the required keys", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("kind: Bundle\nmetadata:\n  version: 1.0.0\n"), 0600))
+
+		_, err := v1.ValidateMetadataFile(path, []string{"version"}, true)
+		require.NoError(t, err)
+
+		_, err = v1.ValidateMetadataFile(path, []string{"owner"}, true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Missing required keys: owner")
+	})
+
+	t.Run("when not strict, invalid metadata is only reported as warnings", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("kind: Bundle\nowner: someone\nauthors:\n- email: not-an-email\n"), 0600))
+
+		warnings, err := v1.ValidateMetadataFile(path, nil, false)
+		require.NoError(t, err)
+		require.Len(t, warnings, 2)
+		assert.Equal(t, "Validating owner: Unknown field", warnings[0])
+		assert.Contains(t, warnings[1], "authors[0].email: Invalid email address 'not-an-email'")
+
+		_, err = v1.ValidateMetadataFile(path, nil, true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Validating owner: Unknown field")
+	})
+}
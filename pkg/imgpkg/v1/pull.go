@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/bundle"
+	"carvel.dev/imgpkg/pkg/imgpkg/image"
+	"carvel.dev/imgpkg/pkg/imgpkg/lockconfig"
 	"carvel.dev/imgpkg/pkg/imgpkg/plainimage"
 	"carvel.dev/imgpkg/pkg/imgpkg/registry"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -29,6 +31,42 @@ type PullOpts struct {
 	AsImage bool
 	// IsBundle the image being pulled is a Bundle
 	IsBundle bool
+	// IncludePaths only extract the files that match at least one of these paths
+	IncludePaths []string
+	// ExcludePaths do not extract the files that match any of these paths
+	ExcludePaths []string
+	// Merge extract into the existing output directory instead of replacing it
+	Merge bool
+	// OnConflict what to do, when merging, with files that already exist in the output directory (overwrite, skip or fail)
+	OnConflict string
+	// Clean only remove the files extracted by a previous pull of the same bundle or image before extracting. Implies Merge
+	Clean bool
+	// WithImagesAsLayout also write every image referenced by the bundle into an OCI Image Layout in the images folder
+	WithImagesAsLayout bool
+	// Concurrency number of concurrent requests used to retrieve the images of the bundle
+	Concurrency int
+	// ImageMap rewrite the bundle's ImagesLock using these mappings instead of checking if the bundle was relocated
+	ImageMap *lockconfig.ImageMap
+}
+
+func (p PullOpts) extractOpts() (image.ExtractOpts, error) {
+	pathFilter, err := image.NewPathFilter(p.IncludePaths, p.ExcludePaths)
+	if err != nil {
+		return image.ExtractOpts{}, err
+	}
+
+	extractOpts := image.ExtractOpts{
+		PathFilter: pathFilter,
+		Merge:      p.Merge,
+		Clean:      p.Clean,
+	}
+	if p.OnConflict != "" {
+		extractOpts.OnConflict, err = image.NewConflictPolicy(p.OnConflict)
+		if err != nil {
+			return image.ExtractOpts{}, err
+		}
+	}
+	return extractOpts, nil
 }
 
 // ImagesLockInfo Information about the ImagesLock file
@@ -126,6 +164,46 @@ func PullWithRegistry(imageRef string, outputPath string, pullOptions PullOpts,
 	return PullStatus{}, fmt.Errorf("Unknown option")
 }
 
+// VerifyPulled Compares the folder outputPath, where the image referenced by imageRef was previously pulled,
+// against the contents of the image without extracting it
+func VerifyPulled(imageRef string, outputPath string, pullOptions PullOpts, registryOpts registry.Opts) (image.DirDiff, error) {
+	reg, err := registry.NewSimpleRegistry(registryOpts)
+	if err != nil {
+		return image.DirDiff{}, err
+	}
+	return VerifyPulledWithRegistry(imageRef, outputPath, pullOptions, reg)
+}
+
+// VerifyPulledWithRegistry Compares the folder outputPath, where the image referenced by imageRef was previously pulled,
+// against the contents of the image without extracting it
+func VerifyPulledWithRegistry(imageRef string, outputPath string, pullOptions PullOpts, reg registry.Registry) (image.DirDiff, error) {
+	imagesLockReader := bundle.NewImagesLockReader()
+	bundleToVerify := bundle.NewBundleFromRef(imageRef, reg, imagesLockReader, bundle.NewRegistryFetcher(reg, imagesLockReader))
+	isBundle, err := bundleToVerify.IsBundle()
+	if err != nil {
+		return image.DirDiff{}, err
+	}
+
+	extractOpts, err := pullOptions.extractOpts()
+	if err != nil {
+		return image.DirDiff{}, err
+	}
+
+	switch {
+	case isBundle && pullOptions.IsBundle:
+		return bundleToVerify.VerifyDirectory(outputPath, pullOptions.Logger, extractOpts)
+
+	case !isBundle && pullOptions.IsBundle:
+		return image.DirDiff{}, &ErrIsNotBundle{}
+
+	case isBundle && !pullOptions.IsBundle && !pullOptions.AsImage:
+		return image.DirDiff{}, &ErrIsBundle{}
+
+	default:
+		return plainimage.NewPlainImage(imageRef, reg).VerifyDirectory(outputPath, pullOptions.Logger, extractOpts)
+	}
+}
+
 // PullRecursive Downloads the contents of the Bundle and Nested Bundles referenced by imageRef to the folder outputPath.
 // This functions should error out when imageRef does not point to a Bundle
 func PullRecursive(imageRef string, outputPath string, pullOptions PullOpts, registryOpts registry.Opts) (PullStatus, error) {
@@ -156,11 +234,31 @@ func PullRecursiveWithRegistry(imageRef string, outputPath string, pullOptions P
 // pullBundle Downloads the contents of the Bundle Image referenced by imageRef to the folder outputPath.
 // This functions should error out when imageRef does not point to a Bundle
 func pullBundle(imgRef string, bundleToPull *bundle.Bundle, outputPath string, pullOptions PullOpts, pullNestedBundles bool) (PullStatus, error) {
-	isRootBundleRelocated, err := bundleToPull.Pull(outputPath, pullOptions.Logger, pullNestedBundles)
+	extractOpts, err := pullOptions.extractOpts()
 	if err != nil {
 		return PullStatus{}, err
 	}
 
+	isRootBundleRelocated, err := bundleToPull.PullWithOpts(outputPath, pullOptions.Logger, bundle.PullOpts{
+		PullNestedBundles: pullNestedBundles,
+		ExtractOpts:       extractOpts,
+		ImageMap:          pullOptions.ImageMap,
+	})
+	if err != nil {
+		return PullStatus{}, err
+	}
+
+	if pullOptions.WithImagesAsLayout {
+		concurrency := pullOptions.Concurrency
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		err = bundleToPull.WriteImagesAsLayout(outputPath, concurrency, pullOptions.Logger)
+		if err != nil {
+			return PullStatus{}, err
+		}
+	}
+
 	isCacheable, err := isCacheable(imgRef, isRootBundleRelocated)
 	if err != nil {
 		return PullStatus{}, err
@@ -187,11 +285,19 @@ func pullImage(imageRef string, outputPath string, pullOptions PullOpts, reg reg
 	if err != nil {
 		return PullStatus{}, err
 	}
+	if pullOptions.ImageMap != nil {
+		return PullStatus{}, fmt.Errorf("Image map can only be used when pulling a bundle")
+	}
 	if !isImage {
 		return PullStatus{}, fmt.Errorf("Unable to pull non-images, such as image indexes. (hint: provide a specific digest to the image instead)")
 	}
 
-	err = plainImg.Pull(outputPath, pullOptions.Logger)
+	extractOpts, err := pullOptions.extractOpts()
+	if err != nil {
+		return PullStatus{}, err
+	}
+
+	err = plainImg.PullWithOpts(outputPath, pullOptions.Logger, extractOpts)
 	if err != nil {
 		return PullStatus{}, err
 	}
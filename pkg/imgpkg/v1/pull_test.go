@@ -5,8 +5,10 @@ package v1_test
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"carvel.dev/imgpkg/pkg/imgpkg/internal/util"
@@ -256,6 +258,30 @@ func TestPullBundle(t *testing.T) {
 		_, err = v1.Pull(img1.RefDigest, outputFolder, opts, registry.Opts{})
 		require.ErrorContains(t, err, "The provided image is not a bundle")
 	})
+
+	t.Run("when the cache is enabled, pulling the bundle again does not download any blob", func(t *testing.T) {
+		blobRequests := 0
+		fakeRegistry.WithHandlerFunc(func(_ http.ResponseWriter, r *http.Request) bool {
+			if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+				blobRequests++
+			}
+			return false
+		})
+
+		opts := v1.PullOpts{
+			Logger:   uiLogger,
+			IsBundle: true,
+		}
+		regOpts := registry.Opts{CacheDir: t.TempDir()}
+		_, err := v1.Pull(randomBundle, t.TempDir(), opts, regOpts)
+		require.NoError(t, err)
+		require.NotZero(t, blobRequests)
+
+		blobRequests = 0
+		_, err = v1.Pull(randomBundle, t.TempDir(), opts, regOpts)
+		require.NoError(t, err)
+		assert.Zero(t, blobRequests, "expected the blobs to be read from the cache")
+	})
 }
 
 func TestPullBundleRecursively(t *testing.T) {
@@ -32,6 +32,18 @@ func OptsFromEnv(base registry.Opts, readEnv func(string) (string, bool)) regist
 		opts.EnableIaasAuthProviders = true
 	}
 
+	if len(opts.ConfigPath) == 0 {
+		opts.ConfigPath, _ = readEnv("IMGPKG_REGISTRY_CONFIG")
+	}
+
+	if len(opts.TokenCacheDir) == 0 {
+		opts.TokenCacheDir, _ = readEnv("IMGPKG_TOKEN_CACHE_DIR")
+	}
+
+	if len(opts.CacheDir) == 0 {
+		opts.CacheDir, _ = readEnv("IMGPKG_CACHE_DIR")
+	}
+
 	keychains, found := readEnv("IMGPKG_ACTIVE_KEYCHAINS")
 	if found {
 		if len(keychains) > 0 {
@@ -61,6 +61,43 @@ func TestOptsFromEnv(t *testing.T) {
 		require.Equal(t, registry.Opts{Token: "should-use"}, result)
 	})
 
+	t.Run("when registry config is define it does not overwrite it", func(t *testing.T) {
+		env := envFake{values: map[string]string{"IMGPKG_REGISTRY_CONFIG": "not-used"}}
+		opts := registry.Opts{
+			ConfigPath: "/some/config.yml",
+		}
+		result := v1.OptsFromEnv(opts, env.Value)
+		require.Equal(t, opts, result)
+	})
+
+	t.Run("when registry config is NOT define it uses value from the environment", func(t *testing.T) {
+		env := envFake{values: map[string]string{"IMGPKG_REGISTRY_CONFIG": "/should/use.yml"}}
+		opts := registry.Opts{}
+		result := v1.OptsFromEnv(opts, env.Value)
+		require.Equal(t, registry.Opts{ConfigPath: "/should/use.yml"}, result)
+	})
+
+	t.Run("when token cache dir is NOT define it uses value from the environment", func(t *testing.T) {
+		env := envFake{values: map[string]string{"IMGPKG_TOKEN_CACHE_DIR": "/should/use"}}
+		opts := registry.Opts{}
+		result := v1.OptsFromEnv(opts, env.Value)
+		require.Equal(t, registry.Opts{TokenCacheDir: "/should/use"}, result)
+	})
+
+	t.Run("when cache dir is NOT define it uses value from the environment", func(t *testing.T) {
+		env := envFake{values: map[string]string{"IMGPKG_CACHE_DIR": "/should/use"}}
+		opts := registry.Opts{}
+		result := v1.OptsFromEnv(opts, env.Value)
+		require.Equal(t, registry.Opts{CacheDir: "/should/use"}, result)
+	})
+
+	t.Run("when cache dir is defined it does not use the environment", func(t *testing.T) {
+		env := envFake{values: map[string]string{"IMGPKG_CACHE_DIR": "/should/not/use"}}
+		opts := registry.Opts{CacheDir: "/should/use"}
+		result := v1.OptsFromEnv(opts, env.Value)
+		require.Equal(t, registry.Opts{CacheDir: "/should/use"}, result)
+	})
+
 	t.Run("when anonymous mode is activated via environment variable it set it", func(t *testing.T) {
 		env := envFake{values: map[string]string{"IMGPKG_ANON": "true"}}
 		opts := registry.Opts{}
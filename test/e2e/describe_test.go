@@ -408,7 +408,9 @@ images:
 			digestSha3 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + "@" + imgSigDigest)
 			digestSha4 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + "@" + locationsImgDigest)
 			digestSha5 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + bundleDigest)
-			require.YAMLEq(t, fmt.Sprintf(`sha: %s
+			require.YAMLEq(t, fmt.Sprintf(`apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: BundleDescription
+sha: %s
 content:
   images:
     "%s":
@@ -524,7 +526,9 @@ images:
 			digestSha3 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + "@" + imgSigDigest)
 			digestSha4 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + "@" + locationsImgDigest)
 			digestSha5 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + bundleDigest)
-			require.YAMLEq(t, fmt.Sprintf(`content:
+			require.YAMLEq(t, fmt.Sprintf(`apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: BundleDescription
+content:
   images:
     "%s":
       annotations:
@@ -660,7 +664,9 @@ images:
 			digestSha5 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + img1Digest)
 			digestSha6 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + "@" + locationsOuterBundleImgDigest)
 			digestSha7 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + outerBundleDigest)
-			require.YAMLEq(t, fmt.Sprintf(`sha: %s
+			require.YAMLEq(t, fmt.Sprintf(`apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: BundleDescription
+sha: %s
 content:
   bundles:
     "%s":
@@ -800,7 +806,9 @@ images:
 			digestSha4 := env.ImageFactory.GetImageLayersDigest(nestedBundle + nestedBundleDigest)
 			digestSha5 := env.ImageFactory.GetImageLayersDigest(img1DigestRef)
 			digestSha6 := env.ImageFactory.GetImageLayersDigest(outerBundle + outerBundleDigest)
-			require.YAMLEq(t, fmt.Sprintf(`sha: %s
+			require.YAMLEq(t, fmt.Sprintf(`apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: BundleDescription
+sha: %s
 content:
   bundles:
     "%s":
@@ -935,7 +943,9 @@ images:
 			digestSha1 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + img1Digest)
 			digestSha3 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + "@" + locationsPublicBundleImgDigest)
 			digestSha4 := env.ImageFactory.GetImageLayersDigest(env.RelocationRepo + privateBundleDigest)
-			require.YAMLEq(t, fmt.Sprintf(`sha: %s
+			require.YAMLEq(t, fmt.Sprintf(`apiVersion: imgpkg.carvel.dev/v1alpha1
+kind: BundleDescription
+sha: %s
 content:
   images:
     "%s":